@@ -21,12 +21,15 @@ import (
 
 	"github.com/tmax-cloud/cd-operator/internal/configs"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"fmt"
 	"net/url"
+	"path"
+	"strings"
 )
 
 const (
@@ -38,8 +41,15 @@ const (
 
 // Condition keys for Application
 const (
-	ApplicationConditionReady             = "ready"
-	ApplicationConditionWebhookRegistered = "webhook-registered"
+	ApplicationConditionReady                   = "ready"
+	ApplicationConditionWebhookRegistered       = "webhook-registered"
+	ApplicationConditionPruneLimitExceeded      = "prune-limit-exceeded"
+	ApplicationConditionAutoSyncDisabled        = "autosync-disabled"
+	ApplicationConditionRetryExhausted          = "retry-exhausted"
+	ApplicationConditionSyncBlocked             = "sync-blocked"
+	ApplicationConditionDeletionBlocked         = "deletion-blocked"
+	ApplicationConditionSharedResourceWarning   = "shared-resource-warning"
+	ApplicationConditionOrphanedResourceWarning = "orphaned-resource-warning"
 )
 
 // ApplicationConditionReasonNoGitToken is a Reason key
@@ -62,18 +72,119 @@ const (
 
 // SyncPolicy controls when a sync will be performed in response to updates in git
 type SyncPolicy struct {
-	// AutoSync will keep an application synced to the target revision if it is set true
+	// AutoSync will keep an application synced to the target revision if it is set true.
+	// It's the same as automated with both onCommit and selfHeal.
+	// Deprecated: use automated instead. It's ignored if automated is set
 	AutoSync bool `json:"autosync,omitempty"`
+	// Automated controls which changes are synced automatically
+	Automated *AutomatedSyncPolicy `json:"automated,omitempty"`
 	// SyncCheckPeriod is period to check sync in sec
 	SyncCheckPeriod int64 `json:"syncCheckPeriod,omitempty"`
+	// Prune deletes resources which are no longer defined in git. If it is not set, such resources are kept and
+	// listed in status.resources as requiresPruning
+	Prune bool `json:"prune,omitempty"`
+	// MaxPruneCount refuses a sync which would prune more resources than this value. 0 means no limit
+	// +kubebuilder:validation:Minimum=0
+	MaxPruneCount int `json:"maxPruneCount,omitempty"`
+	// MaxPrunePercentage refuses a sync which would prune more than this percentage of the tracked resources.
+	// 0 means no limit
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	MaxPrunePercentage int `json:"maxPrunePercentage,omitempty"`
+	// SyncWindows allow or deny syncs in the time windows. Windows of the SyncWindowPolicies selecting the
+	// application are applied as well
+	SyncWindows []SyncWindow `json:"syncWindows,omitempty"`
+	// Retry retries a failed sync with a backoff. If it is not set, a failed sync is not retried until the next
+	// sync check
+	Retry *RetryStrategy `json:"retry,omitempty"`
+	// SyncTimeout is the maximum duration of a sync, e.g., 10m. A sync which takes longer is cancelled and fails.
+	// Default is syncTimeout of the cd-config config map
+	SyncTimeout string `json:"syncTimeout,omitempty"`
+	// SyncOptions are options of the syncs of the application, e.g., CreateNamespace=true
+	SyncOptions []string `json:"syncOptions,omitempty"`
+	// ManagedNamespaceMetadata is set to the destination namespace, which is created by CreateNamespace=true.
+	// It's applied only to the namespace owned by the application
+	ManagedNamespaceMetadata *ManagedNamespaceMetadata `json:"managedNamespaceMetadata,omitempty"`
+}
+
+// ManagedNamespaceMetadata is metadata of the destination namespace, which is managed by the application
+type ManagedNamespaceMetadata struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AutomatedSyncPolicy controls the automated syncs
+type AutomatedSyncPolicy struct {
+	// OnCommit syncs new commits of the target revision (and changes of the source) automatically
+	OnCommit bool `json:"onCommit,omitempty"`
+	// SelfHeal reverts the drift of the live state from the synced revision automatically
+	SelfHeal bool `json:"selfHeal,omitempty"`
+	// SelfHealBackoff delays the repeated self-heals, while the live state keeps drifting. Default is 5s, doubled up
+	// to 3m
+	SelfHealBackoff *Backoff `json:"selfHealBackoff,omitempty"`
+}
+
+// RetryStrategy controls the retries of a failed sync
+type RetryStrategy struct {
+	// Limit is the maximum number of retries. A negative value means no limit
+	Limit int64 `json:"limit"`
+	// Backoff controls the delay between the retries
+	Backoff *Backoff `json:"backoff,omitempty"`
+}
+
+// Backoff is an exponential backoff of the retries
+type Backoff struct {
+	// Duration is the delay before the first retry, e.g., 5s. Default is 5s
+	Duration string `json:"duration,omitempty"`
+	// Factor multiplies the delay after each retry. Default is 2
+	// +kubebuilder:validation:Minimum=1
+	Factor *int64 `json:"factor,omitempty"`
+	// MaxDuration is the maximum delay between the retries, e.g., 3m. Default is 3m
+	MaxDuration string `json:"maxDuration,omitempty"`
 }
 
 // SyncStatus contains information about the currently observed live and desired states of an application
 type SyncStatus struct {
 	// Status is the sync state of the comparison
 	Status SyncStatusCode `json:"status,omitempty"`
-	// TimeCheck is time after last sync in second
+	// TimeCheck is time after last sync in second.
+	// Deprecated: it's not updated anymore. Use lastSyncedAt of the application status instead
 	TimeCheck int64 `json:"timeCheck,omitempty"`
+	// Revision is the commit SHA of the source, which the application was compared to
+	Revision string `json:"revision,omitempty"`
+	// ComparedTo is a snapshot of the source and the destination, which the application was compared to
+	ComparedTo ComparedTo `json:"comparedTo,omitempty"`
+}
+
+// ComparedTo contains the source and the destination of the last comparison
+type ComparedTo struct {
+	// Source is a snapshot of the application's source. The token of the source is not included
+	Source ApplicationSource `json:"source"`
+	// Destination is a snapshot of the application's destination
+	Destination ApplicationDestination `json:"destination"`
+}
+
+// ResourceStatus contains the sync and health status of a resource of the application
+type ResourceStatus struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Status is the sync state of the resource
+	Status SyncStatusCode `json:"status,omitempty"`
+	// Health is the health status of the resource. It's empty if the health of the kind is not assessed
+	Health *HealthStatus `json:"health,omitempty"`
+	// RequiresPruning is true if the resource is no longer defined in git, but is not pruned
+	RequiresPruning bool `json:"requiresPruning,omitempty"`
+}
+
+// HealthStatus contains the aggregated health of the application's resources
+type HealthStatus struct {
+	// Status is the worst health status among the application's resources
+	Status HealthStatusCode `json:"status,omitempty"`
+	// Message is a human readable message explaining the status
+	Message string `json:"message,omitempty"`
 }
 
 // ApplicationSpec defines the desired state of Application
@@ -84,12 +195,277 @@ type ApplicationSpec struct {
 	Destination ApplicationDestination `json:"destination"`
 	// SyncPolicy controls when and how a sync will be performed
 	SyncPolicy SyncPolicy `json:"syncPolicy,omitempty"`
+	// RevisionHistoryLimit is the maximum number of syncs kept in status.history. Default is 10
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit int `json:"revisionHistoryLimit,omitempty"`
+	// DeletionPolicy controls what happens to the application's resources, when the application is deleted.
+	// Default is Foreground
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// OrphanedResources monitors the resources in the destination namespaces, which are not tracked by any
+	// application. The monitor is disabled if it's not set
+	OrphanedResources *OrphanedResourcesMonitorSettings `json:"orphanedResources,omitempty"`
+}
+
+// OrphanedResourcesMonitorSettings controls the monitor of the orphaned resources
+type OrphanedResourcesMonitorSettings struct {
+	// Warn sets the orphaned-resource-warning condition, if there are any orphaned resources. Default is true
+	Warn *bool `json:"warn,omitempty"`
+	// Ignore is a list of the resources, which are not regarded as orphaned
+	Ignore []OrphanedResourceKey `json:"ignore,omitempty"`
+}
+
+// IsWarn returns true if the orphaned resources are warned by a condition
+func (s *OrphanedResourcesMonitorSettings) IsWarn() bool {
+	return s.Warn == nil || *s.Warn
+}
+
+// OrphanedResourceKey selects the resources to be ignored by the monitor. An empty field matches any value
+type OrphanedResourceKey struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind,omitempty"`
+	// Name of the resources. It can be a glob pattern, e.g., temp-*
+	Name string `json:"name,omitempty"`
+}
+
+// Matches checks if the resource of the group/kind and name is selected by the key. An invalid pattern matches nothing
+func (k OrphanedResourceKey) Matches(group, kind, name string) bool {
+	if (k.Group != "" && k.Group != group) || (k.Kind != "" && k.Kind != kind) {
+		return false
+	}
+	if k.Name == "" {
+		return true
+	}
+	matched, err := path.Match(k.Name, name)
+	return err == nil && matched
+}
+
+// OrphanedResource is a resource in a destination namespace, which is not tracked by any application
+type OrphanedResource struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// DeletionPolicy is a policy of the deletion of an application's resources
+// +kubebuilder:validation:Enum=Foreground;Background;Orphan
+type DeletionPolicy string
+
+// Deletion policies
+const (
+	// DeletionPolicyForeground deletes the resources, and keeps the application until they are gone
+	DeletionPolicyForeground DeletionPolicy = "Foreground"
+	// DeletionPolicyBackground deletes the resources, and removes the application without waiting for them
+	DeletionPolicyBackground DeletionPolicy = "Background"
+	// DeletionPolicyOrphan keeps the resources (and the helm release) running, and only stops tracking them
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+)
+
+// SyncInitiatorType is a type of the initiator of a sync
+type SyncInitiatorType string
+
+// Types of the sync initiators
+const (
+	SyncInitiatorTypeAutoSync    SyncInitiatorType = "autosync"
+	SyncInitiatorTypeWebhook     SyncInitiatorType = "webhook"
+	SyncInitiatorTypeUser        SyncInitiatorType = "user"
+	SyncInitiatorTypeSyncRequest SyncInitiatorType = "syncrequest"
+)
+
+// SyncInitiator describes who initiated a sync
+type SyncInitiator struct {
+	// Type is a type of the initiator (autosync/webhook/user)
+	Type SyncInitiatorType `json:"type"`
+	// Username is a name of the user who initiated the sync via the api
+	Username string `json:"username,omitempty"`
+	// SyncRequest is a name of the SyncRequest which initiated the sync
+	SyncRequest string `json:"syncRequest,omitempty"`
+}
+
+// Matches checks if the initiator is the same one as the other
+func (i SyncInitiator) Matches(other SyncInitiator) bool {
+	return i.Type == other.Type && i.Username == other.Username && i.SyncRequest == other.SyncRequest
+}
+
+// SyncResult is a result of a sync
+type SyncResult string
+
+// Results of a sync
+const (
+	SyncResultSucceeded SyncResult = "Succeeded"
+	SyncResultFailed    SyncResult = "Failed"
+)
+
+// SyncHistory is a record of a completed sync
+type SyncHistory struct {
+	// ID is a unique id of the history in the application
+	ID int64 `json:"id"`
+	// Revision is the commit SHA of the source, which was synced
+	Revision string `json:"revision"`
+	// Source is a snapshot of the application's source. The token of the source is not included
+	Source ApplicationSource `json:"source"`
+	// StartedAt is the time when the sync started
+	StartedAt metav1.Time `json:"startedAt"`
+	// FinishedAt is the time when the sync finished
+	FinishedAt metav1.Time `json:"finishedAt"`
+	// InitiatedBy describes who initiated the sync
+	InitiatedBy SyncInitiator `json:"initiatedBy"`
+	// Result is a result of the sync
+	Result SyncResult `json:"result"`
+	// Message is a message of the result, e.g., an error message of the failed sync
+	Message string `json:"message,omitempty"`
+}
+
+// Operation is an operation requested to the application, which is executed by the controller
+type Operation struct {
+	// Sync is a sync operation
+	Sync *SyncOperation `json:"sync,omitempty"`
+	// InitiatedBy describes who requested the operation
+	InitiatedBy SyncInitiator `json:"initiatedBy"`
+	// Coalesced are the triggers which were requested while the operation was pending, and merged into it.
+	// Their syncs are superseded by the operation
+	Coalesced []SyncInitiator `json:"coalesced,omitempty"`
+}
+
+// IsCoalesced checks if the trigger of the initiator is merged into the operation
+func (o *Operation) IsCoalesced(initiator SyncInitiator) bool {
+	for _, c := range o.Coalesced {
+		if c.Matches(initiator) {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncOperation is an operation to sync the application
+type SyncOperation struct {
+	// Revision overrides the target revision of the application. It can be a commit SHA
+	Revision string `json:"revision,omitempty"`
+	// RollbackTo is an ID of the sync history, to which the application is rolled back
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+	// Resources to be synced. If neither resources nor labelSelectors is set, all resources are synced.
+	// Otherwise, only the selected resources are applied or pruned, and the others are left as they are
+	Resources []SyncOperationResource `json:"resources,omitempty"`
+	// LabelSelectors select the resources to be synced by their labels, in addition to the resources
+	LabelSelectors []metav1.LabelSelector `json:"labelSelectors,omitempty"`
+	// Prune overrides syncPolicy.prune of the application
+	Prune *bool `json:"prune,omitempty"`
+}
+
+// SyncOperationResource selects a resource to be synced
+type SyncOperationResource struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+	// Namespace of the resource. If it's empty, the resource in any namespace is selected
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+// Matches checks if the resource of the group/kind, namespace and name is selected
+func (r SyncOperationResource) Matches(group, kind, namespace, name string) bool {
+	return r.Group == group && r.Kind == kind && r.Name == name && (r.Namespace == "" || r.Namespace == namespace)
+}
+
+// OperationPhase is a phase of an operation
+type OperationPhase string
+
+// Operation phases
+const (
+	OperationPhaseRunning     OperationPhase = "Running"
+	OperationPhaseTerminating OperationPhase = "Terminating"
+	OperationPhaseSucceeded   OperationPhase = "Succeeded"
+	OperationPhaseFailed      OperationPhase = "Failed"
+	OperationPhaseError       OperationPhase = "Error"
+)
+
+// ResultCode is a result of an operation on a resource
+type ResultCode string
+
+// Result codes of the resources
+const (
+	ResultCodeSynced       ResultCode = "Synced"
+	ResultCodeSyncFailed   ResultCode = "SyncFailed"
+	ResultCodePruned       ResultCode = "Pruned"
+	ResultCodePruneSkipped ResultCode = "PruneSkipped"
+	// ResultCodeRecreated means that the resource was deleted and created again, as it couldn't be updated in place
+	ResultCodeRecreated ResultCode = "Recreated"
+)
+
+// ResourceResult is a result of a sync operation on a resource
+type ResourceResult struct {
+	Group     string     `json:"group,omitempty"`
+	Version   string     `json:"version"`
+	Kind      string     `json:"kind"`
+	Namespace string     `json:"namespace,omitempty"`
+	Name      string     `json:"name"`
+	Status    ResultCode `json:"status"`
+	Message   string     `json:"message,omitempty"`
+}
+
+// SyncOperationResult is a result of a sync operation
+type SyncOperationResult struct {
+	// Revision is the commit SHA of the source, which was synced
+	Revision string `json:"revision,omitempty"`
+	// Resources are the results of the resources which are applied or pruned by the operation
+	Resources []ResourceResult `json:"resources,omitempty"`
+}
+
+// OperationState is a state of the operation, which is running or finished last
+type OperationState struct {
+	// Operation is the operation being executed
+	Operation Operation `json:"operation"`
+	// Phase is a phase of the operation (Running/Terminating/Succeeded/Failed/Error)
+	Phase OperationPhase `json:"phase"`
+	// Message is a message of the phase
+	Message string `json:"message,omitempty"`
+	// SyncResult is a result of the sync operation
+	SyncResult *SyncOperationResult `json:"syncResult,omitempty"`
+	// StartedAt is the time when the operation started
+	StartedAt metav1.Time `json:"startedAt"`
+	// FinishedAt is the time when the operation finished
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// RetryCount is the number of the retries of the operation, which is 0 for the first attempt
+	RetryCount int64 `json:"retryCount,omitempty"`
+}
+
+// IsCompleted returns true if the operation is finished
+func (s *OperationState) IsCompleted() bool {
+	return s.Phase != OperationPhaseRunning && s.Phase != OperationPhaseTerminating
 }
 
 // ApplicationStatus defines the observed state of Application
 type ApplicationStatus struct {
 	// SyncStatus contains information about the application's current sync status
 	Sync SyncStatus `json:"sync,omitempty"`
+	// Health contains information about the application's current health status
+	Health HealthStatus `json:"health,omitempty"`
+	// Resources is a list of the application's resources, including the ones which require pruning
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// ObservedGeneration is the generation of the application, which is reconciled last
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ObservedSpecHash is the hash of the spec of the application, which is reconciled last. Unlike the generation,
+	// it's not changed by the operations requested to the application, so it detects the changes of the spec
+	ObservedSpecHash string `json:"observedSpecHash,omitempty"`
+	// ReconciledAt is the time when the application was compared with the live state last
+	ReconciledAt *metav1.Time `json:"reconciledAt,omitempty"`
+	// LastSyncedAt is the time when the application was synced last
+	LastSyncedAt *metav1.Time `json:"lastSyncedAt,omitempty"`
+	// SelfHealAttempts is the number of the consecutive self-heals. It's reset when the live state stops drifting
+	SelfHealAttempts int64 `json:"selfHealAttempts,omitempty"`
+	// LastSelfHealedAt is the time when the drift of the live state was reverted last
+	LastSelfHealedAt *metav1.Time `json:"lastSelfHealedAt,omitempty"`
+	// History is a list of the completed syncs, from the oldest to the newest
+	History []SyncHistory `json:"history,omitempty"`
+	// OperationState is a state of the operation, which is running or finished last
+	OperationState *OperationState `json:"operationState,omitempty"`
+	// OwnedNamespace is the destination namespace, which is created by the application with CreateNamespace=true.
+	// It's empty if the namespace already existed, as the namespace is not owned by the application then.
+	// The owned namespace is deleted with the resources of the application, unless its deletion policy is Orphan
+	OwnedNamespace string `json:"ownedNamespace,omitempty"`
+	// OrphanedResources are the resources in the destination namespaces, which are not tracked by any application.
+	// It's set only if spec.orphanedResources is set
+	OrphanedResources []OrphanedResource `json:"orphanedResources,omitempty"`
 	// Conditions of Application
 	Conditions []metav1.Condition `json:"conditions"`
 	Secrets    string             `json:"secrets,omitempty"`
@@ -97,6 +473,12 @@ type ApplicationStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Sync",type="string",JSONPath=".status.sync.status",description="Sync status of the application"
+//+kubebuilder:printcolumn:name="Health",type="string",JSONPath=".status.health.status",description="Health status of the application"
+//+kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.sync.revision",priority=1,description="Revision compared last"
+//+kubebuilder:printcolumn:name="Operation",type="string",JSONPath=".status.operationState.phase",priority=1,description="Phase of the last operation"
+//+kubebuilder:printcolumn:name="Last-Synced",type="date",JSONPath=".status.lastSyncedAt",description="Time when the application was synced last"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Application is the Schema for the applications API
 type Application struct {
@@ -105,6 +487,9 @@ type Application struct {
 
 	Spec   ApplicationSpec   `json:"spec,omitempty"`
 	Status ApplicationStatus `json:"status,omitempty"`
+
+	// Operation is an operation requested to the application. It's removed when the controller starts executing it
+	Operation *Operation `json:"operation,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -125,8 +510,8 @@ type ApplicationSource struct {
 	// In case of Git, this can be commit, tag, or branch. If omitted, will equal to HEAD.
 	// In case of Helm, this is a semver tag for the Chart's version.
 	TargetRevision string `json:"targetRevision,omitempty"`
-	// Type specifies the type of the application's source
-	// +kubebuilder:validation:Enum:=PlainYAML;Helm
+	// Type specifies the type of the application's source. PlainYAML and Helm are supported by default,
+	// and other types can be supported by registering their manifest managers
 	Type ApplicationSourceType `json:"type"`
 	// Helm holds helm specific options
 	Helm *ApplicationSourceHelm `json:"helm,omitempty"`
@@ -182,9 +567,14 @@ type ApplicationDestination struct {
 	Namespace string `json:"namespace,omitempty"`
 	// Name specifies the target cluster's name. Do not enter any value if you want to deploy in current context.
 	Name string `json:"name,omitempty"`
+	// ForceNamespace places the namespace-scoped resources in the target namespace, even if their manifests specify
+	// another namespace. Cluster-scoped resources are never placed in a namespace.
+	// It's ignored for helm applications, which are installed as they're rendered
+	ForceNamespace bool `json:"forceNamespace,omitempty"`
 }
 
 // ApplicationSourceType specifies the type of the application's source
+// +kubebuilder:validation:MinLength=1
 type ApplicationSourceType string
 
 const (
@@ -243,7 +633,128 @@ func (app *Application) GetWebhookServerAddress() string {
 	return fmt.Sprintf("http://%s/webhook/%s/%s", configs.CurrentExternalHostName, app.Namespace, app.Name)
 }
 
+// GetAutomatedSyncPolicy returns the automated sync policy. The deprecated autosync is converted into the policy
+func (app *Application) GetAutomatedSyncPolicy() AutomatedSyncPolicy {
+	if app.Spec.SyncPolicy.Automated != nil {
+		return *app.Spec.SyncPolicy.Automated
+	}
+	if app.Spec.SyncPolicy.AutoSync {
+		return AutomatedSyncPolicy{OnCommit: true, SelfHeal: true}
+	}
+	return AutomatedSyncPolicy{}
+}
+
+// IsAutoSyncEnabled returns true if any automated sync is enabled and it's not disabled temporarily (e.g., by a
+// rollback or a sync window)
+func (app *Application) IsAutoSyncEnabled() bool {
+	policy := app.GetAutomatedSyncPolicy()
+	return (policy.OnCommit || policy.SelfHeal) && !meta.IsStatusConditionTrue(app.Status.Conditions, ApplicationConditionAutoSyncDisabled) &&
+		!meta.IsStatusConditionTrue(app.Status.Conditions, ApplicationConditionSyncBlocked)
+}
+
+// GetHistory returns the sync history of the id. It returns nil if there is no such history
+func (app *Application) GetHistory(id int64) *SyncHistory {
+	for i := range app.Status.History {
+		if app.Status.History[i].ID == id {
+			return &app.Status.History[i]
+		}
+	}
+	return nil
+}
+
+// IsOperationInProgress returns true if an operation is requested or being executed
+func (app *Application) IsOperationInProgress() bool {
+	return app.Operation != nil || (app.Status.OperationState != nil && !app.Status.OperationState.IsCompleted())
+}
+
+// AnnotationSyncOptions is an annotation key for the sync options of each deployed resource.
+// Multiple options are separated by commas (e.g., cd.tmax.io/sync-options: Prune=false)
+const (
+	AnnotationSyncOptions = "cd.tmax.io/sync-options"
+)
+
+// Sync options which can be set with AnnotationSyncOptions
+const (
+	SyncOptionPruneDisabled = "Prune=false"
+	// SyncOptionAdopt adopts the live resource, which exists but is not managed by any application.
+	// It can be set in syncPolicy.syncOptions of the application as well, to adopt all of its resources
+	SyncOptionAdopt = "Adopt=true"
+	// SyncOptionReplace replaces the live resource with the manifest, instead of merging the manifest into it.
+	// The fields which are not in the manifest are removed from the resource
+	SyncOptionReplace = "Replace=true"
+	// SyncOptionRecreate deletes and creates the resource again, instead of updating it, when it's out of sync
+	SyncOptionRecreate = "Recreate=true"
+	// SyncOptionOrphanDependents orphans the dependents (e.g., pods) of the resource being recreated, so that they're
+	// adopted by the recreated resource. Otherwise, they're deleted in the foreground with the resource.
+	// It can be set in syncPolicy.syncOptions of the application as well
+	SyncOptionOrphanDependents = "OrphanDependents=true"
+)
+
+// Keys of the label and the annotation, which mark the applied objects with the applications managing them.
+// Which of them are set is configured by trackingMethod of the cd-config config map
+const (
+	// LabelAppInstance is a label key, whose value is <namespace>_<name> of the application. A value longer than 63
+	// characters is shortened with its hash
+	LabelAppInstance = "cd.tmax.io/app-instance"
+	// AnnotationTrackingID is an annotation key, whose value is <namespace>/<name> of the application
+	AnnotationTrackingID = "cd.tmax.io/tracking-id"
+)
+
+// Sync options which can be set in syncPolicy.syncOptions of the application
+const (
+	SyncOptionCreateNamespace = "CreateNamespace=true"
+	// SyncOptionRecreateOnImmutableError deletes and creates the resources again, whose manifests change immutable
+	// fields (e.g., selectors, the template of a job). Such changes are detected by the dry-run of the updates.
+	// Helm applications are upgraded by helm, so their resources are not recreated
+	SyncOptionRecreateOnImmutableError = "RecreateOnImmutableError=true"
+)
+
+// AnnotationNamespaceOwner is an annotation key of the namespace created by an application, whose value is
+// <namespace>/<name> of the application
+const (
+	AnnotationNamespaceOwner = "cd.tmax.io/namespace-owner"
+)
+
+// AnnotationDeletionProtection is an annotation key of the application. If it's true, the deletion of the application
+// is rejected by the validating webhook. If the application is deleted anyway, e.g., while the webhook is not
+// available, its finalization (the deletion of its resources) is delayed until the annotation is removed, and the
+// application stays terminating until then
+const (
+	AnnotationDeletionProtection = "cd.tmax.io/deletion-protection"
+)
+
+// IsDeletionProtected checks if the deletion of the application is blocked by AnnotationDeletionProtection
+func (app *Application) IsDeletionProtected() bool {
+	return app.Annotations[AnnotationDeletionProtection] == "true"
+}
+
+// GetDeletionPolicy returns the deletion policy of the application, which is Foreground by default
+func (app *Application) GetDeletionPolicy() DeletionPolicy {
+	if app.Spec.DeletionPolicy == "" {
+		return DeletionPolicyForeground
+	}
+	return app.Spec.DeletionPolicy
+}
+
+// HasSyncOption checks if the option is set in syncPolicy.syncOptions of the application
+func (app *Application) HasSyncOption(option string) bool {
+	for _, o := range app.Spec.SyncPolicy.SyncOptions {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// NamespaceOwnerKey returns the value of AnnotationNamespaceOwner, for the namespace owned by the application
+func (app *Application) NamespaceOwnerKey() string {
+	return app.Namespace + "/" + app.Name
+}
+
 // Approval API kinds
 const (
-	ApplicationAPISync = "sync"
+	ApplicationAPISync      = "sync"
+	ApplicationAPIDiff      = "diff"
+	ApplicationAPIRollback  = "rollback"
+	ApplicationAPITerminate = "terminate"
 )
@@ -8,11 +8,20 @@ func init() {
 	SchemeBuilder.Register(&DeployResource{}, &DeployResourceList{})
 }
 
-// DeployResourceStatuseSpec contains sync & health status of application's resource
-// type DeployResourceStatusSpec struct {
-// 	SyncStatus   SyncStatusCode   `json:"syncstatus"`
-// 	HealthStatus HealthStatusCode `json:"healthstatus"`
-// }
+// Label keys of the DeployResources. The values longer than 63 characters are shortened with their hashes
+const (
+	// DeployResourceLabelApplication is <name>-<namespace> of the application tracking the resource
+	DeployResourceLabelApplication = "cd.tmax.io/application"
+	// Group, kind, namespace and name of the tracked resource
+	DeployResourceLabelGroup     = "cd.tmax.io/resource-group"
+	DeployResourceLabelKind      = "cd.tmax.io/resource-kind"
+	DeployResourceLabelNamespace = "cd.tmax.io/resource-namespace"
+	DeployResourceLabelName      = "cd.tmax.io/resource-name"
+)
+
+// DeployResourceFinalizer keeps the DeployResource until the application stops tracking the resource, even if the
+// DeployResource is deleted by the garbage collector with its application
+const DeployResourceFinalizer = "cd.tmax.io/deploy-resource"
 
 // DeployResourceSpec is a spec of deployed application's resource
 type DeployResourceSpec struct {
@@ -23,15 +32,49 @@ type DeployResourceSpec struct {
 	Kind       string `json:"kind"`
 }
 
+// DeployResourceStatus contains sync & health status of application's resource
+type DeployResourceStatus struct {
+	// SyncStatus is a result of the last comparison of the resource with its manifest
+	SyncStatus SyncStatusCode `json:"syncStatus,omitempty"`
+
+	// HealthStatus is a health status of the live resource
+	HealthStatus HealthStatusCode `json:"healthStatus,omitempty"`
+
+	// HealthMessage is a human-readable message of the health status
+	HealthMessage string `json:"healthMessage,omitempty"`
+
+	// LastAppliedRevision is a revision of the source, which was applied to the resource last
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+
+	// LastAppliedTime is a time when the resource was applied last
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+
+	// LastApplyError is an error message of the last failed apply. It's cleared when the resource is applied successfully
+	LastApplyError string `json:"lastApplyError,omitempty"`
+
+	// DiffSummary summarizes the fields which differ between the live resource and its manifest
+	DiffSummary string `json:"diffSummary,omitempty"`
+}
+
 // +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
 
 // DeployResource is resource created by an application
 // +kubebuilder:resource:path=deployresources,scope=Namespaced,shortName=drs
+// +kubebuilder:printcolumn:name="Kind",type="string",JSONPath=".spec.kind",description="Kind of the resource"
+// +kubebuilder:printcolumn:name="Resource-Namespace",type="string",JSONPath=".spec.namespace",description="Namespace of the resource"
+// +kubebuilder:printcolumn:name="Resource-Name",type="string",JSONPath=".spec.name",description="Name of the resource"
+// +kubebuilder:printcolumn:name="Sync",type="string",JSONPath=".status.syncStatus",description="Sync status of the resource"
+// +kubebuilder:printcolumn:name="Health",type="string",JSONPath=".status.healthStatus",description="Health status of the resource"
+// +kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.lastAppliedRevision",priority=1,description="Last applied revision"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.lastApplyError",priority=1,description="Last apply error"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type DeployResource struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
-	Application       string             `json:"application"`
-	Spec              DeployResourceSpec `json:"spec"`
+	Application       string               `json:"application"`
+	Spec              DeployResourceSpec   `json:"spec"`
+	Status            DeployResourceStatus `json:"status,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -56,22 +99,22 @@ type DeployResourceList struct {
 // 	SyncStatusCodeOutOfSync SyncStatusCode = "OutOfSync"
 // )
 
-// // HealthStatusCode Represents resource health status
-// type HealthStatusCode string
+// HealthStatusCode Represents resource health status
+type HealthStatusCode string
 
-// const (
-// 	// HealthStatusUnknown indicates that health assessment failed and actual health status is unknown
-// 	HealthStatusUnknown HealthStatusCode = "Unknown"
-// 	// HealthStatusProgressing indicates that resource is not healthy but still have a chance to reach healthy state
-// 	HealthStatusProgressing HealthStatusCode = "Progressing"
-// 	// HealthStatusHealthy indicates that resource is 100% healthy
-// 	HealthStatusHealthy HealthStatusCode = "Healthy"
-// 	// HealthStatusSuspended is assigned to resources that are suspended or paused. The typical example is a
-// 	// [suspended](https://kubernetes.io/docs/tasks/job/automated-tasks-with-cron-jobs/#suspend) CronJob.
-// 	HealthStatusSuspended HealthStatusCode = "Suspended"
-// 	// HealthStatusDegraded status is used if resource status indicates failure or resource could not reach healthy state
-// 	// within some timeout.
-// 	HealthStatusDegraded HealthStatusCode = "Degraded"
-// 	// HealthStatusMissing indicates that resource is missing in the cluster.
-// 	HealthStatusMissing HealthStatusCode = "Missing"
-// )
+const (
+	// HealthStatusUnknown indicates that health assessment failed and actual health status is unknown
+	HealthStatusUnknown HealthStatusCode = "Unknown"
+	// HealthStatusProgressing indicates that resource is not healthy but still have a chance to reach healthy state
+	HealthStatusProgressing HealthStatusCode = "Progressing"
+	// HealthStatusHealthy indicates that resource is 100% healthy
+	HealthStatusHealthy HealthStatusCode = "Healthy"
+	// HealthStatusSuspended is assigned to resources that are suspended or paused. The typical example is a
+	// [suspended](https://kubernetes.io/docs/tasks/job/automated-tasks-with-cron-jobs/#suspend) CronJob.
+	HealthStatusSuspended HealthStatusCode = "Suspended"
+	// HealthStatusDegraded status is used if resource status indicates failure or resource could not reach healthy state
+	// within some timeout.
+	HealthStatusDegraded HealthStatusCode = "Degraded"
+	// HealthStatusMissing indicates that resource is missing in the cluster.
+	HealthStatusMissing HealthStatusCode = "Missing"
+)
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&SyncRequest{}, &SyncRequestList{})
+}
+
+// SyncRequestSpec defines the sync to be requested to the application
+type SyncRequestSpec struct {
+	// Application is a name of the application in the same namespace, to be synced
+	Application string `json:"application"`
+
+	// Revision overrides the target revision of the application. It can be a commit SHA
+	Revision string `json:"revision,omitempty"`
+
+	// Resources to be synced. If neither resources nor labelSelectors is set, all resources are synced
+	Resources []SyncOperationResource `json:"resources,omitempty"`
+
+	// LabelSelectors select the resources to be synced by their labels, in addition to the resources
+	LabelSelectors []metav1.LabelSelector `json:"labelSelectors,omitempty"`
+
+	// Prune overrides syncPolicy.prune of the application
+	Prune *bool `json:"prune,omitempty"`
+
+	// DryRun only compares the application with the live state, without applying anything
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// SyncRequestPhase is a phase of a SyncRequest
+type SyncRequestPhase string
+
+// SyncRequest phases
+const (
+	SyncRequestPhasePending   SyncRequestPhase = "Pending"
+	SyncRequestPhaseRunning   SyncRequestPhase = "Running"
+	SyncRequestPhaseSucceeded SyncRequestPhase = "Succeeded"
+	SyncRequestPhaseFailed    SyncRequestPhase = "Failed"
+	SyncRequestPhaseError     SyncRequestPhase = "Error"
+	// SyncRequestPhaseSuperseded means that the sync was merged into another sync of the application
+	SyncRequestPhaseSuperseded SyncRequestPhase = "Superseded"
+)
+
+// SyncRequestStatus is a status of the SyncRequest
+type SyncRequestStatus struct {
+	// Phase is a phase of the request (Pending/Running/Succeeded/Failed/Error)
+	Phase SyncRequestPhase `json:"phase,omitempty"`
+
+	// Message is a message of the phase
+	Message string `json:"message,omitempty"`
+
+	// RequestedAt is the time when the sync was requested to the application
+	RequestedAt *metav1.Time `json:"requestedAt,omitempty"`
+
+	// StartedAt is the time when the sync started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+
+	// FinishedAt is the time when the sync finished
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+
+	// Result is a result of the sync
+	Result *SyncOperationResult `json:"result,omitempty"`
+
+	// Logs of the sync
+	Logs []string `json:"logs,omitempty"`
+}
+
+// IsCompleted returns true if the request is finished
+func (s *SyncRequestStatus) IsCompleted() bool {
+	return s.Phase == SyncRequestPhaseSucceeded || s.Phase == SyncRequestPhaseFailed || s.Phase == SyncRequestPhaseError ||
+		s.Phase == SyncRequestPhaseSuperseded
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// SyncRequest is a request to sync an application once
+// +kubebuilder:resource:path=syncrequests,scope=Namespaced,shortName=sr
+// +kubebuilder:printcolumn:name="Application",type="string",JSONPath=".spec.application",description="Application to be synced"
+// +kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".status.result.revision",description="Revision synced"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Phase of the request"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",priority=1,description="Message of the phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type SyncRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SyncRequestSpec   `json:"spec"`
+	Status SyncRequestStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SyncRequestList contains the list of SyncRequests
+type SyncRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SyncRequest `json:"items"`
+}
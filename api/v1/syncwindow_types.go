@@ -0,0 +1,70 @@
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	SchemeBuilder.Register(&SyncWindowPolicy{}, &SyncWindowPolicyList{})
+}
+
+// SyncWindowKind is a kind of a sync window
+type SyncWindowKind string
+
+// Sync window kinds
+const (
+	// SyncWindowKindAllow allows syncs only in the window
+	SyncWindowKindAllow SyncWindowKind = "allow"
+	// SyncWindowKindDeny denies syncs in the window
+	SyncWindowKindDeny SyncWindowKind = "deny"
+)
+
+// SyncWindow is a time window, in which syncs are allowed or denied
+type SyncWindow struct {
+	// Kind is allow or deny
+	// +kubebuilder:validation:Enum=allow;deny
+	Kind SyncWindowKind `json:"kind"`
+
+	// Schedule is a cron schedule when the window starts, e.g., "0 22 * * *"
+	Schedule string `json:"schedule"`
+
+	// Duration is a duration of the window, e.g., 1h
+	Duration string `json:"duration"`
+
+	// TimeZone of the schedule, e.g., Asia/Seoul. Default is UTC
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// BlockManualSync applies the window to manual syncs (the api, SyncRequests) as well.
+	// Otherwise, only automated syncs (autosync, webhooks) are blocked
+	BlockManualSync bool `json:"blockManualSync,omitempty"`
+}
+
+// SyncWindowPolicySpec defines the sync windows of the applications selected by the selector
+type SyncWindowPolicySpec struct {
+	// Selector selects the applications in the same namespace, to which the windows are applied
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Windows are the sync windows
+	Windows []SyncWindow `json:"windows"`
+}
+
+// +kubebuilder:object:root=true
+
+// SyncWindowPolicy is a set of sync windows shared by the applications in a namespace
+// +kubebuilder:resource:path=syncwindowpolicies,scope=Namespaced,shortName=swp
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type SyncWindowPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SyncWindowPolicySpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// SyncWindowPolicyList contains the list of SyncWindowPolicies
+type SyncWindowPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SyncWindowPolicy `json:"items"`
+}
@@ -33,6 +33,11 @@ func (in *Application) DeepCopyInto(out *Application) {
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
+	if in.Operation != nil {
+		in, out := &in.Operation, &out.Operation
+		*out = new(Operation)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Application.
@@ -145,7 +150,12 @@ func (in *ApplicationSpec) DeepCopyInto(out *ApplicationSpec) {
 	*out = *in
 	in.Source.DeepCopyInto(&out.Source)
 	out.Destination = in.Destination
-	out.SyncPolicy = in.SyncPolicy
+	in.SyncPolicy.DeepCopyInto(&out.SyncPolicy)
+	if in.OrphanedResources != nil {
+		in, out := &in.OrphanedResources, &out.OrphanedResources
+		*out = new(OrphanedResourcesMonitorSettings)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplicationSpec.
@@ -161,7 +171,44 @@ func (in *ApplicationSpec) DeepCopy() *ApplicationSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ApplicationStatus) DeepCopyInto(out *ApplicationStatus) {
 	*out = *in
-	out.Sync = in.Sync
+	in.Sync.DeepCopyInto(&out.Sync)
+	out.Health = in.Health
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ReconciledAt != nil {
+		in, out := &in.ReconciledAt, &out.ReconciledAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSyncedAt != nil {
+		in, out := &in.LastSyncedAt, &out.LastSyncedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSelfHealedAt != nil {
+		in, out := &in.LastSelfHealedAt, &out.LastSelfHealedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.History != nil {
+		in, out := &in.History, &out.History
+		*out = make([]SyncHistory, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OperationState != nil {
+		in, out := &in.OperationState, &out.OperationState
+		*out = new(OperationState)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OrphanedResources != nil {
+		in, out := &in.OrphanedResources, &out.OrphanedResources
+		*out = make([]OrphanedResource, len(*in))
+		copy(*out, *in)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -181,12 +228,70 @@ func (in *ApplicationStatus) DeepCopy() *ApplicationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutomatedSyncPolicy) DeepCopyInto(out *AutomatedSyncPolicy) {
+	*out = *in
+	if in.SelfHealBackoff != nil {
+		in, out := &in.SelfHealBackoff, &out.SelfHealBackoff
+		*out = new(Backoff)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutomatedSyncPolicy.
+func (in *AutomatedSyncPolicy) DeepCopy() *AutomatedSyncPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AutomatedSyncPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Backoff) DeepCopyInto(out *Backoff) {
+	*out = *in
+	if in.Factor != nil {
+		in, out := &in.Factor, &out.Factor
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Backoff.
+func (in *Backoff) DeepCopy() *Backoff {
+	if in == nil {
+		return nil
+	}
+	out := new(Backoff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComparedTo) DeepCopyInto(out *ComparedTo) {
+	*out = *in
+	in.Source.DeepCopyInto(&out.Source)
+	out.Destination = in.Destination
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComparedTo.
+func (in *ComparedTo) DeepCopy() *ComparedTo {
+	if in == nil {
+		return nil
+	}
+	out := new(ComparedTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeployResource) DeepCopyInto(out *DeployResource) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeployResource.
@@ -254,6 +359,25 @@ func (in *DeployResourceSpec) DeepCopy() *DeployResourceSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeployResourceStatus) DeepCopyInto(out *DeployResourceStatus) {
+	*out = *in
+	if in.LastAppliedTime != nil {
+		in, out := &in.LastAppliedTime, &out.LastAppliedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeployResourceStatus.
+func (in *DeployResourceStatus) DeepCopy() *DeployResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DeployResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitConfig) DeepCopyInto(out *GitConfig) {
 	*out = *in
@@ -310,9 +434,346 @@ func (in *GitTokenFrom) DeepCopy() *GitTokenFrom {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthStatus) DeepCopyInto(out *HealthStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthStatus.
+func (in *HealthStatus) DeepCopy() *HealthStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagedNamespaceMetadata) DeepCopyInto(out *ManagedNamespaceMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagedNamespaceMetadata.
+func (in *ManagedNamespaceMetadata) DeepCopy() *ManagedNamespaceMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagedNamespaceMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Operation) DeepCopyInto(out *Operation) {
+	*out = *in
+	if in.Sync != nil {
+		in, out := &in.Sync, &out.Sync
+		*out = new(SyncOperation)
+		(*in).DeepCopyInto(*out)
+	}
+	out.InitiatedBy = in.InitiatedBy
+	if in.Coalesced != nil {
+		in, out := &in.Coalesced, &out.Coalesced
+		*out = make([]SyncInitiator, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Operation.
+func (in *Operation) DeepCopy() *Operation {
+	if in == nil {
+		return nil
+	}
+	out := new(Operation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperationState) DeepCopyInto(out *OperationState) {
+	*out = *in
+	in.Operation.DeepCopyInto(&out.Operation)
+	if in.SyncResult != nil {
+		in, out := &in.SyncResult, &out.SyncResult
+		*out = new(SyncOperationResult)
+		(*in).DeepCopyInto(*out)
+	}
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	if in.FinishedAt != nil {
+		in, out := &in.FinishedAt, &out.FinishedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperationState.
+func (in *OperationState) DeepCopy() *OperationState {
+	if in == nil {
+		return nil
+	}
+	out := new(OperationState)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanedResource) DeepCopyInto(out *OrphanedResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanedResource.
+func (in *OrphanedResource) DeepCopy() *OrphanedResource {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanedResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanedResourceKey) DeepCopyInto(out *OrphanedResourceKey) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanedResourceKey.
+func (in *OrphanedResourceKey) DeepCopy() *OrphanedResourceKey {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanedResourceKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OrphanedResourcesMonitorSettings) DeepCopyInto(out *OrphanedResourcesMonitorSettings) {
+	*out = *in
+	if in.Warn != nil {
+		in, out := &in.Warn, &out.Warn
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Ignore != nil {
+		in, out := &in.Ignore, &out.Ignore
+		*out = make([]OrphanedResourceKey, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OrphanedResourcesMonitorSettings.
+func (in *OrphanedResourcesMonitorSettings) DeepCopy() *OrphanedResourcesMonitorSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(OrphanedResourcesMonitorSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceResult) DeepCopyInto(out *ResourceResult) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceResult.
+func (in *ResourceResult) DeepCopy() *ResourceResult {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceStatus) DeepCopyInto(out *ResourceStatus) {
+	*out = *in
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(HealthStatus)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceStatus.
+func (in *ResourceStatus) DeepCopy() *ResourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RetryStrategy) DeepCopyInto(out *RetryStrategy) {
+	*out = *in
+	if in.Backoff != nil {
+		in, out := &in.Backoff, &out.Backoff
+		*out = new(Backoff)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RetryStrategy.
+func (in *RetryStrategy) DeepCopy() *RetryStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RetryStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncHistory) DeepCopyInto(out *SyncHistory) {
+	*out = *in
+	in.Source.DeepCopyInto(&out.Source)
+	in.StartedAt.DeepCopyInto(&out.StartedAt)
+	in.FinishedAt.DeepCopyInto(&out.FinishedAt)
+	out.InitiatedBy = in.InitiatedBy
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncHistory.
+func (in *SyncHistory) DeepCopy() *SyncHistory {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncHistory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncInitiator) DeepCopyInto(out *SyncInitiator) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncInitiator.
+func (in *SyncInitiator) DeepCopy() *SyncInitiator {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncInitiator)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncOperation) DeepCopyInto(out *SyncOperation) {
+	*out = *in
+	if in.RollbackTo != nil {
+		in, out := &in.RollbackTo, &out.RollbackTo
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]SyncOperationResource, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelSelectors != nil {
+		in, out := &in.LabelSelectors, &out.LabelSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Prune != nil {
+		in, out := &in.Prune, &out.Prune
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncOperation.
+func (in *SyncOperation) DeepCopy() *SyncOperation {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncOperation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncOperationResource) DeepCopyInto(out *SyncOperationResource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncOperationResource.
+func (in *SyncOperationResource) DeepCopy() *SyncOperationResource {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncOperationResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncOperationResult) DeepCopyInto(out *SyncOperationResult) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]ResourceResult, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncOperationResult.
+func (in *SyncOperationResult) DeepCopy() *SyncOperationResult {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncOperationResult)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SyncPolicy) DeepCopyInto(out *SyncPolicy) {
 	*out = *in
+	if in.Automated != nil {
+		in, out := &in.Automated, &out.Automated
+		*out = new(AutomatedSyncPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SyncWindows != nil {
+		in, out := &in.SyncWindows, &out.SyncWindows
+		*out = make([]SyncWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(RetryStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SyncOptions != nil {
+		in, out := &in.SyncOptions, &out.SyncOptions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ManagedNamespaceMetadata != nil {
+		in, out := &in.ManagedNamespaceMetadata, &out.ManagedNamespaceMetadata
+		*out = new(ManagedNamespaceMetadata)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncPolicy.
@@ -325,9 +786,138 @@ func (in *SyncPolicy) DeepCopy() *SyncPolicy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncRequest) DeepCopyInto(out *SyncRequest) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncRequest.
+func (in *SyncRequest) DeepCopy() *SyncRequest {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SyncRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncRequestList) DeepCopyInto(out *SyncRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SyncRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncRequestList.
+func (in *SyncRequestList) DeepCopy() *SyncRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SyncRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncRequestSpec) DeepCopyInto(out *SyncRequestSpec) {
+	*out = *in
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]SyncOperationResource, len(*in))
+		copy(*out, *in)
+	}
+	if in.LabelSelectors != nil {
+		in, out := &in.LabelSelectors, &out.LabelSelectors
+		*out = make([]metav1.LabelSelector, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Prune != nil {
+		in, out := &in.Prune, &out.Prune
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncRequestSpec.
+func (in *SyncRequestSpec) DeepCopy() *SyncRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncRequestStatus) DeepCopyInto(out *SyncRequestStatus) {
+	*out = *in
+	if in.RequestedAt != nil {
+		in, out := &in.RequestedAt, &out.RequestedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.StartedAt != nil {
+		in, out := &in.StartedAt, &out.StartedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.FinishedAt != nil {
+		in, out := &in.FinishedAt, &out.FinishedAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Result != nil {
+		in, out := &in.Result, &out.Result
+		*out = new(SyncOperationResult)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logs != nil {
+		in, out := &in.Logs, &out.Logs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncRequestStatus.
+func (in *SyncRequestStatus) DeepCopy() *SyncRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SyncStatus) DeepCopyInto(out *SyncStatus) {
 	*out = *in
+	in.ComparedTo.DeepCopyInto(&out.ComparedTo)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncStatus.
@@ -339,3 +929,97 @@ func (in *SyncStatus) DeepCopy() *SyncStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncWindow) DeepCopyInto(out *SyncWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncWindow.
+func (in *SyncWindow) DeepCopy() *SyncWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncWindowPolicy) DeepCopyInto(out *SyncWindowPolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncWindowPolicy.
+func (in *SyncWindowPolicy) DeepCopy() *SyncWindowPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncWindowPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SyncWindowPolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncWindowPolicyList) DeepCopyInto(out *SyncWindowPolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SyncWindowPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncWindowPolicyList.
+func (in *SyncWindowPolicyList) DeepCopy() *SyncWindowPolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncWindowPolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SyncWindowPolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SyncWindowPolicySpec) DeepCopyInto(out *SyncWindowPolicySpec) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]SyncWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SyncWindowPolicySpec.
+func (in *SyncWindowPolicySpec) DeepCopy() *SyncWindowPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SyncWindowPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
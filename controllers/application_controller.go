@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"os"
 	"time"
@@ -28,17 +29,18 @@ import (
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
 	"github.com/tmax-cloud/cd-operator/internal/utils"
 	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
 	"github.com/tmax-cloud/cd-operator/pkg/sync"
-
-	corev1 "k8s.io/api/core/v1"
 )
 
 // ApplicationReconciler reconciles a Application object
@@ -46,23 +48,40 @@ type ApplicationReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the maximum number of applications which are reconciled concurrently.
+	// An application is never reconciled concurrently
+	MaxConcurrentReconciles int
 }
 
 const (
 	finalizer = "cd.tmax.io/finalizer"
-)
 
-var (
-	syncStopFlags map[string]chan bool    = make(map[string]chan bool)
-	syncTickers   map[string]*time.Ticker = make(map[string]*time.Ticker)
-	syncPeriods   map[string]int64        = make(map[string]int64)
+	// statusPatchTimeout is the timeout to patch the status after a reconciliation, which may outlive its context
+	statusPatchTimeout = 10 * time.Second
+
+	// deletionCheckPeriod is the period to check the resources, which are deleted in the foreground
+	deletionCheckPeriod = 5 * time.Second
 )
 
 //+kubebuilder:rbac:groups=cd.tmax.io,resources=applications,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cd.tmax.io,resources=applications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=applications/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=deployresources,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=deployresources/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=syncwindowpolicies,verbs=get;list;watch
 //+kubebuilder:rbac:groups=cdapi.tmax.io,resources=applications/sync,verbs=update
+//+kubebuilder:rbac:groups=cdapi.tmax.io,resources=applications/diff,verbs=get
+//+kubebuilder:rbac:groups=cdapi.tmax.io,resources=applications/rollback,verbs=update
+//+kubebuilder:rbac:groups=cdapi.tmax.io,resources=applications/terminate,verbs=update
 //+kubebuilder:rbac:groups="",resources=secrets;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps;persistentvolumeclaims,verbs=list
+//+kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=list
+//+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=list
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=list
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=list
 
 // Reconcile reconciles Application
 func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -92,23 +111,60 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 
 	defer func() {
+		// The state of an operation cancelled by the shutdown of the manager is recorded as well
+		patchCtx, cancel := context.WithTimeout(context.Background(), statusPatchTimeout)
+		defer cancel()
 		p := client.MergeFrom(original)
-		if err := r.Client.Status().Patch(ctx, instance, p); err != nil {
+		if err := r.Client.Status().Patch(patchCtx, instance, p); err != nil {
 			log.Error(err, "")
 		}
 	}()
 
 	// Set webhook registered
-	r.setWebhookRegisteredCond(instance)
+	r.setWebhookRegisteredCond(ctx, instance)
 
 	// Set ready
 	r.setReadyCond(instance)
 
-	if cond.Status == metav1.ConditionTrue {
-		r.manageSyncRoutine(instance)
-		if err := sync.CheckSync(r.Client, instance, false); err != nil {
+	// Autosync disabled by a rollback is enabled again, when the spec is changed
+	if sync.IsSpecChanged(instance) {
+		sync.EnableAutoSync(instance)
+	}
+
+	// Operations are executed only in the reconciliation, so the one in progress was interrupted
+	sync.AbortInterruptedOperation(instance)
+
+	result := ctrl.Result{}
+	if cond.Status == metav1.ConditionTrue && instance.DeletionTimestamp == nil {
+		// Execute the requested operation, retry the failed one, or compare the application with the live state
+		var err error
+		switch {
+		case instance.Operation != nil:
+			err = sync.RunOperation(ctx, r.Client, instance, *instance.Operation)
+		case !sync.IsSpecChanged(instance) && sync.IsRetryPending(instance):
+			if delay := sync.RetryDelay(instance); delay > 0 {
+				result.RequeueAfter = delay
+			} else {
+				err = sync.RetryOperation(ctx, r.Client, instance)
+			}
+		default:
+			op := cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeAutoSync}}
+			err = sync.RunOperation(ctx, r.Client, instance, op)
+		}
+		if err != nil {
 			log.Error(err, "")
-			return ctrl.Result{}, err
+			// Without the retry strategy, the failed operation is retried by the controller's rate limiter
+			if instance.Spec.SyncPolicy.Retry == nil {
+				return ctrl.Result{}, err
+			}
+			if sync.IsRetryPending(instance) {
+				result = ctrl.Result{Requeue: true, RequeueAfter: sync.RetryDelay(instance)}
+			}
+		}
+
+		// Check the sync periodically, with the latest application fetched by the next reconciliation
+		if delay := sync.SyncCheckDelay(instance); result.RequeueAfter <= 0 || delay < result.RequeueAfter {
+			result.RequeueAfter = delay
 		}
 	}
 
@@ -116,18 +172,23 @@ func (r *ApplicationReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, err
 	}
 
-	if err := r.handleFinalizer(instance); err != nil {
+	if instance.DeletionTimestamp != nil {
+		instance.Status.ObservedGeneration = instance.Generation
+		instance.Status.ObservedSpecHash = sync.SpecHash(instance)
+		return r.handleDeletion(ctx, instance)
+	}
+
+	if err := r.handleFinalizer(ctx, instance); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	instance.Status.ObservedGeneration = instance.Generation
+	instance.Status.ObservedSpecHash = sync.SpecHash(instance)
+
+	return result, nil
 }
 
 func (r *ApplicationReconciler) setDefaultValues(instance *cdv1.Application) error {
-	if err := r.checkAppDestNamespace(instance); err != nil {
-		return err
-	}
-
 	if instance.Status.Sync.Status == "" {
 		sync.SetDefaultSyncStatus(instance)
 	}
@@ -142,39 +203,60 @@ func (r *ApplicationReconciler) setDefaultValues(instance *cdv1.Application) err
 	return nil
 }
 
-func (r *ApplicationReconciler) handleFinalizer(instance *cdv1.Application) error {
-	isAppMarkedToBeDeleted := instance.DeletionTimestamp != nil
-	if isAppMarkedToBeDeleted {
-		if controllerutil.ContainsFinalizer(instance, finalizer) {
-			if err := r.finalizeApp(instance); err != nil {
-				return err
-			}
-		}
-		controllerutil.RemoveFinalizer(instance, finalizer)
-		if err := r.Update(context.Background(), instance); err != nil {
-			return err
-		}
-		return nil
-	}
-
+func (r *ApplicationReconciler) handleFinalizer(ctx context.Context, instance *cdv1.Application) error {
 	if !controllerutil.ContainsFinalizer(instance, finalizer) {
+		original := instance.DeepCopy()
 		controllerutil.AddFinalizer(instance, finalizer)
-		if err := r.Update(context.Background(), instance); err != nil {
+		// Patch a copy, not to overwrite the status which is not patched yet.
+		// The status may have been patched while running an operation, so the resource version is outdated
+		if err := r.Patch(ctx, instance.DeepCopy(), client.MergeFrom(original)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *ApplicationReconciler) finalizeApp(instance *cdv1.Application) error {
-	deleteSyncFlag(instance)
+// handleDeletion finalizes the application which is being deleted, following its deletion policy. The deletion of the
+// protected application is rejected by the validating webhook. If it's deleted anyway, e.g., while the webhook is not
+// available, its finalization is delayed until the protection is removed, and it stays terminating until then
+func (r *ApplicationReconciler) handleDeletion(ctx context.Context, instance *cdv1.Application) (ctrl.Result, error) {
+	if controllerutil.ContainsFinalizer(instance, finalizer) {
+		if instance.IsDeletionProtected() {
+			meta.SetStatusCondition(&instance.Status.Conditions, metav1.Condition{
+				Type:    cdv1.ApplicationConditionDeletionBlocked,
+				Status:  metav1.ConditionTrue,
+				Reason:  "DeletionProtected",
+				Message: fmt.Sprintf("Deletion is blocked by annotation %s. Remove the annotation to delete the application", cdv1.AnnotationDeletionProtection),
+			})
+			return ctrl.Result{}, nil
+		}
+		meta.RemoveStatusCondition(&instance.Status.Conditions, cdv1.ApplicationConditionDeletionBlocked)
+
+		if err := r.finalizeApp(ctx, instance); err != nil {
+			if stderrors.Is(err, manifestmanager.ErrDeletionInProgress) {
+				r.Log.Info(fmt.Sprintf("Waiting for the resources of application %s/%s to be deleted: %s", instance.Namespace, instance.Name, err.Error()))
+				return ctrl.Result{RequeueAfter: deletionCheckPeriod}, nil
+			}
+			return ctrl.Result{}, err
+		}
+	}
+
+	controllerutil.RemoveFinalizer(instance, finalizer)
+	if err := r.Update(ctx, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
 
-	if err := r.clearDeployedResources(instance); err != nil {
-		r.Log.Error(err, "Delete deployed resources failed..")
+func (r *ApplicationReconciler) finalizeApp(ctx context.Context, instance *cdv1.Application) error {
+	if err := r.clearDeployedResources(ctx, instance); err != nil {
+		if !stderrors.Is(err, manifestmanager.ErrDeletionInProgress) {
+			r.Log.Error(err, "Delete deployed resources failed..")
+		}
 		return err
 	}
 
-	if err := r.clearWebhook(instance); err != nil {
+	if err := r.clearWebhook(ctx, instance); err != nil {
 		r.Log.Error(err, "Delete webhook failed..")
 		return err
 	}
@@ -189,39 +271,24 @@ func (r *ApplicationReconciler) finalizeApp(instance *cdv1.Application) error {
 }
 
 // TODO: Namespace 처리 방안
-func (r *ApplicationReconciler) clearDeployedResources(instance *cdv1.Application) error {
-	var mgr manifestmanager.ManifestManager
-	switch instance.Spec.Source.Type {
-	case cdv1.ApplicationSourceTypePlainYAML:
-		mgr = sync.PlainYamlManager
-	case cdv1.ApplicationSourceTypeHelm:
-		mgr = sync.HelmManager
-	default:
-		err := fmt.Errorf("get sync manager failed")
-		return err
-	}
-
-	if err := mgr.Clear(instance); err != nil {
-		return err
-	}
-
-	return nil
+func (r *ApplicationReconciler) clearDeployedResources(ctx context.Context, instance *cdv1.Application) error {
+	return sync.Clear(ctx, r.Client, instance)
 }
 
-func (r *ApplicationReconciler) clearWebhook(instance *cdv1.Application) error {
+func (r *ApplicationReconciler) clearWebhook(ctx context.Context, instance *cdv1.Application) error {
 	if instance.Spec.Source.Token != nil {
 		gitCli, err := utils.GetGitCli(instance, r.Client)
 		if err != nil {
 			return err
 		}
-		hookList, err := gitCli.ListWebhook()
+		hookList, err := gitCli.ListWebhook(ctx)
 		if err != nil {
 			return err
 		}
 		for _, h := range hookList {
 			if h.URL == instance.GetWebhookServerAddress() {
 				r.Log.Info("Deleting webhook " + h.URL)
-				if err := gitCli.DeleteWebhook(h.ID); err != nil {
+				if err := gitCli.DeleteWebhook(ctx, h.ID); err != nil {
 					return err
 				}
 			}
@@ -238,52 +305,6 @@ func (r *ApplicationReconciler) clearGitRepo(instance *cdv1.Application) error {
 	return nil
 }
 
-func (r *ApplicationReconciler) checkAppDestNamespace(instance *cdv1.Application) error {
-	if err := r.Client.Get(context.Background(), types.NamespacedName{Name: instance.Spec.Destination.Namespace}, &corev1.Namespace{}); err != nil {
-		if !errors.IsNotFound(err) {
-			return err
-		}
-		if err := r.Client.Create(context.Background(), &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: instance.Spec.Destination.Namespace}}); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (r *ApplicationReconciler) manageSyncRoutine(instance *cdv1.Application) {
-	instance.Status.Sync.Status = cdv1.SyncStatusCodeUnknown
-
-	deleteSyncFlag(instance)
-	done, ticker := registerSyncFlag(instance)
-	go sync.PeriodicSyncCheck(r.Client, instance, done, ticker)
-}
-
-func registerSyncFlag(instance *cdv1.Application) (chan bool, *time.Ticker) {
-	appKeyName := instance.Name + "/" + instance.Namespace
-
-	done := make(chan bool)
-	syncStopFlags[appKeyName] = done
-
-	ticker := time.NewTicker(time.Second * time.Duration(instance.Spec.SyncPolicy.SyncCheckPeriod))
-	syncTickers[appKeyName] = ticker
-
-	syncPeriods[appKeyName] = instance.Spec.SyncPolicy.SyncCheckPeriod
-
-	return done, ticker
-}
-
-func deleteSyncFlag(instance *cdv1.Application) {
-	appKeyName := instance.Name + "/" + instance.Namespace
-
-	if syncStopFlags[appKeyName] != nil && syncTickers[appKeyName] != nil {
-		syncStopFlags[appKeyName] <- true
-		syncTickers[appKeyName].Stop()
-	}
-	delete(syncStopFlags, appKeyName)
-	delete(syncTickers, appKeyName)
-	delete(syncPeriods, appKeyName)
-}
-
 // Set status.secrets, return if it's changed or not
 func (r *ApplicationReconciler) setSecretString(instance *cdv1.Application) {
 	if instance.Status.Secrets == "" {
@@ -305,13 +326,35 @@ func (r *ApplicationReconciler) setReadyCond(instance *cdv1.Application) {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ApplicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Status updates (e.g., status.reconciledAt) should not trigger another reconciliation
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&cdv1.Application{}).
+		For(&cdv1.Application{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, operationRequestedPredicate(), deletingPredicate()))).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		Complete(r)
 }
 
+// operationRequestedPredicate passes the events of the applications, to which operations are requested
+func operationRequestedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			app, ok := e.ObjectNew.(*cdv1.Application)
+			return ok && app.Operation != nil
+		},
+	}
+}
+
+// deletingPredicate passes the events of the applications being deleted, e.g., when their deletion protection is
+// removed
+func deletingPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return e.ObjectNew.GetDeletionTimestamp() != nil
+		},
+	}
+}
+
 // Set webhook-registered condition, return if it's changed or not
-func (r *ApplicationReconciler) setWebhookRegisteredCond(instance *cdv1.Application) {
+func (r *ApplicationReconciler) setWebhookRegisteredCond(ctx context.Context, instance *cdv1.Application) {
 	webhookRegistered := meta.FindStatusCondition(instance.Status.Conditions, cdv1.ApplicationConditionWebhookRegistered)
 	if webhookRegistered == nil {
 		webhookRegistered = &metav1.Condition{
@@ -344,7 +387,7 @@ func (r *ApplicationReconciler) setWebhookRegisteredCond(instance *cdv1.Applicat
 			addr := instance.GetWebhookServerAddress()
 			isUnique := true
 			r.Log.Info("Registering webhook " + addr)
-			entries, err := gitCli.ListWebhook()
+			entries, err := gitCli.ListWebhook(ctx)
 			if err != nil {
 				webhookRegistered.Reason = "webhookRegisterFailed"
 				webhookRegistered.Message = err.Error()
@@ -358,7 +401,7 @@ func (r *ApplicationReconciler) setWebhookRegisteredCond(instance *cdv1.Applicat
 				}
 			}
 			if isUnique {
-				if err := gitCli.RegisterWebhook(addr); err != nil {
+				if err := gitCli.RegisterWebhook(ctx, addr); err != nil {
 					webhookRegistered.Reason = "webhookRegisterFailed"
 					webhookRegistered.Message = err.Error()
 				} else {
@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+)
+
+const testSourceType cdv1.ApplicationSourceType = "Test"
+
+// testManifestManager records the options of the syncs, and fails them with err
+type testManifestManager struct {
+	manifestmanager.ManifestManager
+	syncs *[]manifestmanager.SyncOptions
+	err   error
+}
+
+func (m *testManifestManager) Sync(_ context.Context, _ *cdv1.Application, opts manifestmanager.SyncOptions) error {
+	*m.syncs = append(*m.syncs, opts)
+	return m.err
+}
+
+func registerTestManifestManager(t *testing.T, err error) *[]manifestmanager.SyncOptions {
+	syncs := &[]manifestmanager.SyncOptions{}
+	manifestmanager.Register(testSourceType, func(_ context.Context, _ client.Client, _ *cdv1.Application) (manifestmanager.ManifestManager, error) {
+		return &testManifestManager{syncs: syncs, err: err}, nil
+	})
+	t.Cleanup(func() {
+		manifestmanager.Register(testSourceType, func(_ context.Context, _ client.Client, _ *cdv1.Application) (manifestmanager.ManifestManager, error) {
+			return nil, fmt.Errorf("source type %q is not supported", testSourceType)
+		})
+	})
+	return syncs
+}
+
+// generationClient bumps the generation of the applications when anything but their metadata and status is changed,
+// as the API server does for the custom resources with the status subresource
+type generationClient struct {
+	client.Client
+}
+
+func (c *generationClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	before, err := c.getApplication(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	return c.bumpGeneration(ctx, before, obj)
+}
+
+func (c *generationClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	before, err := c.getApplication(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	return c.bumpGeneration(ctx, before, obj)
+}
+
+func (c *generationClient) getApplication(ctx context.Context, obj client.Object) (*cdv1.Application, error) {
+	if _, ok := obj.(*cdv1.Application); !ok {
+		return nil, nil
+	}
+	app := &cdv1.Application{}
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func (c *generationClient) bumpGeneration(ctx context.Context, before *cdv1.Application, obj client.Object) error {
+	app, ok := obj.(*cdv1.Application)
+	if !ok || before == nil {
+		return nil
+	}
+	if equality.Semantic.DeepEqual(before.Spec, app.Spec) && equality.Semantic.DeepEqual(before.Operation, app.Operation) {
+		return nil
+	}
+	app.Generation = before.Generation + 1
+	return c.Client.Update(ctx, app)
+}
+
+func newReconcileTestApp(op *cdv1.Operation) *cdv1.Application {
+	source := cdv1.ApplicationSource{Type: testSourceType, RepoURL: "https://github.com/tmax-cloud/cd-example-apps", TargetRevision: "main"}
+	return &cdv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default", Generation: 1},
+		Spec: cdv1.ApplicationSpec{
+			Source:     source,
+			SyncPolicy: cdv1.SyncPolicy{Automated: &cdv1.AutomatedSyncPolicy{OnCommit: true}},
+		},
+		Operation: op,
+		Status: cdv1.ApplicationStatus{
+			Secrets:            "secret",
+			ObservedGeneration: 1,
+			Conditions: []metav1.Condition{
+				{Type: cdv1.ApplicationConditionReady, Status: metav1.ConditionTrue, Reason: "Ready"},
+				{Type: cdv1.ApplicationConditionWebhookRegistered, Status: metav1.ConditionFalse, Reason: cdv1.ApplicationConditionReasonNoGitToken},
+			},
+			History: []cdv1.SyncHistory{
+				{ID: 1, Revision: "abcdef", Source: source, Result: cdv1.SyncResultSucceeded},
+			},
+		},
+	}
+}
+
+func newTestReconciler(app *cdv1.Application) *ApplicationReconciler {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(app).Build()
+	return &ApplicationReconciler{
+		Client: &generationClient{Client: cli},
+		Log:    ctrl.Log.WithName("test"),
+		Scheme: s,
+	}
+}
+
+func reconcileTestApp(t *testing.T, r *ApplicationReconciler, app *cdv1.Application) *cdv1.Application {
+	key := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	_, _ = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+
+	result := &cdv1.Application{}
+	require.NoError(t, r.Get(context.Background(), key, result))
+	return result
+}
+
+func TestReconcileRollback(t *testing.T) {
+	syncs := registerTestManifestManager(t, nil)
+
+	id := int64(1)
+	app := newReconcileTestApp(&cdv1.Operation{
+		Sync:        &cdv1.SyncOperation{RollbackTo: &id},
+		InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeUser},
+	})
+	r := newTestReconciler(app)
+
+	// The rollback disables autosync
+	result := reconcileTestApp(t, r, app)
+	require.Nil(t, result.Operation)
+	require.Equal(t, int64(2), result.Generation)
+	require.Equal(t, cdv1.OperationPhaseSucceeded, result.Status.OperationState.Phase)
+	require.True(t, meta.IsStatusConditionTrue(result.Status.Conditions, cdv1.ApplicationConditionAutoSyncDisabled))
+
+	// The reconciliation triggered by clearing the operation keeps autosync disabled
+	result = reconcileTestApp(t, r, app)
+	require.True(t, meta.IsStatusConditionTrue(result.Status.Conditions, cdv1.ApplicationConditionAutoSyncDisabled))
+	require.Len(t, *syncs, 2)
+	require.False(t, (*syncs)[1].Forced)
+	require.False(t, (*syncs)[1].AutoSync.OnCommit)
+
+	// Changing the spec enables autosync again
+	result.Spec.Source.TargetRevision = "release"
+	require.NoError(t, r.Update(context.Background(), result))
+	result = reconcileTestApp(t, r, app)
+	require.Nil(t, meta.FindStatusCondition(result.Status.Conditions, cdv1.ApplicationConditionAutoSyncDisabled))
+	require.Len(t, *syncs, 3)
+	require.True(t, (*syncs)[2].AutoSync.OnCommit)
+}
+
+func TestReconcileRetryRequestedOperation(t *testing.T) {
+	registerTestManifestManager(t, fmt.Errorf("sync failed"))
+
+	app := newReconcileTestApp(&cdv1.Operation{
+		Sync:        &cdv1.SyncOperation{},
+		InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeWebhook},
+	})
+	app.Spec.SyncPolicy.Retry = &cdv1.RetryStrategy{Limit: 2, Backoff: &cdv1.Backoff{Duration: "1ns"}}
+	r := newTestReconciler(app)
+
+	result := reconcileTestApp(t, r, app)
+	require.Nil(t, result.Operation)
+	require.Equal(t, cdv1.OperationPhaseError, result.Status.OperationState.Phase)
+	require.Equal(t, int64(0), result.Status.OperationState.RetryCount)
+
+	// The failed operation is retried, not replaced by an autosync
+	for retry := int64(1); retry <= 2; retry++ {
+		result = reconcileTestApp(t, r, app)
+		require.Equal(t, retry, result.Status.OperationState.RetryCount)
+		require.Equal(t, cdv1.SyncInitiatorTypeWebhook, result.Status.OperationState.Operation.InitiatedBy.Type)
+	}
+
+	// No retry is left
+	require.True(t, meta.IsStatusConditionTrue(result.Status.Conditions, cdv1.ApplicationConditionRetryExhausted))
+}
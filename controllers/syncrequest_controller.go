@@ -0,0 +1,269 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	"github.com/tmax-cloud/cd-operator/pkg/sync"
+)
+
+// SyncRequestReconciler reconciles a SyncRequest object
+type SyncRequestReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=syncrequests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cd.tmax.io,resources=syncrequests/status,verbs=get;update;patch
+
+// Reconcile requests a sync to the application once, and records its result
+func (r *SyncRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("SyncRequest", req.NamespacedName)
+
+	instance := &cdv1.SyncRequest{}
+	if err := r.Client.Get(ctx, req.NamespacedName, instance); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "")
+		return ctrl.Result{}, err
+	}
+
+	// A SyncRequest is run only once
+	if instance.Status.IsCompleted() {
+		return ctrl.Result{}, nil
+	}
+
+	original := instance.DeepCopy()
+	defer func() {
+		p := client.MergeFrom(original)
+		if err := r.Client.Status().Patch(ctx, instance, p); err != nil {
+			log.Error(err, "")
+		}
+	}()
+
+	app := &cdv1.Application{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: instance.Spec.Application, Namespace: instance.Namespace}, app); err != nil {
+		if errors.IsNotFound(err) {
+			finishSyncRequest(instance, cdv1.SyncRequestPhaseError, fmt.Sprintf("Application %s is not found", instance.Spec.Application))
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "")
+		return ctrl.Result{}, err
+	}
+
+	if instance.Spec.DryRun {
+		r.runDryRun(ctx, instance, app)
+		return ctrl.Result{}, nil
+	}
+
+	if instance.Status.RequestedAt == nil {
+		return ctrl.Result{}, r.requestSync(ctx, instance, app)
+	}
+
+	updateSyncRequestFromOperation(instance, app)
+	return ctrl.Result{}, nil
+}
+
+// requestSync requests a sync operation to the application. The request waits, while another operation is in progress
+func (r *SyncRequestReconciler) requestSync(ctx context.Context, instance *cdv1.SyncRequest, app *cdv1.Application) error {
+	op := cdv1.Operation{
+		Sync: &cdv1.SyncOperation{
+			Revision:       instance.Spec.Revision,
+			Resources:      instance.Spec.Resources,
+			LabelSelectors: instance.Spec.LabelSelectors,
+			Prune:          instance.Spec.Prune,
+		},
+		InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeSyncRequest, SyncRequest: instance.Name},
+	}
+
+	if err := sync.RequestOperation(ctx, r.Client, app, op); err != nil {
+		if err == sync.ErrOperationInProgress || errors.IsConflict(err) {
+			instance.Status.Phase = cdv1.SyncRequestPhasePending
+			instance.Status.Message = "Waiting for the operation in progress of the application"
+			return nil
+		}
+		r.Log.Error(err, "RequestOperation failed..")
+		return err
+	}
+
+	now := metav1.Now()
+	instance.Status.Phase = cdv1.SyncRequestPhaseRunning
+	instance.Status.Message = "Sync is requested"
+	instance.Status.RequestedAt = &now
+	addSyncRequestLog(instance, fmt.Sprintf("Requested sync to Application %s", app.Name))
+	return nil
+}
+
+// updateSyncRequestFromOperation copies the state of the requested operation into the status of the SyncRequest
+func updateSyncRequestFromOperation(instance *cdv1.SyncRequest, app *cdv1.Application) {
+	initiator := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeSyncRequest, SyncRequest: instance.Name}
+	if app.Operation != nil && app.Operation.IsCoalesced(initiator) {
+		instance.Status.Message = fmt.Sprintf("Sync is coalesced into the pending sync of %s", initiatorString(app.Operation.InitiatedBy))
+	}
+
+	state := app.Status.OperationState
+	if state == nil || state.StartedAt.Before(instance.Status.RequestedAt) {
+		// Not started yet
+		return
+	}
+
+	// The sync may have been merged into another sync, which was requested while it was pending
+	superseded := state.Operation.IsCoalesced(initiator)
+	if !state.Operation.InitiatedBy.Matches(initiator) && !superseded {
+		// Another operation started instead of the requested one
+		if app.Operation == nil {
+			finishSyncRequest(instance, cdv1.SyncRequestPhaseError, "Sync is cancelled")
+		}
+		return
+	}
+
+	instance.Status.StartedAt = state.StartedAt.DeepCopy()
+	if !state.IsCompleted() {
+		instance.Status.Phase = cdv1.SyncRequestPhaseRunning
+		instance.Status.Message = state.Message
+		return
+	}
+
+	instance.Status.Result = state.SyncResult.DeepCopy()
+	if state.SyncResult != nil {
+		for _, res := range state.SyncResult.Resources {
+			addSyncRequestLog(instance, fmt.Sprintf("%s %s: %s", res.Status, resourceString(res.Kind, res.Namespace, res.Name), res.Message))
+		}
+	}
+
+	if superseded {
+		finishSyncRequest(instance, cdv1.SyncRequestPhaseSuperseded, fmt.Sprintf("Superseded by the sync of %s: %s", initiatorString(state.Operation.InitiatedBy), state.Message))
+		return
+	}
+
+	phase := cdv1.SyncRequestPhaseError
+	switch state.Phase {
+	case cdv1.OperationPhaseSucceeded:
+		phase = cdv1.SyncRequestPhaseSucceeded
+	case cdv1.OperationPhaseFailed:
+		phase = cdv1.SyncRequestPhaseFailed
+	}
+	finishSyncRequest(instance, phase, state.Message)
+}
+
+// runDryRun compares the application with the live state, and records the differences in the logs
+func (r *SyncRequestReconciler) runDryRun(ctx context.Context, instance *cdv1.SyncRequest, app *cdv1.Application) {
+	now := metav1.Now()
+	instance.Status.StartedAt = &now
+
+	opts := manifestmanager.SyncOptions{
+		Revision:       instance.Spec.Revision,
+		Resources:      instance.Spec.Resources,
+		LabelSelectors: instance.Spec.LabelSelectors,
+		Prune:          instance.Spec.Prune,
+	}
+	diffs, err := sync.Diff(ctx, r.Client, app, opts)
+	if err != nil {
+		r.Log.Error(err, "Diff failed..")
+		finishSyncRequest(instance, cdv1.SyncRequestPhaseError, err.Error())
+		return
+	}
+
+	changed := 0
+	for _, diff := range diffs {
+		if diff.Action == manifestmanager.DiffActionNone {
+			continue
+		}
+		changed++
+		msg := fmt.Sprintf("%s %s", diff.Action, resourceString(diff.Kind, diff.Namespace, diff.Name))
+		if diff.Summary != "" {
+			msg += ": " + diff.Summary
+		}
+		addSyncRequestLog(instance, msg)
+	}
+
+	finishSyncRequest(instance, cdv1.SyncRequestPhaseSucceeded, fmt.Sprintf("Dry run: %d resources would be changed", changed))
+}
+
+func finishSyncRequest(instance *cdv1.SyncRequest, phase cdv1.SyncRequestPhase, message string) {
+	now := metav1.Now()
+	instance.Status.Phase = phase
+	instance.Status.Message = message
+	instance.Status.FinishedAt = &now
+	addSyncRequestLog(instance, fmt.Sprintf("Sync %s: %s", phase, message))
+}
+
+func addSyncRequestLog(instance *cdv1.SyncRequest, message string) {
+	instance.Status.Logs = append(instance.Status.Logs, fmt.Sprintf("%s %s", time.Now().UTC().Format(time.RFC3339), message))
+}
+
+func resourceString(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + "/" + name
+	}
+	return kind + " " + namespace + "/" + name
+}
+
+func initiatorString(initiator cdv1.SyncInitiator) string {
+	switch {
+	case initiator.SyncRequest != "":
+		return fmt.Sprintf("%s %s", initiator.Type, initiator.SyncRequest)
+	case initiator.Username != "":
+		return fmt.Sprintf("%s %s", initiator.Type, initiator.Username)
+	default:
+		return string(initiator.Type)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SyncRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cdv1.SyncRequest{}).
+		Watches(&source.Kind{Type: &cdv1.Application{}}, handler.EnqueueRequestsFromMapFunc(r.syncRequestsOfApplication)).
+		Complete(r)
+}
+
+// syncRequestsOfApplication returns the SyncRequests in progress, which refer to the application
+func (r *SyncRequestReconciler) syncRequestsOfApplication(obj client.Object) []reconcile.Request {
+	list := &cdv1.SyncRequestList{}
+	if err := r.Client.List(context.Background(), list, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, sr := range list.Items {
+		if sr.Spec.Application != obj.GetName() || sr.Status.IsCompleted() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: sr.Name, Namespace: sr.Namespace}})
+	}
+	return requests
+}
@@ -30,7 +30,7 @@ type authorizer struct {
 	log logr.Logger
 }
 
-// NewAuthorizer instantiates a new authorizer
+// NewAuthorizer instantiates a new authorizer. If verb is empty, it is derived from the request method
 func NewAuthorizer(cli *authorization.AuthorizationV1Client, apiGroup, apiVersion, verb string) Authorizer {
 	return &authorizer{
 		AuthCli:    cli,
@@ -83,6 +83,11 @@ func (a *authorizer) reviewAccess(req *http.Request) error {
 	resourceName := subPaths[7]
 	subResource := subPaths[8]
 
+	verb := a.Verb
+	if verb == "" {
+		verb = verbForMethod(req.Method)
+	}
+
 	r := &authorizationv1.SubjectAccessReview{
 		Spec: authorizationv1.SubjectAccessReviewSpec{
 			User:   userName,
@@ -95,7 +100,7 @@ func (a *authorizer) reviewAccess(req *http.Request) error {
 				Version:     a.APIVersion,
 				Resource:    resourceType,
 				Subresource: subResource,
-				Verb:        a.Verb,
+				Verb:        verb,
 			},
 		},
 	}
@@ -111,3 +116,19 @@ func (a *authorizer) reviewAccess(req *http.Request) error {
 
 	return fmt.Errorf(result.Status.Reason)
 }
+
+// verbForMethod converts a http method to a kubernetes api verb
+func verbForMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return "update"
+	}
+}
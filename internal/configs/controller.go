@@ -41,6 +41,13 @@ func ApplyControllerConfigChange(cm *corev1.ConfigMap) error {
 		"exposeMode":       {Type: cfgTypeString, StringVal: &ExposeMode, StringDefault: "Ingress"}, // Expose mode
 		"ingressClass":     {Type: cfgTypeString, StringVal: &IngressClass, StringDefault: ""},      // Ingress class
 		"ingressHost":      {Type: cfgTypeString, StringVal: &IngressHost, StringDefault: ""},       // Ingress host
+
+		"resourceHealthChecks": {Type: cfgTypeString, StringVal: &ResourceHealthChecks, StringDefault: ""}, // Health checks for custom resources
+		"syncTimeout":          {Type: cfgTypeString, StringVal: &SyncTimeout, StringDefault: "10m"},       // Default timeout of a sync
+		"syncWorkers":          {Type: cfgTypeInt, IntVal: &SyncWorkers, IntDefault: 10},                   // Number of resources compared and applied concurrently in a sync
+		"clusterQPS":           {Type: cfgTypeInt, IntVal: &ClusterQPS, IntDefault: 50},                    // Maximum QPS to a destination cluster
+		"clusterBurst":         {Type: cfgTypeInt, IntVal: &ClusterBurst, IntDefault: 100},                 // Maximum burst of the requests to a destination cluster
+		"trackingMethod":       {Type: cfgTypeString, StringVal: &TrackingMethod, StringDefault: "label"},  // How the applied objects are marked with their applications
 	})
 
 	// Init
@@ -78,4 +85,21 @@ var (
 
 	// IngressHost is a host for ingress instance
 	IngressHost string
+
+	// ResourceHealthChecks is a yaml list of user-defined health checks, keyed by group/kind of the resources
+	ResourceHealthChecks string
+
+	// SyncTimeout is the default maximum duration of a sync, e.g., 10m
+	SyncTimeout string
+
+	// SyncWorkers is the number of resources, which are compared and applied concurrently in a sync
+	SyncWorkers int
+
+	// ClusterQPS and ClusterBurst limit the requests of the syncs to each destination cluster. 0 QPS means no limit
+	ClusterQPS   int
+	ClusterBurst int
+
+	// TrackingMethod is how the applied objects are marked with the applications managing them
+	// (label/annotation/annotation+label)
+	TrackingMethod string
 )
@@ -48,7 +48,7 @@ func NewHandler(parent wrapper.RouterWrapper, cli client.Client, authCli *author
 	handler := &handler{k8sClient: cli, log: logger}
 
 	// Authorizer
-	handler.authorizer = apiserver.NewAuthorizer(authCli, apiserver.APIGroup, APIVersion, "update")
+	handler.authorizer = apiserver.NewAuthorizer(authCli, apiserver.APIGroup, APIVersion, "")
 
 	// /applications/<application>
 	applicationWrapper := wrapper.New(fmt.Sprintf("/%s/{%s}", cdv1.APIKindApplication, applicationNameParamKey), nil, nil)
@@ -63,5 +63,23 @@ func NewHandler(parent wrapper.RouterWrapper, cli client.Client, authCli *author
 		return nil, err
 	}
 
+	// /applications/<application>/diff
+	diffWrapper := wrapper.New("/"+cdv1.ApplicationAPIDiff, []string{http.MethodGet}, handler.diffHandler)
+	if err := applicationWrapper.Add(diffWrapper); err != nil {
+		return nil, err
+	}
+
+	// /applications/<application>/rollback
+	rollbackWrapper := wrapper.New("/"+cdv1.ApplicationAPIRollback, []string{http.MethodPut}, handler.rollbackHandler)
+	if err := applicationWrapper.Add(rollbackWrapper); err != nil {
+		return nil, err
+	}
+
+	// /applications/<application>/terminate
+	terminateWrapper := wrapper.New("/"+cdv1.ApplicationAPITerminate, []string{http.MethodPut}, handler.terminateHandler)
+	if err := applicationWrapper.Add(terminateWrapper); err != nil {
+		return nil, err
+	}
+
 	return handler, nil
 }
@@ -18,11 +18,17 @@ package applications
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
 	"github.com/tmax-cloud/cd-operator/pkg/sync"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/tmax-cloud/cd-operator/internal/apiserver"
@@ -35,11 +41,96 @@ import (
 // +kubebuilder:rbac:groups="authorization.k8s.io",resources=subjectaccessreviews,verbs=get;list;watch;create;update;patch
 
 func (h *handler) syncHandler(w http.ResponseWriter, req *http.Request) {
-	h.updateDeploy(w, req)
+	reqID := utils.RandomString(10)
+	log := h.log.WithValues("request", reqID)
+
+	syncOp, err := decodeSyncOperation(req)
+	if err != nil {
+		log.Info(err.Error())
+		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("req: %s, sync request body is invalid: %s", reqID, err.Error()))
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(req.URL.Query().Get("dryRun"))
+	if dryRun {
+		h.diff(w, req, reqID, manifestmanager.SyncOptions{
+			Revision:       syncOp.Revision,
+			Resources:      syncOp.Resources,
+			LabelSelectors: syncOp.LabelSelectors,
+			Prune:          syncOp.Prune,
+		})
+		return
+	}
+	h.updateDeploy(w, req, reqID, syncOp)
 }
 
-func (h *handler) updateDeploy(w http.ResponseWriter, req *http.Request) {
-	reqID := utils.RandomString(10)
+func (h *handler) updateDeploy(w http.ResponseWriter, req *http.Request, reqID string, syncOp *cdv1.SyncOperation) {
+	app, ok := h.getApplication(w, req, reqID)
+	if !ok {
+		return
+	}
+
+	// sync resources with manitests
+	// TODO: application의 sync status, sync 옵션 등 추가하여 분기 필요.
+	op := cdv1.Operation{Sync: syncOp, InitiatedBy: getInitiator(req)}
+	h.requestOperation(w, req, app, op, reqID)
+}
+
+// decodeSyncOperation decodes the body of the sync request, which selects the resources to be synced.
+// An empty body syncs all resources
+func decodeSyncOperation(req *http.Request) (*cdv1.SyncOperation, error) {
+	syncOp := &cdv1.SyncOperation{}
+	if req.Body == nil {
+		return syncOp, nil
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(syncOp); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if syncOp.RollbackTo != nil {
+		return nil, fmt.Errorf("rollbackTo is not allowed, use the rollback api instead")
+	}
+	for _, r := range syncOp.Resources {
+		if r.Kind == "" || r.Name == "" {
+			return nil, fmt.Errorf("kind and name of the resource are required")
+		}
+	}
+	for i := range syncOp.LabelSelectors {
+		if _, err := metav1.LabelSelectorAsSelector(&syncOp.LabelSelectors[i]); err != nil {
+			return nil, err
+		}
+	}
+	return syncOp, nil
+}
+
+// requestOperation requests the operation to the application, and responds with the requested operation
+func (h *handler) requestOperation(w http.ResponseWriter, req *http.Request, app *cdv1.Application, op cdv1.Operation, reqID string) {
+	log := h.log.WithValues("request", reqID)
+
+	if err := sync.RequestOperation(req.Context(), h.k8sClient, app, op); err != nil {
+		log.Info(err.Error())
+		code := http.StatusInternalServerError
+		if err == sync.ErrOperationInProgress || errors.IsConflict(err) {
+			code = http.StatusConflict
+		}
+		_ = utils.RespondError(w, code, fmt.Sprintf("req: %s, cannot request operation to Application %s/%s: %s", reqID, app.Namespace, app.Name, err.Error()))
+		return
+	}
+
+	_ = utils.RespondJSON(w, app.Operation)
+}
+
+// getInitiator returns the user who requested the sync
+func getInitiator(req *http.Request) cdv1.SyncInitiator {
+	userName, _ := apiserver.GetUserName(req.Header)
+	return cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeUser, Username: userName}
+}
+
+// getApplication gets the Application of the request. If it fails, it responds with an error and returns false
+func (h *handler) getApplication(w http.ResponseWriter, req *http.Request, reqID string) (*cdv1.Application, bool) {
 	log := h.log.WithValues("request", reqID)
 
 	vars := mux.Vars(req)
@@ -48,18 +139,13 @@ func (h *handler) updateDeploy(w http.ResponseWriter, req *http.Request) {
 	applicationName, nameExist := vars[applicationNameParamKey]
 	if !nsExist || !nameExist {
 		_ = utils.RespondError(w, http.StatusBadRequest, "url is malformed")
-		return
+		return nil, false
 	}
 	app := &cdv1.Application{}
 	if err := h.k8sClient.Get(context.Background(), types.NamespacedName{Name: applicationName, Namespace: ns}, app); err != nil {
 		log.Info(err.Error())
 		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("req: %s, no Application %s/%s is found", reqID, ns, applicationName))
-		return
-	}
-	// sync resources with manitests
-	// TODO: application의 sync status, sync 옵션 등 추가하여 분기 필요.
-	if err := sync.CheckSync(h.k8sClient, app, true); err != nil {
-		log.Info(err.Error())
-		return
+		return nil, false
 	}
+	return app, true
 }
@@ -0,0 +1,59 @@
+/*
+ Copyright 2021 The CI/CD Operator Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package applications
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tmax-cloud/cd-operator/internal/utils"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	"github.com/tmax-cloud/cd-operator/pkg/sync"
+)
+
+// DiffResponse is a response of the diff api and the dry-run sync api
+type DiffResponse struct {
+	// Revision is the revision which the desired state is rendered from
+	Revision  string                         `json:"revision"`
+	Resources []manifestmanager.ResourceDiff `json:"resources"`
+}
+
+func (h *handler) diffHandler(w http.ResponseWriter, req *http.Request) {
+	h.diff(w, req, utils.RandomString(10), manifestmanager.SyncOptions{Revision: req.URL.Query().Get("revision")})
+}
+
+func (h *handler) diff(w http.ResponseWriter, req *http.Request, reqID string, opts manifestmanager.SyncOptions) {
+	log := h.log.WithValues("request", reqID)
+
+	app, ok := h.getApplication(w, req, reqID)
+	if !ok {
+		return
+	}
+
+	diffs, err := sync.Diff(req.Context(), h.k8sClient, app, opts)
+	if err != nil {
+		log.Info(err.Error())
+		_ = utils.RespondError(w, http.StatusInternalServerError, fmt.Sprintf("req: %s, cannot get diff: %s", reqID, err.Error()))
+		return
+	}
+
+	revision := opts.Revision
+	if revision == "" {
+		revision = app.Spec.Source.TargetRevision
+	}
+	_ = utils.RespondJSON(w, DiffResponse{Revision: revision, Resources: diffs})
+}
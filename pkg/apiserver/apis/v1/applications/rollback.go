@@ -0,0 +1,50 @@
+/*
+ Copyright 2021 The CI/CD Operator Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package applications
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/utils"
+)
+
+// rollbackHandler requests to sync the application to the revision of the history, given as ?id=<history id>
+func (h *handler) rollbackHandler(w http.ResponseWriter, req *http.Request) {
+	reqID := utils.RandomString(10)
+
+	id, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("req: %s, id of the history is not valid", reqID))
+		return
+	}
+
+	app, ok := h.getApplication(w, req, reqID)
+	if !ok {
+		return
+	}
+
+	if app.GetHistory(id) == nil {
+		_ = utils.RespondError(w, http.StatusNotFound, fmt.Sprintf("req: %s, no history %d is found in Application %s/%s", reqID, id, app.Namespace, app.Name))
+		return
+	}
+
+	op := cdv1.Operation{Sync: &cdv1.SyncOperation{RollbackTo: &id}, InitiatedBy: getInitiator(req)}
+	h.requestOperation(w, req, app, op, reqID)
+}
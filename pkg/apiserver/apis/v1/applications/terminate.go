@@ -0,0 +1,54 @@
+/*
+ Copyright 2021 The CI/CD Operator Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package applications
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/tmax-cloud/cd-operator/internal/utils"
+	"github.com/tmax-cloud/cd-operator/pkg/sync"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// terminateHandler terminates the operation in progress of the application
+func (h *handler) terminateHandler(w http.ResponseWriter, req *http.Request) {
+	reqID := utils.RandomString(10)
+	log := h.log.WithValues("request", reqID)
+
+	app, ok := h.getApplication(w, req, reqID)
+	if !ok {
+		return
+	}
+
+	if !app.IsOperationInProgress() {
+		_ = utils.RespondError(w, http.StatusBadRequest, fmt.Sprintf("req: %s, no operation is in progress in Application %s/%s", reqID, app.Namespace, app.Name))
+		return
+	}
+
+	if err := sync.TerminateOperation(req.Context(), h.k8sClient, app); err != nil {
+		log.Info(err.Error())
+		code := http.StatusInternalServerError
+		if errors.IsConflict(err) {
+			code = http.StatusConflict
+		}
+		_ = utils.RespondError(w, code, fmt.Sprintf("req: %s, cannot terminate operation of Application %s/%s: %s", reqID, app.Namespace, app.Name, err.Error()))
+		return
+	}
+
+	_ = utils.RespondJSON(w, app.Status.OperationState)
+}
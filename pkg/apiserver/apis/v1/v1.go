@@ -77,6 +77,18 @@ func (h *handler) versionHandler(w http.ResponseWriter, _ *http.Request) {
 			Name:       fmt.Sprintf("%s/%s", cdv1.APIKindApplication, cdv1.ApplicationAPISync),
 			Namespaced: true,
 		},
+		{
+			Name:       fmt.Sprintf("%s/%s", cdv1.APIKindApplication, cdv1.ApplicationAPIDiff),
+			Namespaced: true,
+		},
+		{
+			Name:       fmt.Sprintf("%s/%s", cdv1.APIKindApplication, cdv1.ApplicationAPIRollback),
+			Namespaced: true,
+		},
+		{
+			Name:       fmt.Sprintf("%s/%s", cdv1.APIKindApplication, cdv1.ApplicationAPITerminate),
+			Namespaced: true,
+		},
 	}
 
 	_ = utils.RespondJSON(w, apiResourceList)
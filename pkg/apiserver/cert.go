@@ -28,7 +28,9 @@ import (
 
 	"github.com/tmax-cloud/cd-operator/internal/utils"
 
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/cert"
@@ -93,6 +95,23 @@ func createCert(ctx context.Context, client client.Client) error {
 		return err
 	}
 
+	// Update ValidatingWebhookConfiguration. It may not be installed, then the deletion protection is enforced only by
+	// the finalizer of the application
+	webhookConfig := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := client.Get(ctx, types.NamespacedName{Name: ValidatingWebhookConfigurationName}, webhookConfig); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf("ValidatingWebhookConfiguration %s is not found, skipping it", ValidatingWebhookConfigurationName))
+			return nil
+		}
+		return err
+	}
+	for i := range webhookConfig.Webhooks {
+		webhookConfig.Webhooks[i].ClientConfig.CABundle = caCrt
+	}
+	if err := client.Update(ctx, webhookConfig); err != nil {
+		return err
+	}
+
 	return nil
 }
 
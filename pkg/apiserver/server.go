@@ -56,6 +56,7 @@ type Server struct {
 }
 
 // +kubebuilder:rbac:groups=apiregistration.k8s.io,resources=apiservices,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,verbs=get;list;watch;update
 // +kubebuilder:rbac:groups="",resources=configmaps,namespace=kube-system,resourceNames=extension-apiserver-authentication,verbs=get;list;watch
 
 // New is a constructor of server
@@ -65,6 +66,7 @@ func New(cli client.Client, cfg *rest.Config, cache cache.Cache) *Server {
 	srv.wrapper = wrapper.New("/", nil, srv.rootHandler)
 	srv.wrapper.SetRouter(mux.NewRouter())
 	srv.wrapper.Router().HandleFunc("/", srv.rootHandler)
+	srv.wrapper.Router().HandleFunc(validateApplicationPath, srv.validateApplicationHandler).Methods(http.MethodPost)
 	srv.client = cli
 	srv.cache = cache
 	srv.authCli, err = authorization.NewForConfig(cfg)
@@ -0,0 +1,75 @@
+/*
+ Copyright 2021 The CI/CD Operator Authors
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/utils"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ValidatingWebhookConfigurationName is a name of ValidatingWebhookConfiguration object
+	ValidatingWebhookConfigurationName = "cd-validating-webhook"
+
+	validateApplicationPath = "/validate-application"
+)
+
+// validateApplicationHandler is a validating webhook of the applications, which rejects the deletion of the applications
+// protected by the deletion protection annotation
+func (s *Server) validateApplicationHandler(w http.ResponseWriter, req *http.Request) {
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(req.Body).Decode(review); err != nil || review.Request == nil {
+		_ = utils.RespondError(w, http.StatusBadRequest, "cannot decode admission review")
+		return
+	}
+
+	review.Response = reviewApplication(review.Request)
+	review.Request = nil
+	_ = utils.RespondJSON(w, review)
+}
+
+// reviewApplication allows the request, unless it deletes an application protected from the deletion
+func reviewApplication(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	resp := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation != admissionv1.Delete {
+		return resp
+	}
+
+	app := &cdv1.Application{}
+	if err := json.Unmarshal(req.OldObject.Raw, app); err != nil {
+		log.Error(err, "Decoding application failed..")
+		resp.Allowed = false
+		resp.Result = &metav1.Status{Code: http.StatusBadRequest, Reason: metav1.StatusReasonBadRequest, Message: err.Error()}
+		return resp
+	}
+
+	if app.IsDeletionProtected() {
+		resp.Allowed = false
+		resp.Result = &metav1.Status{
+			Code:    http.StatusForbidden,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: fmt.Sprintf("application %s/%s is protected by annotation %s. Remove the annotation to delete it", app.Namespace, app.Name, cdv1.AnnotationDeletionProtection),
+		}
+	}
+	return resp
+}
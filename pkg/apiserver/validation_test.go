@@ -0,0 +1,59 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type reviewApplicationTestCase struct {
+	operation   admissionv1.Operation
+	annotations map[string]string
+
+	expectedAllowed bool
+}
+
+func TestReviewApplication(t *testing.T) {
+	tc := map[string]reviewApplicationTestCase{
+		"delete": {
+			operation:       admissionv1.Delete,
+			expectedAllowed: true,
+		},
+		"deleteProtected": {
+			operation:       admissionv1.Delete,
+			annotations:     map[string]string{cdv1.AnnotationDeletionProtection: "true"},
+			expectedAllowed: false,
+		},
+		"deleteNotProtected": {
+			operation:       admissionv1.Delete,
+			annotations:     map[string]string{cdv1.AnnotationDeletionProtection: "false"},
+			expectedAllowed: true,
+		},
+		"updateProtected": {
+			operation:       admissionv1.Update,
+			annotations:     map[string]string{cdv1.AnnotationDeletionProtection: "true"},
+			expectedAllowed: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default", Annotations: c.annotations}}
+			raw, err := json.Marshal(app)
+			require.NoError(t, err)
+
+			req := &admissionv1.AdmissionRequest{UID: "test-uid", Operation: c.operation, OldObject: runtime.RawExtension{Raw: raw}}
+			resp := reviewApplication(req)
+			require.Equal(t, req.UID, resp.UID)
+			require.Equal(t, c.expectedAllowed, resp.Allowed)
+			if !c.expectedAllowed {
+				require.Contains(t, resp.Result.Message, cdv1.AnnotationDeletionProtection)
+			}
+		})
+	}
+}
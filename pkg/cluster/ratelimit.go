@@ -0,0 +1,180 @@
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("cluster")
+
+// Annotations of the cluster secret, which override the rate limit of the requests to the cluster
+const (
+	AnnotationQPS   = "cd.tmax.io/qps"
+	AnnotationBurst = "cd.tmax.io/burst"
+)
+
+// clusterRateLimiter is a rate limiter of a cluster, with the limit it's created for
+type clusterRateLimiter struct {
+	qps     float32
+	burst   int
+	limiter flowcontrol.RateLimiter
+}
+
+// rateLimiters are the rate limiters of the clusters, keyed by their secrets. The current cluster's key is empty
+var (
+	rateLimiters     = map[string]*clusterRateLimiter{}
+	rateLimitersLock sync.Mutex
+)
+
+// GetRateLimiter returns the rate limiter of the requests to the destination cluster of the application, which is
+// shared by all syncs to the cluster. The limit is clusterQPS and clusterBurst of the cd-config config map, unless
+// they're overridden by the annotations of the cluster secret. It returns nil, if the requests are not limited
+func GetRateLimiter(ctx context.Context, c client.Client, app *cdv1.Application) (flowcontrol.RateLimiter, error) {
+	key := ""
+	qps := float32(configs.ClusterQPS)
+	burst := configs.ClusterBurst
+	if app.Spec.Destination.Name != "" {
+		clusterSecret, err := getDestClusterSecret(ctx, c, app.Spec.Destination.Name, app.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		key = clusterSecret.Namespace + "/" + clusterSecret.Name
+		qps, burst = getRateLimitOverrides(clusterSecret, qps, burst)
+	}
+
+	if qps <= 0 {
+		return nil, nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return getRateLimiter(key, qps, burst), nil
+}
+
+// getRateLimitOverrides returns the rate limit of the cluster secret's annotations, or the default values
+func getRateLimitOverrides(clusterSecret *corev1.Secret, qps float32, burst int) (float32, int) {
+	if v, exist := clusterSecret.Annotations[AnnotationQPS]; exist {
+		f, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			log.Error(err, "Parsing qps annotation failed..", "secret", clusterSecret.Name)
+		} else {
+			qps = float32(f)
+		}
+	}
+	if v, exist := clusterSecret.Annotations[AnnotationBurst]; exist {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			log.Error(err, "Parsing burst annotation failed..", "secret", clusterSecret.Name)
+		} else {
+			burst = i
+		}
+	}
+	return qps, burst
+}
+
+// getRateLimiter returns the rate limiter of the cluster. It's created again, if the limit is changed
+func getRateLimiter(key string, qps float32, burst int) flowcontrol.RateLimiter {
+	rateLimitersLock.Lock()
+	defer rateLimitersLock.Unlock()
+
+	l, exist := rateLimiters[key]
+	if !exist || l.qps != qps || l.burst != burst {
+		l = &clusterRateLimiter{qps: qps, burst: burst, limiter: flowcontrol.NewTokenBucketRateLimiter(qps, burst)}
+		rateLimiters[key] = l
+	}
+	return l.limiter
+}
+
+// NewRateLimitedClient returns a client, which waits for the rate limiter before each request.
+// If the limiter is nil, the client is returned as it is
+func NewRateLimitedClient(c client.Client, limiter flowcontrol.RateLimiter) client.Client {
+	if limiter == nil {
+		return c
+	}
+	return &rateLimitedClient{Client: c, limiter: limiter}
+}
+
+type rateLimitedClient struct {
+	client.Client
+	limiter flowcontrol.RateLimiter
+}
+
+func (c *rateLimitedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+func (c *rateLimitedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *rateLimitedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *rateLimitedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *rateLimitedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *rateLimitedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *rateLimitedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c *rateLimitedClient) Status() client.StatusWriter {
+	return &rateLimitedStatusWriter{StatusWriter: c.Client.Status(), limiter: c.limiter}
+}
+
+type rateLimitedStatusWriter struct {
+	client.StatusWriter
+	limiter flowcontrol.RateLimiter
+}
+
+func (w *rateLimitedStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+func (w *rateLimitedStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
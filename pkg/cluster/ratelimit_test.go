@@ -0,0 +1,118 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type getRateLimiterTestCase struct {
+	destination string
+	qps         int
+	burst       int
+
+	expectedLimited bool
+	expectedQPS     float32
+	expectedErrMsg  string
+}
+
+func TestGetRateLimiter(t *testing.T) {
+	tc := map[string]getRateLimiterTestCase{
+		"currentCluster": {
+			qps:             20,
+			burst:           40,
+			expectedLimited: true,
+			expectedQPS:     20,
+		},
+		"notLimited": {
+			expectedLimited: false,
+		},
+		"overridden": {
+			destination:     "remote",
+			qps:             20,
+			burst:           40,
+			expectedLimited: true,
+			expectedQPS:     5,
+		},
+		"noSecret": {
+			destination:    "no-exist",
+			qps:            20,
+			expectedErrMsg: "unable to find cluster secret no-exist-kubeconfig: secrets \"no-exist-kubeconfig\" not found",
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	sec := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "remote-kubeconfig",
+			Namespace:   "default",
+			Annotations: map[string]string{AnnotationQPS: "5", AnnotationBurst: "10"},
+		},
+	}
+	fakeCli := fake.NewClientBuilder().WithScheme(s).WithObjects(sec).Build()
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			configs.ClusterQPS = c.qps
+			configs.ClusterBurst = c.burst
+			defer func() {
+				configs.ClusterQPS = 0
+				configs.ClusterBurst = 0
+			}()
+
+			app := &cdv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default"},
+				Spec:       cdv1.ApplicationSpec{Destination: cdv1.ApplicationDestination{Name: c.destination}},
+			}
+			limiter, err := GetRateLimiter(context.Background(), fakeCli, app)
+			if c.expectedErrMsg != "" {
+				require.EqualError(t, err, c.expectedErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			if !c.expectedLimited {
+				require.Nil(t, limiter)
+				return
+			}
+			require.NotNil(t, limiter)
+			require.Equal(t, c.expectedQPS, limiter.QPS())
+
+			// The limiter is shared by the syncs to the same cluster
+			shared, err := GetRateLimiter(context.Background(), fakeCli, app)
+			require.NoError(t, err)
+			require.Equal(t, limiter, shared)
+		})
+	}
+}
+
+func TestRateLimitedClient(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	fakeCli := fake.NewClientBuilder().WithScheme(s).WithObjects(cm).Build()
+	require.Equal(t, fakeCli, NewRateLimitedClient(fakeCli, nil))
+
+	// A limiter which never accepts fails the requests, when the context is done
+	cli := NewRateLimitedClient(fakeCli, flowcontrol.NewFakeNeverRateLimiter())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, cli.Get(ctx, types.NamespacedName{Name: "test", Namespace: "default"}, &corev1.ConfigMap{}))
+
+	cli = NewRateLimitedClient(fakeCli, flowcontrol.NewFakeAlwaysRateLimiter())
+	require.NoError(t, cli.Get(context.Background(), types.NamespacedName{Name: "test", Namespace: "default"}, &corev1.ConfigMap{}))
+	require.NoError(t, cli.Status().Update(context.Background(), cm))
+}
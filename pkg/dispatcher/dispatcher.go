@@ -1,14 +1,19 @@
 package dispatcher
 
 import (
+	"context"
 	"fmt"
 
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
 	"github.com/tmax-cloud/cd-operator/pkg/git"
 	"github.com/tmax-cloud/cd-operator/pkg/sync"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+var log = logf.Log.WithName("dispatcher")
+
 // Dispatcher dispatches Application when webhook is called
 // A kind of 'plugin' for webhook handler
 type Dispatcher struct {
@@ -22,10 +27,16 @@ func (d Dispatcher) Handle(webhook *git.Webhook, app *cdv1.Application) error {
 		return fmt.Errorf("push struct is nil")
 	}
 
+	// Do not sync the pushed revision, while the application is rolled back
+	if meta.IsStatusConditionTrue(app.Status.Conditions, cdv1.ApplicationConditionAutoSyncDisabled) {
+		log.Info(fmt.Sprintf("Autosync of %s/%s is disabled, skip syncing the push event", app.Namespace, app.Name))
+		return nil
+	}
+
 	// Push일 경우
 	if webhook.EventType == git.EventTypePush && push != nil {
-		app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
-		if err := sync.CheckSync(d.Client, app, true); err != nil {
+		op := cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeWebhook}}
+		if err := sync.RequestOperation(context.Background(), d.Client, app, op); err != nil {
 			return err
 		}
 	}
@@ -17,6 +17,7 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -59,12 +60,12 @@ func (c *Client) Init() error {
 }
 
 // ParseWebhook parses a webhook body for github
-func (c *Client) ParseWebhook(_ http.Header, _ []byte) (*git.Webhook, error) {
+func (c *Client) ParseWebhook(ctx context.Context, _ http.Header, _ []byte) (*git.Webhook, error) {
 	return nil, nil
 }
 
 // ListWebhook lists registered webhooks
-func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
+func (c *Client) ListWebhook(ctx context.Context) ([]git.WebhookEntry, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -81,7 +82,7 @@ func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
 }
 
 // RegisterWebhook registers our webhook server to the remote git server
-func (c *Client) RegisterWebhook(url string) error {
+func (c *Client) RegisterWebhook(ctx context.Context, url string) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -100,7 +101,7 @@ func (c *Client) RegisterWebhook(url string) error {
 }
 
 // DeleteWebhook deletes registered webhook
-func (c *Client) DeleteWebhook(id int) error {
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -114,7 +115,7 @@ func (c *Client) DeleteWebhook(id int) error {
 }
 
 // ListCommitStatuses lists commit status of the specific commit
-func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
+func (c *Client) ListCommitStatuses(ctx context.Context, ref string) ([]git.CommitStatus, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -135,7 +136,7 @@ func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
 }
 
 // SetCommitStatus sets commit status for the specific commit
-func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
+func (c *Client) SetCommitStatus(ctx context.Context, sha string, status git.CommitStatus) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -153,7 +154,7 @@ func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
 }
 
 // GetUserInfo gets a user's information
-func (c *Client) GetUserInfo(userName string) (*git.User, error) {
+func (c *Client) GetUserInfo(ctx context.Context, userName string) (*git.User, error) {
 	if Users == nil {
 		return nil, fmt.Errorf("users not initialized")
 	}
@@ -165,7 +166,7 @@ func (c *Client) GetUserInfo(userName string) (*git.User, error) {
 }
 
 // CanUserWriteToRepo decides if the user has write permission on the repo
-func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
+func (c *Client) CanUserWriteToRepo(ctx context.Context, user git.User) (bool, error) {
 	if Repos == nil {
 		return false, fmt.Errorf("repos not initialized")
 	}
@@ -187,7 +188,7 @@ func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
 }
 
 // RegisterComment registers comment to an issue
-func (c *Client) RegisterComment(_ git.IssueType, issueNo int, body string) error {
+func (c *Client) RegisterComment(ctx context.Context, _ git.IssueType, issueNo int, body string) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -213,7 +214,7 @@ func (c *Client) RegisterComment(_ git.IssueType, issueNo int, body string) erro
 }
 
 // ListPullRequests gets pull request list
-func (c *Client) ListPullRequests(_ bool) ([]git.PullRequest, error) {
+func (c *Client) ListPullRequests(ctx context.Context, _ bool) ([]git.PullRequest, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -231,7 +232,7 @@ func (c *Client) ListPullRequests(_ bool) ([]git.PullRequest, error) {
 }
 
 // GetPullRequest gets PR given id
-func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
+func (c *Client) GetPullRequest(ctx context.Context, id int) (*git.PullRequest, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -253,7 +254,7 @@ func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
 }
 
 // MergePullRequest merges a pull request
-func (c *Client) MergePullRequest(id int, _ string, _ git.MergeMethod, message string) error {
+func (c *Client) MergePullRequest(ctx context.Context, id int, _ string, _ git.MergeMethod, message string) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -284,7 +285,7 @@ func (c *Client) MergePullRequest(id int, _ string, _ git.MergeMethod, message s
 }
 
 // GetPullRequestDiff gets diff of the pull request
-func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
+func (c *Client) GetPullRequestDiff(ctx context.Context, id int) (*git.Diff, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -306,7 +307,7 @@ func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
 }
 
 // ListPullRequestCommits lists commits list of a pull request
-func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
+func (c *Client) ListPullRequestCommits(ctx context.Context, id int) ([]git.Commit, error) {
 	if Repos == nil {
 		return nil, fmt.Errorf("repos not initialized")
 	}
@@ -328,7 +329,7 @@ func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
 }
 
 // SetLabel sets label to the issue id
-func (c *Client) SetLabel(_ git.IssueType, id int, label string) error {
+func (c *Client) SetLabel(ctx context.Context, _ git.IssueType, id int, label string) error {
 	if Repos == nil {
 		return fmt.Errorf("repos not initialized")
 	}
@@ -352,12 +353,12 @@ func (c *Client) SetLabel(_ git.IssueType, id int, label string) error {
 }
 
 // DeleteLabel deletes label from the issue id
-func (c *Client) DeleteLabel(_ git.IssueType, id int, label string) error {
+func (c *Client) DeleteLabel(ctx context.Context, _ git.IssueType, id int, label string) error {
 	return DeleteLabel(c.Repository, id, label)
 }
 
 // GetBranch returns branch info
-func (c *Client) GetBranch(branch string) (*git.Branch, error) {
+func (c *Client) GetBranch(ctx context.Context, branch string) (*git.Branch, error) {
 	if Branches == nil {
 		return nil, fmt.Errorf("branches not initialized")
 	}
@@ -406,13 +407,13 @@ func DeleteLabel(repoName string, id int, label string) error {
 }
 
 // GetManifestInfos gets fake info
-func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string) ([]string, error) {
+func (c *Client) GetManifestInfos(ctx context.Context, path, revision string, manifestInfos []string) ([]string, error) {
 	// TODO
 	return nil, nil
 }
 
-// ObjectFromManifest returns unstructured objects from a raw manifest file
-func (c *Client) ObjectFromManifest(info, namespace string) ([]*unstructured.Unstructured, error) {
+// ObjectFromManifest returns unstructured objects from a raw manifest file, as they're written
+func (c *Client) ObjectFromManifest(ctx context.Context, info string) ([]*unstructured.Unstructured, error) {
 	// TODO
 	var manifestRawObjs []*unstructured.Unstructured
 
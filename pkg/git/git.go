@@ -17,6 +17,7 @@
 package git
 
 import (
+	"context"
 	"net/http"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
@@ -28,45 +29,47 @@ type Client interface {
 
 	// Webhooks
 
-	ListWebhook() ([]WebhookEntry, error)
-	RegisterWebhook(url string) error
-	DeleteWebhook(id int) error
-	ParseWebhook(http.Header, []byte) (*Webhook, error)
+	ListWebhook(ctx context.Context) ([]WebhookEntry, error)
+	RegisterWebhook(ctx context.Context, url string) error
+	DeleteWebhook(ctx context.Context, id int) error
+	ParseWebhook(context.Context, http.Header, []byte) (*Webhook, error)
 
 	// Commit Status
 
-	ListCommitStatuses(ref string) ([]CommitStatus, error)
-	SetCommitStatus(sha string, status CommitStatus) error
+	ListCommitStatuses(ctx context.Context, ref string) ([]CommitStatus, error)
+	SetCommitStatus(ctx context.Context, sha string, status CommitStatus) error
 
 	// Users
 
-	GetUserInfo(user string) (*User, error)
-	CanUserWriteToRepo(user User) (bool, error)
+	GetUserInfo(ctx context.Context, user string) (*User, error)
+	CanUserWriteToRepo(ctx context.Context, user User) (bool, error)
 
 	// Comments
 
-	RegisterComment(issueType IssueType, issueNo int, body string) error
+	RegisterComment(ctx context.Context, issueType IssueType, issueNo int, body string) error
 
 	// Pull Request
 
-	ListPullRequests(onlyOpen bool) ([]PullRequest, error)
-	GetPullRequest(id int) (*PullRequest, error)
-	MergePullRequest(id int, sha string, method MergeMethod, message string) error
-	GetPullRequestDiff(id int) (*Diff, error)
-	ListPullRequestCommits(id int) ([]Commit, error)
+	ListPullRequests(ctx context.Context, onlyOpen bool) ([]PullRequest, error)
+	GetPullRequest(ctx context.Context, id int) (*PullRequest, error)
+	MergePullRequest(ctx context.Context, id int, sha string, method MergeMethod, message string) error
+	GetPullRequestDiff(ctx context.Context, id int) (*Diff, error)
+	ListPullRequestCommits(ctx context.Context, id int) ([]Commit, error)
 
 	// Issue Labels
 
-	SetLabel(issueType IssueType, id int, label string) error
-	DeleteLabel(issueType IssueType, id int, label string) error
+	SetLabel(ctx context.Context, issueType IssueType, id int, label string) error
+	DeleteLabel(ctx context.Context, issueType IssueType, id int, label string) error
 
 	// Branch
 
-	GetBranch(branch string) (*Branch, error)
+	GetBranch(ctx context.Context, branch string) (*Branch, error)
 
 	// Manifest Files
-	GetManifestInfos(path, revision string, manifestInfos []string) ([]string, error)
-	ObjectFromManifest(info, namespace string) ([]*unstructured.Unstructured, error)
+	GetManifestInfos(ctx context.Context, path, revision string, manifestInfos []string) ([]string, error)
+	// ObjectFromManifest parses the objects of the manifest file. Their namespaces are not set, as the scopes of the
+	// kinds are known only to the destination cluster
+	ObjectFromManifest(ctx context.Context, info string) ([]*unstructured.Unstructured, error)
 }
 
 // IssueType is a type of the issue
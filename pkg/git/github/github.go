@@ -17,6 +17,7 @@
 package github
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha1"
 	"encoding/json"
@@ -56,7 +57,7 @@ func (c *Client) Init() error {
 }
 
 // ParseWebhook parses a webhook body for github
-func (c *Client) ParseWebhook(header http.Header, jsonString []byte) (*git.Webhook, error) {
+func (c *Client) ParseWebhook(ctx context.Context, header http.Header, jsonString []byte) (*git.Webhook, error) {
 	var signature = strings.Replace(header.Get("x-hub-signature"), "sha1=", "", 1)
 	if err := Validate(c.GitWebhookSecret, signature, jsonString); err != nil {
 		return nil, err
@@ -64,25 +65,25 @@ func (c *Client) ParseWebhook(header http.Header, jsonString []byte) (*git.Webho
 	eventType := git.EventType(header.Get("x-github-event"))
 	switch eventType {
 	case git.EventTypePullRequest:
-		return c.parsePullRequestWebhook(jsonString)
+		return c.parsePullRequestWebhook(ctx, jsonString)
 	case git.EventTypePush:
-		return c.parsePushWebhook(jsonString)
+		return c.parsePushWebhook(ctx, jsonString)
 	case git.EventTypeIssueComment:
-		return c.parseIssueCommentWebhook(jsonString)
+		return c.parseIssueCommentWebhook(ctx, jsonString)
 	case git.EventTypePullRequestReview:
-		return c.parsePullRequestReviewWebhook(jsonString)
+		return c.parsePullRequestReviewWebhook(ctx, jsonString)
 	case git.EventTypePullRequestReviewComment:
-		return c.parsePullRequestReviewCommentWebhook(jsonString)
+		return c.parsePullRequestReviewCommentWebhook(ctx, jsonString)
 	}
 	return nil, nil
 }
 
 // ListWebhook lists registered webhooks
-func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
+func (c *Client) ListWebhook(ctx context.Context) ([]git.WebhookEntry, error) {
 	var apiURL = c.GitAPIURL + "/repos/" + c.GitRepository + "/hooks"
 
 	var entries []WebhookEntry
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]WebhookEntry{}
 	}, func(i interface{}) {
 		entries = append(entries, *i.(*[]WebhookEntry)...)
@@ -100,7 +101,7 @@ func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
 }
 
 // RegisterWebhook registers our webhook server to the remote git server
-func (c *Client) RegisterWebhook(url string) error {
+func (c *Client) RegisterWebhook(ctx context.Context, url string) error {
 	var registrationBody RegistrationWebhookBody
 	var registrationConfig RegistrationWebhookBodyConfig
 	var apiURL = c.GitAPIURL + "/repos/" + c.GitRepository + "/hooks"
@@ -115,7 +116,7 @@ func (c *Client) RegisterWebhook(url string) error {
 
 	registrationBody.Config = registrationConfig
 
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, registrationBody); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, registrationBody); err != nil {
 		return err
 	}
 
@@ -123,20 +124,20 @@ func (c *Client) RegisterWebhook(url string) error {
 }
 
 // DeleteWebhook deletes registered webhook
-func (c *Client) DeleteWebhook(id int) error {
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
 	var apiURL = c.GitAPIURL + "/repos/" + c.GitRepository + "/hooks/" + strconv.Itoa(id)
-	if _, _, err := c.requestHTTP(http.MethodDelete, apiURL, nil); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodDelete, apiURL, nil); err != nil {
 		return err
 	}
 	return nil
 }
 
 // ListCommitStatuses lists commit status of the specific commit
-func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
+func (c *Client) ListCommitStatuses(ctx context.Context, ref string) ([]git.CommitStatus, error) {
 	apiURL := c.GitAPIURL + "/repos/" + c.GitRepository + "/commits/" + ref + "/statuses"
 
 	var statuses []CommitStatusResponse
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]CommitStatusResponse{}
 	}, func(i interface{}) {
 		statuses = append(statuses, *i.(*[]CommitStatusResponse)...)
@@ -167,7 +168,7 @@ func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
 }
 
 // SetCommitStatus sets commit status for the specific commit
-func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
+func (c *Client) SetCommitStatus(ctx context.Context, sha string, status git.CommitStatus) error {
 	var commitStatusBody CommitStatusRequest
 
 	// Don't set commit status if its' sha is a fake
@@ -182,7 +183,7 @@ func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
 	commitStatusBody.Description = status.Description
 	commitStatusBody.Context = status.Context
 
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, commitStatusBody); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, commitStatusBody); err != nil {
 		return err
 	}
 
@@ -190,11 +191,11 @@ func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
 }
 
 // GetUserInfo gets a user's information
-func (c *Client) GetUserInfo(userName string) (*git.User, error) {
+func (c *Client) GetUserInfo(ctx context.Context, userName string) (*git.User, error) {
 	// userName is string!
 	apiURL := fmt.Sprintf("%s/users/%s", c.GitAPIURL, userName)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -212,11 +213,11 @@ func (c *Client) GetUserInfo(userName string) (*git.User, error) {
 }
 
 // CanUserWriteToRepo decides if the user has write permission on the repo
-func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
+func (c *Client) CanUserWriteToRepo(ctx context.Context, user git.User) (bool, error) {
 	// userName is string!
 	apiURL := fmt.Sprintf("%s/repos/%s/collaborators/%s/permission", c.GitAPIURL, c.GitRepository, user.Name)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return false, err
 	}
@@ -230,25 +231,25 @@ func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
 }
 
 // RegisterComment registers comment to an issue
-func (c *Client) RegisterComment(_ git.IssueType, issueNo int, body string) error {
+func (c *Client) RegisterComment(ctx context.Context, _ git.IssueType, issueNo int, body string) error {
 	apiURL := fmt.Sprintf("%s/repos/%s/issues/%d/comments", c.GitAPIURL, c.GitRepository, issueNo)
 
 	commentBody := &CommentBody{Body: body}
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, commentBody); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, commentBody); err != nil {
 		return err
 	}
 	return nil
 }
 
 // ListPullRequests gets pull request list
-func (c *Client) ListPullRequests(onlyOpen bool) ([]git.PullRequest, error) {
+func (c *Client) ListPullRequests(ctx context.Context, onlyOpen bool) ([]git.PullRequest, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls", c.GitAPIURL, c.GitRepository)
 	if !onlyOpen {
 		apiURL += "?state=all"
 	}
 
 	var prs []PullRequest
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]PullRequest{}
 	}, func(i interface{}) {
 		prs = append(prs, *i.(*[]PullRequest)...)
@@ -268,10 +269,10 @@ func (c *Client) ListPullRequests(onlyOpen bool) ([]git.PullRequest, error) {
 }
 
 // GetPullRequest gets PR given id
-func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
+func (c *Client) GetPullRequest(ctx context.Context, id int) (*git.PullRequest, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls/%d", c.GitAPIURL, c.GitRepository, id)
 
-	data, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	data, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -285,7 +286,7 @@ func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
 }
 
 // MergePullRequest merges a pull request
-func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, message string) error {
+func (c *Client) MergePullRequest(ctx context.Context, id int, sha string, method git.MergeMethod, message string) error {
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls/%d/merge", c.GitAPIURL, c.GitRepository, id)
 
 	tokens := strings.Split(message, "\n\n")
@@ -300,7 +301,7 @@ func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, me
 		body.CommitMessage = strings.Join(tokens[1:], "\n\n")
 	}
 
-	_, _, err := c.requestHTTP(http.MethodPut, apiURL, body)
+	_, _, err := c.requestHTTP(ctx, http.MethodPut, apiURL, body)
 	if err != nil {
 		return err
 	}
@@ -309,9 +310,9 @@ func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, me
 }
 
 // GetPullRequestDiff gets diff of the pull request
-func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
+func (c *Client) GetPullRequestDiff(ctx context.Context, id int) (*git.Diff, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls/%d/files", c.GitAPIURL, c.GitRepository, id)
-	rawDiffs, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	rawDiffs, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -340,10 +341,10 @@ func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
 }
 
 // ListPullRequestCommits lists commits list of a pull request
-func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
+func (c *Client) ListPullRequestCommits(ctx context.Context, id int) ([]git.Commit, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/pulls/%d/commits", c.GitAPIURL, c.GitRepository, id)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -373,10 +374,10 @@ func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
 }
 
 // SetLabel sets label to the issue id
-func (c *Client) SetLabel(_ git.IssueType, id int, label string) error {
+func (c *Client) SetLabel(ctx context.Context, _ git.IssueType, id int, label string) error {
 	apiURL := fmt.Sprintf("%s/repos/%s/issues/%d/labels", c.GitAPIURL, c.GitRepository, id)
 
-	_, _, err := c.requestHTTP(http.MethodPost, apiURL, []LabelBody{{Name: label}})
+	_, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, []LabelBody{{Name: label}})
 	if err != nil {
 		return err
 	}
@@ -385,10 +386,10 @@ func (c *Client) SetLabel(_ git.IssueType, id int, label string) error {
 }
 
 // DeleteLabel deletes label from the issue id
-func (c *Client) DeleteLabel(_ git.IssueType, id int, label string) error {
+func (c *Client) DeleteLabel(ctx context.Context, _ git.IssueType, id int, label string) error {
 	apiURL := fmt.Sprintf("%s/repos/%s/issues/%d/labels/%s", c.GitAPIURL, c.GitRepository, id, label)
 
-	_, _, err := c.requestHTTP(http.MethodDelete, apiURL, nil)
+	_, _, err := c.requestHTTP(ctx, http.MethodDelete, apiURL, nil)
 	if err != nil {
 		return err
 	}
@@ -397,10 +398,10 @@ func (c *Client) DeleteLabel(_ git.IssueType, id int, label string) error {
 }
 
 // GetBranch gets branch info
-func (c *Client) GetBranch(branch string) (*git.Branch, error) {
+func (c *Client) GetBranch(ctx context.Context, branch string) (*git.Branch, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/branches/%s", c.GitAPIURL, c.GitRepository, branch)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -414,10 +415,10 @@ func (c *Client) GetBranch(branch string) (*git.Branch, error) {
 }
 
 // GetManifestInfos gets info to download manifests
-func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string) ([]string, error) {
+func (c *Client) GetManifestInfos(ctx context.Context, path, revision string, manifestInfos []string) ([]string, error) {
 	apiURL := fmt.Sprintf("%s/repos/%s/contents/%s?ref=%s", c.GitAPIURL, c.GitRepository, path, revision)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -437,7 +438,7 @@ func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string)
 		case string(ContentTypeFile):
 			manifestInfos = append(manifestInfos, content.DownloadURL)
 		case string(ContentTypeDir):
-			manifestInfos, err = c.GetManifestInfos(content.Path, revision, manifestInfos)
+			manifestInfos, err = c.GetManifestInfos(ctx, content.Path, revision, manifestInfos)
 			if err != nil {
 				return nil, err
 			}
@@ -447,11 +448,11 @@ func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string)
 	return manifestInfos, nil
 }
 
-// ObjectFromManifest returns unstructured objects from a raw manifest file
-func (c *Client) ObjectFromManifest(info, namespace string) ([]*unstructured.Unstructured, error) {
+// ObjectFromManifest returns unstructured objects from a raw manifest file, as they're written
+func (c *Client) ObjectFromManifest(ctx context.Context, info string) ([]*unstructured.Unstructured, error) {
 	var manifestRawObjs []*unstructured.Unstructured
 
-	raw, _, err := c.requestHTTP(http.MethodGet, info, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, info, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -475,9 +476,6 @@ func (c *Client) ObjectFromManifest(info, namespace string) ([]*unstructured.Uns
 			return nil, err
 		}
 
-		if len(manifestRawObj.GetNamespace()) == 0 {
-			manifestRawObj.SetNamespace(namespace)
-		}
 		manifestRawObjs = append(manifestRawObjs, manifestRawObj)
 	}
 	return manifestRawObjs, nil
@@ -505,8 +503,8 @@ func convertPullRequestToShared(pr *PullRequest) *git.PullRequest {
 	}
 }
 
-func (c *Client) requestHTTP(method, apiURL string, data interface{}) ([]byte, http.Header, error) {
-	return git.RequestHTTP(method, apiURL, c.header, data)
+func (c *Client) requestHTTP(ctx context.Context, method, apiURL string, data interface{}) ([]byte, http.Header, error) {
+	return git.RequestHTTP(ctx, method, apiURL, c.header, data)
 }
 
 // IsValidPayload validates the webhook payload
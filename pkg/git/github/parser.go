@@ -17,6 +17,7 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"strconv"
 	"strings"
@@ -24,7 +25,7 @@ import (
 	"github.com/tmax-cloud/cd-operator/pkg/git"
 )
 
-func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePullRequestWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	var data PullRequestWebhook
 	if err := json.Unmarshal(jsonString, &data); err != nil {
 		return nil, err
@@ -33,7 +34,7 @@ func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error
 	pullRequest := git.PullRequest{ID: data.Number, Title: data.PullRequest.Title, URL: data.Repo.URL, State: git.PullRequestState(data.PullRequest.State), Action: git.PullRequestAction(data.Action)}
 
 	// Get sender & author
-	sender, author := c.getSenderAuthor(data.Sender, data.PullRequest.User)
+	sender, author := c.getSenderAuthor(ctx, data.Sender, data.PullRequest.User)
 	pullRequest.Author = *author
 
 	for _, l := range data.PullRequest.Labels {
@@ -51,7 +52,7 @@ func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error
 	return &git.Webhook{EventType: git.EventTypePullRequest, Repo: repo, PullRequest: &pullRequest, Sender: *sender}, nil
 }
 
-func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePushWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	var data PushWebhook
 
 	if err := json.Unmarshal(jsonString, &data); err != nil {
@@ -65,7 +66,7 @@ func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
 	push := git.Push{Ref: data.Ref, Sha: data.Sha}
 
 	// Get sender email
-	userInfo, err := c.GetUserInfo(data.Sender.Name)
+	userInfo, err := c.GetUserInfo(ctx, data.Sender.Name)
 	if err == nil {
 		sender.Email = userInfo.Email
 	}
@@ -73,7 +74,7 @@ func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
 	return &git.Webhook{EventType: git.EventTypePush, Repo: repo, Sender: sender, Push: &push}, nil
 }
 
-func (c *Client) parseIssueCommentWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parseIssueCommentWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	issueComment := &IssueCommentWebhook{}
 	if err := json.Unmarshal(jsonString, issueComment); err != nil {
 		return nil, err
@@ -92,14 +93,14 @@ func (c *Client) parseIssueCommentWebhook(jsonString []byte) (*git.Webhook, erro
 		if err != nil {
 			return nil, err
 		}
-		pr, err = c.GetPullRequest(prID)
+		pr, err = c.GetPullRequest(ctx, prID)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	// Get sender & author
-	sender, author := c.getSenderAuthor(issueComment.Sender, issueComment.Comment.User)
+	sender, author := c.getSenderAuthor(ctx, issueComment.Sender, issueComment.Comment.User)
 
 	return &git.Webhook{EventType: git.EventTypeIssueComment, Repo: git.Repository{
 		Name: issueComment.Repo.Name,
@@ -118,7 +119,7 @@ func (c *Client) parseIssueCommentWebhook(jsonString []byte) (*git.Webhook, erro
 		}}, nil
 }
 
-func (c *Client) parsePullRequestReviewWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePullRequestReviewWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	review := &PullRequestReviewWebhook{}
 	if err := json.Unmarshal(jsonString, review); err != nil {
 		return nil, err
@@ -130,7 +131,7 @@ func (c *Client) parsePullRequestReviewWebhook(jsonString []byte) (*git.Webhook,
 	}
 
 	// Get sender & author
-	sender, author := c.getSenderAuthor(review.Sender, review.Review.User)
+	sender, author := c.getSenderAuthor(ctx, review.Sender, review.Review.User)
 
 	return &git.Webhook{EventType: git.EventTypePullRequestReview, Repo: git.Repository{
 		Name: review.Repo.Name,
@@ -150,7 +151,7 @@ func (c *Client) parsePullRequestReviewWebhook(jsonString []byte) (*git.Webhook,
 		}}, nil
 }
 
-func (c *Client) parsePullRequestReviewCommentWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePullRequestReviewCommentWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	reviewComment := &PullRequestReviewCommentWebhook{}
 	if err := json.Unmarshal(jsonString, reviewComment); err != nil {
 		return nil, err
@@ -162,7 +163,7 @@ func (c *Client) parsePullRequestReviewCommentWebhook(jsonString []byte) (*git.W
 	}
 
 	// Get sender & author
-	sender, author := c.getSenderAuthor(reviewComment.Sender, reviewComment.Comment.User)
+	sender, author := c.getSenderAuthor(ctx, reviewComment.Sender, reviewComment.Comment.User)
 
 	return &git.Webhook{EventType: git.EventTypePullRequestReviewComment, Repo: git.Repository{
 		Name: reviewComment.Repo.Name,
@@ -181,9 +182,9 @@ func (c *Client) parsePullRequestReviewCommentWebhook(jsonString []byte) (*git.W
 		}}, nil
 }
 
-func (c *Client) getSenderAuthor(senderPre, authorPre User) (*git.User, *git.User) {
+func (c *Client) getSenderAuthor(ctx context.Context, senderPre, authorPre User) (*git.User, *git.User) {
 	// Get sender & email
-	sender, err := c.GetUserInfo(senderPre.Name)
+	sender, err := c.GetUserInfo(ctx, senderPre.Name)
 	if err != nil {
 		sender = &git.User{Name: senderPre.Name, ID: senderPre.ID}
 	}
@@ -193,7 +194,7 @@ func (c *Client) getSenderAuthor(senderPre, authorPre User) (*git.User, *git.Use
 	if sender.ID == authorPre.ID {
 		author = sender
 	} else {
-		author, err = c.GetUserInfo(authorPre.Name)
+		author, err = c.GetUserInfo(ctx, authorPre.Name)
 		if err != nil {
 			author = &git.User{Name: authorPre.Name, ID: authorPre.ID}
 		}
@@ -17,6 +17,7 @@
 package gitlab
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -55,7 +56,7 @@ func (c *Client) Init() error {
 }
 
 // ParseWebhook parses a webhook body for gitlab
-func (c *Client) ParseWebhook(header http.Header, jsonString []byte) (*git.Webhook, error) {
+func (c *Client) ParseWebhook(ctx context.Context, header http.Header, jsonString []byte) (*git.Webhook, error) {
 	if err := Validate(c.GitWebhookSecret, header.Get("x-gitlab-token")); err != nil {
 		return nil, err
 	}
@@ -63,23 +64,23 @@ func (c *Client) ParseWebhook(header http.Header, jsonString []byte) (*git.Webho
 	eventFromHeader := header.Get("x-gitlab-event")
 	switch eventFromHeader {
 	case "Merge Request Hook":
-		return c.parsePullRequestWebhook(jsonString)
+		return c.parsePullRequestWebhook(ctx, jsonString)
 	case "Push Hook", "Tag Push Hook":
-		return c.parsePushWebhook(jsonString)
+		return c.parsePushWebhook(ctx, jsonString)
 	case "Note Hook":
-		return c.parseIssueComment(jsonString)
+		return c.parseIssueComment(ctx, jsonString)
 	}
 
 	return nil, nil
 }
 
 // ListWebhook lists registered webhooks
-func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
+func (c *Client) ListWebhook(ctx context.Context) ([]git.WebhookEntry, error) {
 	encodedRepoPath := url.QueryEscape(c.GitRepository)
 	apiURL := c.GitAPIURL + "/api/v4/projects/" + encodedRepoPath + "/hooks"
 
 	var entries []WebhookEntry
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]WebhookEntry{}
 	}, func(i interface{}) {
 		entries = append(entries, *i.(*[]WebhookEntry)...)
@@ -97,7 +98,7 @@ func (c *Client) ListWebhook() ([]git.WebhookEntry, error) {
 }
 
 // RegisterWebhook registers our webhook server to the remote git server
-func (c *Client) RegisterWebhook(uri string) error {
+func (c *Client) RegisterWebhook(ctx context.Context, uri string) error {
 	var registrationBody RegistrationWebhookBody
 	EncodedRepoPath := url.QueryEscape(c.GitRepository)
 	apiURL := c.GitAPIURL + "/api/v4/projects/" + EncodedRepoPath + "/hooks"
@@ -119,7 +120,7 @@ func (c *Client) RegisterWebhook(uri string) error {
 	registrationBody.ID = EncodedRepoPath
 	registrationBody.Token = c.GitWebhookSecret
 
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, registrationBody); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, registrationBody); err != nil {
 		return err
 	}
 
@@ -127,11 +128,11 @@ func (c *Client) RegisterWebhook(uri string) error {
 }
 
 // DeleteWebhook deletes registered webhook
-func (c *Client) DeleteWebhook(id int) error {
+func (c *Client) DeleteWebhook(ctx context.Context, id int) error {
 	encodedRepoPath := url.QueryEscape(c.GitRepository)
 	apiURL := c.GitAPIURL + "/api/v4/projects/" + encodedRepoPath + "/hooks/" + strconv.Itoa(id)
 
-	if _, _, err := c.requestHTTP(http.MethodDelete, apiURL, nil); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodDelete, apiURL, nil); err != nil {
 		return err
 	}
 
@@ -139,12 +140,12 @@ func (c *Client) DeleteWebhook(id int) error {
 }
 
 // ListCommitStatuses lists commit status of the specific commit
-func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
+func (c *Client) ListCommitStatuses(ctx context.Context, ref string) ([]git.CommitStatus, error) {
 	var urlEncodePath = url.QueryEscape(c.GitRepository)
 	apiURL := c.GitAPIURL + "/api/v4/projects/" + urlEncodePath + "/repository/commits/" + ref + "/statuses"
 
 	var statuses []CommitStatusResponse
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]CommitStatusResponse{}
 	}, func(i interface{}) {
 		statuses = append(statuses, *i.(*[]CommitStatusResponse)...)
@@ -174,7 +175,7 @@ func (c *Client) ListCommitStatuses(ref string) ([]git.CommitStatus, error) {
 }
 
 // SetCommitStatus sets commit status for the specific commit
-func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
+func (c *Client) SetCommitStatus(ctx context.Context, sha string, status git.CommitStatus) error {
 	var commitStatusBody CommitStatusRequest
 	var urlEncodePath = url.QueryEscape(c.GitRepository)
 
@@ -197,7 +198,7 @@ func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
 	commitStatusBody.Context = status.Context
 
 	// Cannot transition status via :run from :running
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, commitStatusBody); err != nil && !strings.Contains(strings.ToLower(err.Error()), "cannot transition status via") {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, commitStatusBody); err != nil && !strings.Contains(strings.ToLower(err.Error()), "cannot transition status via") {
 		return err
 	}
 
@@ -205,11 +206,11 @@ func (c *Client) SetCommitStatus(sha string, status git.CommitStatus) error {
 }
 
 // GetUserInfo gets a user's information
-func (c *Client) GetUserInfo(userID string) (*git.User, error) {
+func (c *Client) GetUserInfo(ctx context.Context, userID string) (*git.User, error) {
 	// userID is int!
 	apiURL := fmt.Sprintf("%s/api/v4/users/%s", c.GitAPIURL, userID)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -232,11 +233,11 @@ func (c *Client) GetUserInfo(userID string) (*git.User, error) {
 }
 
 // CanUserWriteToRepo decides if the user has write permission on the repo
-func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
+func (c *Client) CanUserWriteToRepo(ctx context.Context, user git.User) (bool, error) {
 	// userID is int!
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/members/all/%d", c.GitAPIURL, url.QueryEscape(c.GitRepository), user.ID)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return false, err
 	}
@@ -250,7 +251,7 @@ func (c *Client) CanUserWriteToRepo(user git.User) (bool, error) {
 }
 
 // RegisterComment registers comment to an issue
-func (c *Client) RegisterComment(issueType git.IssueType, issueNo int, body string) error {
+func (c *Client) RegisterComment(ctx context.Context, issueType git.IssueType, issueNo int, body string) error {
 	var t string
 	switch issueType {
 	case git.IssueTypeIssue:
@@ -264,21 +265,21 @@ func (c *Client) RegisterComment(issueType git.IssueType, issueNo int, body stri
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/%s/%d/notes", c.GitAPIURL, url.QueryEscape(c.GitRepository), t, issueNo)
 
 	commentBody := &CommentBody{Body: body}
-	if _, _, err := c.requestHTTP(http.MethodPost, apiURL, commentBody); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPost, apiURL, commentBody); err != nil {
 		return err
 	}
 	return nil
 }
 
 // ListPullRequests gets pull request list
-func (c *Client) ListPullRequests(onlyOpen bool) ([]git.PullRequest, error) {
+func (c *Client) ListPullRequests(ctx context.Context, onlyOpen bool) ([]git.PullRequest, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests?with_merge_status_recheck=true", c.GitAPIURL, url.QueryEscape(c.GitRepository))
 	if onlyOpen {
 		apiURL += "&state=opened"
 	}
 
 	var mrs []MergeRequest
-	err := git.GetPaginatedRequest(apiURL, c.header, func() interface{} {
+	err := git.GetPaginatedRequest(ctx, apiURL, c.header, func() interface{} {
 		return &[]MergeRequest{}
 	}, func(i interface{}) {
 		mrs = append(mrs, *i.(*[]MergeRequest)...)
@@ -308,10 +309,10 @@ func (c *Client) ListPullRequests(onlyOpen bool) ([]git.PullRequest, error) {
 }
 
 // GetPullRequest gets pull request info
-func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
+func (c *Client) GetPullRequest(ctx context.Context, id int) (*git.PullRequest, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d", c.GitAPIURL, url.QueryEscape(c.GitRepository), id)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -322,7 +323,7 @@ func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
 
 	// Target Branch
 	// TODO - can we delete this logic...? it consumes another API token limit...
-	targetBranch, err := c.GetBranch(mr.TargetBranch)
+	targetBranch, err := c.GetBranch(ctx, mr.TargetBranch)
 	if err != nil {
 		return nil, err
 	}
@@ -344,7 +345,7 @@ func (c *Client) GetPullRequest(id int) (*git.PullRequest, error) {
 }
 
 // MergePullRequest merges a pull request
-func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, msg string) error {
+func (c *Client) MergePullRequest(ctx context.Context, id int, sha string, method git.MergeMethod, msg string) error {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d/merge", c.GitAPIURL, url.QueryEscape(c.GitRepository), id)
 
 	body := &MergeAcceptRequest{
@@ -359,7 +360,7 @@ func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, ms
 		body.MergeCommitMessage = msg
 	}
 
-	_, _, err := c.requestHTTP(http.MethodPut, apiURL, body)
+	_, _, err := c.requestHTTP(ctx, http.MethodPut, apiURL, body)
 	if err != nil {
 		return err
 	}
@@ -368,10 +369,10 @@ func (c *Client) MergePullRequest(id int, sha string, method git.MergeMethod, ms
 }
 
 // GetPullRequestDiff gets diff of the pull request
-func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
+func (c *Client) GetPullRequestDiff(ctx context.Context, id int) (*git.Diff, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d/changes", c.GitAPIURL, url.QueryEscape(c.GitRepository), id)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -401,10 +402,10 @@ func (c *Client) GetPullRequestDiff(id int) (*git.Diff, error) {
 }
 
 // ListPullRequestCommits lists commits list of a pull request
-func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
+func (c *Client) ListPullRequestCommits(ctx context.Context, id int) ([]git.Commit, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%d/commits", c.GitAPIURL, url.QueryEscape(c.GitRepository), id)
 
-	result, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	result, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -434,7 +435,7 @@ func (c *Client) ListPullRequestCommits(id int) ([]git.Commit, error) {
 }
 
 // SetLabel sets label to the issue id
-func (c *Client) SetLabel(issueType git.IssueType, id int, label string) error {
+func (c *Client) SetLabel(ctx context.Context, issueType git.IssueType, id int, label string) error {
 	var t string
 	switch issueType {
 	case git.IssueTypeIssue:
@@ -447,7 +448,7 @@ func (c *Client) SetLabel(issueType git.IssueType, id int, label string) error {
 
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/%s/%d", c.GitAPIURL, url.QueryEscape(c.GitRepository), t, id)
 
-	if _, _, err := c.requestHTTP(http.MethodPut, apiURL, UpdateMergeRequest{AddLabels: label}); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPut, apiURL, UpdateMergeRequest{AddLabels: label}); err != nil {
 		return err
 	}
 
@@ -455,7 +456,7 @@ func (c *Client) SetLabel(issueType git.IssueType, id int, label string) error {
 }
 
 // DeleteLabel deletes label from the issue id
-func (c *Client) DeleteLabel(issueType git.IssueType, id int, label string) error {
+func (c *Client) DeleteLabel(ctx context.Context, issueType git.IssueType, id int, label string) error {
 	var t string
 	switch issueType {
 	case git.IssueTypeIssue:
@@ -468,17 +469,17 @@ func (c *Client) DeleteLabel(issueType git.IssueType, id int, label string) erro
 
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/%s/%d", c.GitAPIURL, url.QueryEscape(c.GitRepository), t, id)
 
-	if _, _, err := c.requestHTTP(http.MethodPut, apiURL, UpdateMergeRequest{RemoveLabels: label}); err != nil {
+	if _, _, err := c.requestHTTP(ctx, http.MethodPut, apiURL, UpdateMergeRequest{RemoveLabels: label}); err != nil {
 		return err
 	}
 	return nil
 }
 
 // GetBranch gets branch info
-func (c *Client) GetBranch(branch string) (*git.Branch, error) {
+func (c *Client) GetBranch(ctx context.Context, branch string) (*git.Branch, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/branches/%s", c.GitAPIURL, url.QueryEscape(c.GitRepository), branch)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -492,10 +493,10 @@ func (c *Client) GetBranch(branch string) (*git.Branch, error) {
 }
 
 // GetManifestInfos gets info to download manifests
-func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string) ([]string, error) {
+func (c *Client) GetManifestInfos(ctx context.Context, path, revision string, manifestInfos []string) ([]string, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/tree?path=%s&ref=%s", c.GitAPIURL, url.QueryEscape(c.GitRepository), path, revision)
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -510,7 +511,7 @@ func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string)
 		case string(RepoTypeBlob):
 			manifestInfos = append(manifestInfos, repo.ID)
 		case string(RepoTypeTree):
-			manifestInfos, err = c.GetManifestInfos(repo.Path, revision, manifestInfos)
+			manifestInfos, err = c.GetManifestInfos(ctx, repo.Path, revision, manifestInfos)
 			if err != nil {
 				return nil, err
 			}
@@ -520,12 +521,12 @@ func (c *Client) GetManifestInfos(path, revision string, manifestInfos []string)
 	return manifestInfos, nil
 }
 
-// ObjectFromManifest returns unstructured objects from a raw manifest file
-func (c *Client) ObjectFromManifest(info, namespace string) ([]*unstructured.Unstructured, error) {
+// ObjectFromManifest returns unstructured objects from a raw manifest file, as they're written
+func (c *Client) ObjectFromManifest(ctx context.Context, info string) ([]*unstructured.Unstructured, error) {
 	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/repository/blobs/%s/raw", c.GitAPIURL, url.QueryEscape(c.GitRepository), info)
 	var manifestRawObjs []*unstructured.Unstructured
 
-	raw, _, err := c.requestHTTP(http.MethodGet, apiURL, nil)
+	raw, _, err := c.requestHTTP(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -549,16 +550,13 @@ func (c *Client) ObjectFromManifest(info, namespace string) ([]*unstructured.Uns
 			return nil, err
 		}
 
-		if len(manifestRawObj.GetNamespace()) == 0 {
-			manifestRawObj.SetNamespace(namespace)
-		}
 		manifestRawObjs = append(manifestRawObjs, manifestRawObj)
 	}
 	return manifestRawObjs, nil
 }
 
-func (c *Client) requestHTTP(method, apiURL string, data interface{}) ([]byte, http.Header, error) {
-	return git.RequestHTTP(method, apiURL, c.header, data)
+func (c *Client) requestHTTP(ctx context.Context, method, apiURL string, data interface{}) ([]byte, http.Header, error) {
+	return git.RequestHTTP(ctx, method, apiURL, c.header, data)
 }
 
 func convertState(original string) git.PullRequestState {
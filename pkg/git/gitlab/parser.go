@@ -17,6 +17,7 @@
 package gitlab
 
 import (
+	"context"
 	"encoding/json"
 	"strconv"
 	"strings"
@@ -25,13 +26,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePullRequestWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	var data MergeRequestWebhook
 	if err := json.Unmarshal(jsonString, &data); err != nil {
 		return nil, err
 	}
 
-	sender, author, err := c.getSenderAuthor(data.User, data.ObjectAttribute.AuthorID)
+	sender, author, err := c.getSenderAuthor(ctx, data.User, data.ObjectAttribute.AuthorID)
 	if err != nil {
 		return nil, err
 	}
@@ -61,11 +62,11 @@ func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error
 			}
 		}
 	case "approved", "unapproved":
-		return c.parsePullRequestReviewWebhook(data)
+		return c.parsePullRequestReviewWebhook(ctx, data)
 	}
 
 	// Get Target branch
-	baseBranch, err := c.GetBranch(data.ObjectAttribute.BaseRef)
+	baseBranch, err := c.GetBranch(ctx, data.ObjectAttribute.BaseRef)
 	if err != nil {
 		return nil, err
 	}
@@ -86,7 +87,7 @@ func (c *Client) parsePullRequestWebhook(jsonString []byte) (*git.Webhook, error
 	return &git.Webhook{EventType: git.EventTypePullRequest, Repo: repo, PullRequest: &pullRequest, Sender: *sender}, nil
 }
 
-func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parsePushWebhook(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	var data PushWebhook
 
 	if err := json.Unmarshal(jsonString, &data); err != nil {
@@ -100,7 +101,7 @@ func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
 	push := git.Push{Ref: data.Ref, Sha: data.Sha}
 
 	// Get sender email
-	userInfo, err := c.GetUserInfo(strconv.Itoa(data.UserID))
+	userInfo, err := c.GetUserInfo(ctx, strconv.Itoa(data.UserID))
 	if err == nil {
 		sender.Email = userInfo.Email
 	}
@@ -108,7 +109,7 @@ func (c *Client) parsePushWebhook(jsonString []byte) (*git.Webhook, error) {
 	return &git.Webhook{EventType: git.EventTypePush, Repo: repo, Sender: sender, Push: &push}, nil
 }
 
-func (c *Client) parseIssueComment(jsonString []byte) (*git.Webhook, error) {
+func (c *Client) parseIssueComment(ctx context.Context, jsonString []byte) (*git.Webhook, error) {
 	data := &NoteHook{}
 
 	if err := json.Unmarshal(jsonString, data); err != nil {
@@ -128,7 +129,7 @@ func (c *Client) parseIssueComment(jsonString []byte) (*git.Webhook, error) {
 		return nil, nil
 	}
 
-	sender, author, err := c.getSenderAuthor(data.User, data.ObjectAttributes.AuthorID)
+	sender, author, err := c.getSenderAuthor(ctx, data.User, data.ObjectAttributes.AuthorID)
 	if err != nil {
 		return nil, err
 	}
@@ -137,12 +138,12 @@ func (c *Client) parseIssueComment(jsonString []byte) (*git.Webhook, error) {
 	var pr *git.PullRequest
 	if data.MergeRequest.TargetBranch != "" {
 		// Get User info
-		mrAuthor, err := c.GetUserInfo(strconv.Itoa(data.MergeRequest.AuthorID))
+		mrAuthor, err := c.GetUserInfo(ctx, strconv.Itoa(data.MergeRequest.AuthorID))
 		if err != nil {
 			mrAuthor = &git.User{ID: data.MergeRequest.AuthorID}
 		}
 		// Get Target branch
-		baseBranch, err := c.GetBranch(data.MergeRequest.TargetBranch)
+		baseBranch, err := c.GetBranch(ctx, data.MergeRequest.TargetBranch)
 		if err != nil {
 			return nil, err
 		}
@@ -180,7 +181,7 @@ func (c *Client) parseIssueComment(jsonString []byte) (*git.Webhook, error) {
 		}}, nil
 }
 
-func (c *Client) parsePullRequestReviewWebhook(data MergeRequestWebhook) (*git.Webhook, error) {
+func (c *Client) parsePullRequestReviewWebhook(ctx context.Context, data MergeRequestWebhook) (*git.Webhook, error) {
 	state := git.PullRequestState(data.ObjectAttribute.State)
 	switch string(state) {
 	case "opened":
@@ -197,12 +198,12 @@ func (c *Client) parsePullRequestReviewWebhook(data MergeRequestWebhook) (*git.W
 	commentAuthor := sender
 
 	// Get User info
-	mrAuthor, err := c.GetUserInfo(strconv.Itoa(data.ObjectAttribute.AuthorID))
+	mrAuthor, err := c.GetUserInfo(ctx, strconv.Itoa(data.ObjectAttribute.AuthorID))
 	if err != nil {
 		mrAuthor = &git.User{ID: data.ObjectAttribute.AuthorID}
 	}
 	// Get Target branch
-	baseBranch, err := c.GetBranch(data.ObjectAttribute.BaseRef)
+	baseBranch, err := c.GetBranch(ctx, data.ObjectAttribute.BaseRef)
 	if err != nil {
 		return nil, err
 	}
@@ -240,13 +241,13 @@ func (c *Client) parsePullRequestReviewWebhook(data MergeRequestWebhook) (*git.W
 	}, nil
 }
 
-func (c *Client) getSenderAuthor(senderPre User, authorID int) (*git.User, *git.User, error) {
+func (c *Client) getSenderAuthor(ctx context.Context, senderPre User, authorID int) (*git.User, *git.User, error) {
 	sender := git.User{ID: senderPre.ID, Name: senderPre.Name, Email: senderPre.Email}
 	var author git.User
 	if sender.ID == authorID {
 		author = sender
 	} else {
-		user, err := c.GetUserInfo(strconv.Itoa(authorID))
+		user, err := c.GetUserInfo(ctx, strconv.Itoa(authorID))
 		if err != nil {
 			return nil, nil, err
 		}
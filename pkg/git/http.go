@@ -18,6 +18,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -26,7 +27,7 @@ import (
 )
 
 // GetPaginatedRequest gets paginated APIs and accumulates them together
-func GetPaginatedRequest(apiURL string, header map[string]string, newObj func() interface{}, accumulate func(interface{})) error {
+func GetPaginatedRequest(ctx context.Context, apiURL string, header map[string]string, newObj func() interface{}, accumulate func(interface{})) error {
 	u, err := url.Parse(apiURL)
 	if err != nil {
 		return err
@@ -38,7 +39,7 @@ func GetPaginatedRequest(apiURL string, header map[string]string, newObj func()
 	}
 	uri := u.String()
 	for {
-		data, h, err := RequestHTTP(http.MethodGet, uri, header, nil)
+		data, h, err := RequestHTTP(ctx, http.MethodGet, uri, header, nil)
 		if err != nil {
 			return err
 		}
@@ -64,8 +65,8 @@ func GetPaginatedRequest(apiURL string, header map[string]string, newObj func()
 	return nil
 }
 
-// RequestHTTP requests api call
-func RequestHTTP(method string, uri string, header map[string]string, data interface{}) ([]byte, http.Header, error) {
+// RequestHTTP requests api call. The request is cancelled when the context is done
+func RequestHTTP(ctx context.Context, method string, uri string, header map[string]string, data interface{}) ([]byte, http.Header, error) {
 	var jsonBytes []byte
 	var err error
 
@@ -76,7 +77,7 @@ func RequestHTTP(method string, uri string, header map[string]string, data inter
 		}
 	}
 
-	req, err := http.NewRequest(method, uri, bytes.NewBuffer(jsonBytes))
+	req, err := http.NewRequestWithContext(ctx, method, uri, bytes.NewBuffer(jsonBytes))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func getJobHealth(obj *unstructured.Unstructured) (*Status, error) {
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job); err != nil {
+		return nil, err
+	}
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobFailed:
+			return &Status{Status: cdv1.HealthStatusDegraded, Message: cond.Message}, nil
+		case batchv1.JobComplete:
+			return &Status{Status: cdv1.HealthStatusHealthy, Message: cond.Message}, nil
+		}
+	}
+
+	if job.Spec.Suspend != nil && *job.Spec.Suspend {
+		return &Status{Status: cdv1.HealthStatusSuspended, Message: "Job is suspended"}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusProgressing, Message: "Job is running"}, nil
+}
+
+func getCronJobHealth(obj *unstructured.Unstructured) (*Status, error) {
+	// batch/v1beta1 and batch/v1 CronJobs share the fields used here
+	cronJob := &batchv1.CronJob{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, cronJob); err != nil {
+		return nil, err
+	}
+
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return &Status{Status: cdv1.HealthStatusSuspended, Message: "CronJob is suspended"}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
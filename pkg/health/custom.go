@@ -0,0 +1,211 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// HealthCheck is a user-defined health check for the resources of a group/kind, declared in the cd-config configmap.
+// e.g.,
+//
+//	resourceHealthChecks: |
+//	  - group: cert-manager.io
+//	    kind: Certificate
+//	    rules:
+//	    - jsonPath: '{.status.conditions[?(@.type=="Ready")].status}'
+//	      values: ["True"]
+//	      status: Healthy
+//	    - jsonPath: '{.status.conditions[?(@.type=="Ready")].status}'
+//	      values: ["False"]
+//	      status: Degraded
+//	      messageJSONPath: '{.status.conditions[?(@.type=="Ready")].message}'
+type HealthCheck struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+
+	// Rules are evaluated in order, and the first matched rule decides the health status.
+	// If no rule is matched, the resource is regarded as progressing
+	Rules []HealthRule `json:"rules"`
+}
+
+// HealthRule maps the result of a JSONPath expression to a health status
+type HealthRule struct {
+	// JSONPath is a JSONPath template evaluated against the live object, e.g., {.status.phase}
+	JSONPath string `json:"jsonPath"`
+
+	// Values to be matched with the result of the JSONPath.
+	// If it's empty, the rule is matched when the result is not empty
+	Values []string `json:"values,omitempty"`
+
+	// Status is a health status of the resource when the rule is matched (Healthy/Progressing/Degraded/Suspended)
+	Status cdv1.HealthStatusCode `json:"status"`
+
+	// Message is a message of the health status
+	Message string `json:"message,omitempty"`
+
+	// MessageJSONPath is a JSONPath template whose result is used as a message of the health status
+	MessageJSONPath string `json:"messageJSONPath,omitempty"`
+}
+
+// Condition type, which is used by the default health check
+const conditionTypeReady = "Ready"
+
+// Parsed health checks from configs.ResourceHealthChecks
+var (
+	customHealthChecksRaw string
+	customHealthChecks    map[string]HealthCheck
+	customHealthChecksMu  sync.Mutex
+)
+
+// getCustomHealthCheck returns a user-defined health check for the group/kind
+func getCustomHealthCheck(groupKind string) (HealthCheck, bool) {
+	customHealthChecksMu.Lock()
+	defer customHealthChecksMu.Unlock()
+
+	// Parse again only if the config is changed
+	raw := configs.ResourceHealthChecks
+	if customHealthChecks == nil || raw != customHealthChecksRaw {
+		checks, err := ParseHealthChecks(raw)
+		if err != nil {
+			log.Error(err, "ParseHealthChecks failed..")
+		}
+		customHealthChecksRaw = raw
+		customHealthChecks = checks
+	}
+
+	check, exist := customHealthChecks[groupKind]
+	return check, exist
+}
+
+// ParseHealthChecks parses a yaml list of health checks and validates them. It returns health checks keyed by group/kind
+func ParseHealthChecks(raw string) (map[string]HealthCheck, error) {
+	checks := map[string]HealthCheck{}
+	if strings.TrimSpace(raw) == "" {
+		return checks, nil
+	}
+
+	var list []HealthCheck
+	if err := yaml.Unmarshal([]byte(raw), &list); err != nil {
+		return checks, err
+	}
+
+	for _, check := range list {
+		if err := validateHealthCheck(check); err != nil {
+			return map[string]HealthCheck{}, err
+		}
+		checks[check.Group+"/"+check.Kind] = check
+	}
+	return checks, nil
+}
+
+func validateHealthCheck(check HealthCheck) error {
+	if check.Kind == "" {
+		return fmt.Errorf("kind of the health check for group %q is empty", check.Group)
+	}
+	for _, rule := range check.Rules {
+		switch rule.Status {
+		case cdv1.HealthStatusHealthy, cdv1.HealthStatusProgressing, cdv1.HealthStatusDegraded, cdv1.HealthStatusSuspended:
+		default:
+			return fmt.Errorf("health check for %s/%s has an invalid status %q", check.Group, check.Kind, rule.Status)
+		}
+		if _, err := evaluateJSONPath(rule.JSONPath, nil); err != nil {
+			return fmt.Errorf("health check for %s/%s has an invalid jsonPath %q: %s", check.Group, check.Kind, rule.JSONPath, err.Error())
+		}
+		if rule.MessageJSONPath != "" {
+			if _, err := evaluateJSONPath(rule.MessageJSONPath, nil); err != nil {
+				return fmt.Errorf("health check for %s/%s has an invalid messageJSONPath %q: %s", check.Group, check.Kind, rule.MessageJSONPath, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// getCustomHealth assesses the health of the object using the user-defined health check
+func getCustomHealth(check HealthCheck, obj *unstructured.Unstructured) (*Status, error) {
+	for _, rule := range check.Rules {
+		result, err := evaluateJSONPath(rule.JSONPath, obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		if !matchValues(result, rule.Values) {
+			continue
+		}
+
+		msg := rule.Message
+		if rule.MessageJSONPath != "" {
+			msg, err = evaluateJSONPath(rule.MessageJSONPath, obj.Object)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return &Status{Status: rule.Status, Message: msg}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for health check to be matched"}, nil
+}
+
+// getDefaultHealth assesses the health of the object using the standard Ready condition.
+// It returns nil if the object does not have the Ready condition
+func getDefaultHealth(obj *unstructured.Unstructured) (*Status, error) {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		// Conditions of the resource are not in the standard form
+		return nil, nil
+	}
+
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != conditionTypeReady {
+			continue
+		}
+		msg, _ := cond["message"].(string)
+		switch cond["status"] {
+		case "True":
+			return &Status{Status: cdv1.HealthStatusHealthy, Message: msg}, nil
+		case "False":
+			return &Status{Status: cdv1.HealthStatusDegraded, Message: msg}, nil
+		default:
+			return &Status{Status: cdv1.HealthStatusProgressing, Message: msg}, nil
+		}
+	}
+	return nil, nil
+}
+
+// evaluateJSONPath evaluates the JSONPath template against the object. Missing keys result in an empty string.
+// If obj is nil, it only parses the template
+func evaluateJSONPath(template string, obj interface{}) (string, error) {
+	jp := jsonpath.New("health")
+	jp.AllowMissingKeys(true)
+	if err := jp.Parse(template); err != nil {
+		return "", err
+	}
+	if obj == nil {
+		return "", nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jp.Execute(buf, obj); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func matchValues(result string, values []string) bool {
+	if len(values) == 0 {
+		return result != ""
+	}
+	for _, v := range values {
+		if result == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+)
+
+const testHealthChecks = `
+- group: kafka.strimzi.io
+  kind: KafkaTopic
+  rules:
+  - jsonPath: '{.status.conditions[?(@.type=="Ready")].status}'
+    values: ["True"]
+    status: Healthy
+  - jsonPath: '{.status.conditions[?(@.type=="NotReady")].status}'
+    values: ["True"]
+    status: Degraded
+    messageJSONPath: '{.status.conditions[?(@.type=="NotReady")].message}'
+- group: example.com
+  kind: Database
+  rules:
+  - jsonPath: '{.status.phase}'
+    values: ["Running"]
+    status: Healthy
+  - jsonPath: '{.status.error}'
+    status: Degraded
+    message: Database has an error
+`
+
+type parseHealthChecksTestCase struct {
+	raw string
+
+	expectedErrOccur bool
+	expectedKeys     []string
+}
+
+func TestParseHealthChecks(t *testing.T) {
+	tc := map[string]parseHealthChecksTestCase{
+		"empty": {
+			raw:          "",
+			expectedKeys: nil,
+		},
+		"normal": {
+			raw:          testHealthChecks,
+			expectedKeys: []string{"example.com/Database", "kafka.strimzi.io/KafkaTopic"},
+		},
+		"invalidStatus": {
+			raw:              "- group: a\n  kind: B\n  rules:\n  - jsonPath: '{.status}'\n    status: Broken\n",
+			expectedErrOccur: true,
+		},
+		"invalidJSONPath": {
+			raw:              "- group: a\n  kind: B\n  rules:\n  - jsonPath: '{.status'\n    status: Healthy\n",
+			expectedErrOccur: true,
+		},
+		"noKind": {
+			raw:              "- group: a\n",
+			expectedErrOccur: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			checks, err := ParseHealthChecks(c.raw)
+			if c.expectedErrOccur {
+				require.Error(t, err)
+				require.Empty(t, checks)
+				return
+			}
+			require.NoError(t, err)
+			var keys []string
+			for k := range checks {
+				keys = append(keys, k)
+			}
+			require.ElementsMatch(t, c.expectedKeys, keys)
+		})
+	}
+}
+
+func TestGetResourceHealthCustom(t *testing.T) {
+	configs.ResourceHealthChecks = testHealthChecks
+	defer func() {
+		configs.ResourceHealthChecks = ""
+	}()
+
+	tc := map[string]getResourceHealthTestCase{
+		"customHealthy": {
+			obj: newObject("kafka.strimzi.io/v1beta2", "KafkaTopic", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"customDegraded": {
+			obj: newObject("kafka.strimzi.io/v1beta2", "KafkaTopic", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "NotReady", "status": "True", "message": "broken"}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"customNotMatched": {
+			obj:            newObject("kafka.strimzi.io/v1beta2", "KafkaTopic", nil, nil),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"customNonEmpty": {
+			obj:            newObject("example.com/v1", "Database", nil, map[string]interface{}{"phase": "Failed", "error": "disk full"}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"defaultReady": {
+			obj: newObject("cert-manager.io/v1", "Certificate", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"defaultReadyUnknown": {
+			obj: newObject("cert-manager.io/v1", "Certificate", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "Unknown"}}}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"defaultNoCondition": {
+			obj:         newObject("cert-manager.io/v1", "Certificate", nil, nil),
+			expectedNil: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			status, err := GetResourceHealth(c.obj)
+			require.NoError(t, err)
+			if c.expectedNil {
+				require.Nil(t, status)
+				return
+			}
+			require.NotNil(t, status)
+			require.Equal(t, c.expectedStatus, status.Status)
+		})
+	}
+
+	status, err := GetResourceHealth(newObject("kafka.strimzi.io/v1beta2", "KafkaTopic", nil,
+		map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "NotReady", "status": "True", "message": "broken"}}}))
+	require.NoError(t, err)
+	require.Equal(t, "broken", status.Message)
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"fmt"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("health")
+
+// Status is a health status of a resource
+type Status struct {
+	Status  cdv1.HealthStatusCode
+	Message string
+}
+
+// healthFunc assesses the health of a live object
+type healthFunc func(obj *unstructured.Unstructured) (*Status, error)
+
+// Health assessment functions for each group/kind
+var healthFuncs = map[string]healthFunc{
+	"apps/Deployment":                   getDeploymentHealth,
+	"extensions/Deployment":             getDeploymentHealth,
+	"apps/StatefulSet":                  getStatefulSetHealth,
+	"apps/DaemonSet":                    getDaemonSetHealth,
+	"extensions/DaemonSet":              getDaemonSetHealth,
+	"apps/ReplicaSet":                   getReplicaSetHealth,
+	"extensions/ReplicaSet":             getReplicaSetHealth,
+	"/Pod":                              getPodHealth,
+	"batch/Job":                         getJobHealth,
+	"batch/CronJob":                     getCronJobHealth,
+	"/PersistentVolumeClaim":            getPVCHealth,
+	"/Service":                          getServiceHealth,
+	"networking.k8s.io/Ingress":         getIngressHealth,
+	"extensions/Ingress":                getIngressHealth,
+	"apiregistration.k8s.io/APIService": getAPIServiceHealth,
+}
+
+// Order of the health statuses, from the best to the worst
+var healthOrder = []cdv1.HealthStatusCode{
+	cdv1.HealthStatusHealthy,
+	cdv1.HealthStatusSuspended,
+	cdv1.HealthStatusProgressing,
+	cdv1.HealthStatusMissing,
+	cdv1.HealthStatusDegraded,
+	cdv1.HealthStatusUnknown,
+}
+
+// GetResourceHealth assesses the health of the live object. A nil object is regarded as missing.
+// User-defined health checks take precedence over the built-in ones, and the standard Ready condition is used for
+// the other kinds. It returns nil if there is no health rule for the kind of the object
+func GetResourceHealth(obj *unstructured.Unstructured) (*Status, error) {
+	if obj == nil {
+		return &Status{Status: cdv1.HealthStatusMissing, Message: "Resource does not exist"}, nil
+	}
+
+	if obj.GetDeletionTimestamp() != nil {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Pending deletion"}, nil
+	}
+
+	gvk := obj.GroupVersionKind()
+	groupKind := gvk.Group + "/" + gvk.Kind
+
+	var f healthFunc
+	if check, exist := getCustomHealthCheck(groupKind); exist {
+		f = func(obj *unstructured.Unstructured) (*Status, error) {
+			return getCustomHealth(check, obj)
+		}
+	} else if builtin, exist := healthFuncs[groupKind]; exist {
+		f = builtin
+	} else {
+		f = getDefaultHealth
+	}
+
+	status, err := f(obj)
+	if err != nil {
+		return &Status{Status: cdv1.HealthStatusUnknown, Message: err.Error()}, err
+	}
+	return status, nil
+}
+
+// IsWorse returns true if the current status is worse than the new one
+func IsWorse(current, new cdv1.HealthStatusCode) bool {
+	return healthOrderIndex(new) > healthOrderIndex(current)
+}
+
+func healthOrderIndex(code cdv1.HealthStatusCode) int {
+	for i, c := range healthOrder {
+		if c == code {
+			return i
+		}
+	}
+	return len(healthOrder)
+}
+
+// Aggregate returns the worst status among the statuses. Statuses which are nil are ignored
+func Aggregate(statuses map[string]*Status) cdv1.HealthStatus {
+	result := cdv1.HealthStatus{Status: cdv1.HealthStatusHealthy}
+
+	for name, status := range statuses {
+		if status == nil {
+			continue
+		}
+		if IsWorse(result.Status, status.Status) {
+			result.Status = status.Status
+			result.Message = fmt.Sprintf("%s: %s", name, status.Message)
+		}
+	}
+	return result
+}
+
+func getPVCHealth(obj *unstructured.Unstructured) (*Status, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pvc); err != nil {
+		return nil, err
+	}
+
+	switch pvc.Status.Phase {
+	case corev1.ClaimLost:
+		return &Status{Status: cdv1.HealthStatusDegraded, Message: "Claim is lost"}, nil
+	case corev1.ClaimPending:
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Claim is pending"}, nil
+	case corev1.ClaimBound:
+		return &Status{Status: cdv1.HealthStatusHealthy}, nil
+	default:
+		return &Status{Status: cdv1.HealthStatusUnknown}, nil
+	}
+}
@@ -0,0 +1,164 @@
+package health
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type getResourceHealthTestCase struct {
+	obj *unstructured.Unstructured
+
+	expectedStatus cdv1.HealthStatusCode
+	expectedNil    bool
+}
+
+func TestGetResourceHealth(t *testing.T) {
+	tc := map[string]getResourceHealthTestCase{
+		"missing": {
+			obj:            nil,
+			expectedStatus: cdv1.HealthStatusMissing,
+		},
+		"noRule": {
+			obj:         newObject("v1", "ConfigMap", nil, nil),
+			expectedNil: true,
+		},
+		"deploymentHealthy": {
+			obj: newObject("apps/v1", "Deployment",
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(2)}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"deploymentPaused": {
+			obj:            newObject("apps/v1", "Deployment", map[string]interface{}{"paused": true}, nil),
+			expectedStatus: cdv1.HealthStatusSuspended,
+		},
+		"deploymentRollingOut": {
+			obj: newObject("apps/v1", "Deployment",
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"replicas": int64(3), "updatedReplicas": int64(2), "availableReplicas": int64(2)}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"deploymentUnavailable": {
+			obj: newObject("apps/v1", "Deployment",
+				map[string]interface{}{"replicas": int64(2)},
+				map[string]interface{}{"replicas": int64(2), "updatedReplicas": int64(2), "availableReplicas": int64(1)}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"deploymentDeadlineExceeded": {
+			obj: newObject("apps/v1", "Deployment", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Progressing", "status": "False", "reason": "ProgressDeadlineExceeded"}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"statefulSetHealthy": {
+			obj: newObject("apps/v1", "StatefulSet",
+				map[string]interface{}{"replicas": int64(1)},
+				map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(1), "updatedReplicas": int64(1), "currentRevision": "a", "updateRevision": "a"}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"statefulSetUpdating": {
+			obj: newObject("apps/v1", "StatefulSet",
+				map[string]interface{}{"replicas": int64(1)},
+				map[string]interface{}{"observedGeneration": int64(1), "readyReplicas": int64(1), "currentRevision": "a", "updateRevision": "b"}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"daemonSetProgressing": {
+			obj: newObject("apps/v1", "DaemonSet", nil,
+				map[string]interface{}{"desiredNumberScheduled": int64(3), "updatedNumberScheduled": int64(3), "numberAvailable": int64(2)}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"replicaSetFailure": {
+			obj: newObject("apps/v1", "ReplicaSet", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "ReplicaFailure", "status": "True"}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"podCrashLoop": {
+			obj: newObject("v1", "Pod", nil,
+				map[string]interface{}{"phase": "Running", "containerStatuses": []interface{}{map[string]interface{}{"name": "c", "state": map[string]interface{}{"waiting": map[string]interface{}{"reason": "CrashLoopBackOff"}}}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"podRunning": {
+			obj: newObject("v1", "Pod", nil,
+				map[string]interface{}{"phase": "Running", "containerStatuses": []interface{}{map[string]interface{}{"name": "c", "ready": true}}}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"jobComplete": {
+			obj: newObject("batch/v1", "Job", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Complete", "status": "True"}}}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"jobFailed": {
+			obj: newObject("batch/v1", "Job", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Failed", "status": "True"}}}),
+			expectedStatus: cdv1.HealthStatusDegraded,
+		},
+		"cronJobSuspended": {
+			obj:            newObject("batch/v1", "CronJob", map[string]interface{}{"suspend": true}, nil),
+			expectedStatus: cdv1.HealthStatusSuspended,
+		},
+		"pvcPending": {
+			obj:            newObject("v1", "PersistentVolumeClaim", nil, map[string]interface{}{"phase": "Pending"}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"serviceClusterIP": {
+			obj:            newObject("v1", "Service", map[string]interface{}{"type": "ClusterIP"}, nil),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"serviceLoadBalancerPending": {
+			obj:            newObject("v1", "Service", map[string]interface{}{"type": "LoadBalancer"}, nil),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+		"ingressAssigned": {
+			obj: newObject("networking.k8s.io/v1", "Ingress", nil,
+				map[string]interface{}{"loadBalancer": map[string]interface{}{"ingress": []interface{}{map[string]interface{}{"ip": "10.0.0.1"}}}}),
+			expectedStatus: cdv1.HealthStatusHealthy,
+		},
+		"apiServiceUnavailable": {
+			obj: newObject("apiregistration.k8s.io/v1", "APIService", nil,
+				map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Available", "status": "False"}}}),
+			expectedStatus: cdv1.HealthStatusProgressing,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			status, err := GetResourceHealth(c.obj)
+			require.NoError(t, err)
+			if c.expectedNil {
+				require.Nil(t, status)
+				return
+			}
+			require.NotNil(t, status)
+			require.Equal(t, c.expectedStatus, status.Status)
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	result := Aggregate(map[string]*Status{
+		"Deployment/a": {Status: cdv1.HealthStatusHealthy},
+		"ConfigMap/b":  nil,
+		"Job/c":        {Status: cdv1.HealthStatusProgressing, Message: "Job is running"},
+		"CronJob/d":    {Status: cdv1.HealthStatusSuspended},
+	})
+	require.Equal(t, cdv1.HealthStatus{Status: cdv1.HealthStatusProgressing, Message: "Job/c: Job is running"}, result)
+
+	require.Equal(t, cdv1.HealthStatusHealthy, Aggregate(nil).Status)
+}
+
+func newObject(apiVersion, kind string, spec, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": "test", "namespace": "default"},
+	}}
+	if spec != nil {
+		obj.Object["spec"] = spec
+	}
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
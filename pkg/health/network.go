@@ -0,0 +1,57 @@
+package health
+
+import (
+	"fmt"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func getServiceHealth(obj *unstructured.Unstructured) (*Status, error) {
+	serviceType, _, err := unstructured.NestedString(obj.Object, "spec", "type")
+	if err != nil {
+		return nil, err
+	}
+
+	if serviceType != "LoadBalancer" {
+		return &Status{Status: cdv1.HealthStatusHealthy}, nil
+	}
+	return getLoadBalancerHealth(obj)
+}
+
+func getIngressHealth(obj *unstructured.Unstructured) (*Status, error) {
+	return getLoadBalancerHealth(obj)
+}
+
+// getLoadBalancerHealth checks if the object (Service or Ingress) has been assigned a load balancer address
+func getLoadBalancerHealth(obj *unstructured.Unstructured) (*Status, error) {
+	ingress, _, err := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ingress) == 0 {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for load balancer to be assigned"}, nil
+	}
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
+
+func getAPIServiceHealth(obj *unstructured.Unstructured) (*Status, error) {
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok || cond["type"] != "Available" {
+			continue
+		}
+		if cond["status"] == "True" {
+			return &Status{Status: cdv1.HealthStatusHealthy}, nil
+		}
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("%v: %v", cond["reason"], cond["message"])}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting to be processed"}, nil
+}
@@ -0,0 +1,185 @@
+package health
+
+import (
+	"fmt"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Reason of the deployment's progressing condition, when it failed to progress
+const deploymentReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// Container waiting reasons which are not going to recover by themselves
+var podErrorReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+func getDeploymentHealth(obj *unstructured.Unstructured) (*Status, error) {
+	deploy := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deploy); err != nil {
+		return nil, err
+	}
+
+	if deploy.Spec.Paused {
+		return &Status{Status: cdv1.HealthStatusSuspended, Message: "Deployment is paused"}, nil
+	}
+
+	if deploy.Generation > deploy.Status.ObservedGeneration {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for rollout to finish: observed deployment generation less than desired generation"}, nil
+	}
+
+	for _, cond := range deploy.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == deploymentReasonProgressDeadlineExceeded {
+			return &Status{Status: cdv1.HealthStatusDegraded, Message: fmt.Sprintf("Deployment %q exceeded its progress deadline", deploy.Name)}, nil
+		}
+	}
+
+	replicas := int32(1)
+	if deploy.Spec.Replicas != nil {
+		replicas = *deploy.Spec.Replicas
+	}
+	if deploy.Status.UpdatedReplicas < replicas {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for rollout to finish: %d out of %d new replicas have been updated...", deploy.Status.UpdatedReplicas, replicas)}, nil
+	}
+	if deploy.Status.Replicas > deploy.Status.UpdatedReplicas {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for rollout to finish: %d old replicas are pending termination...", deploy.Status.Replicas-deploy.Status.UpdatedReplicas)}, nil
+	}
+	if deploy.Status.AvailableReplicas < deploy.Status.UpdatedReplicas {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for rollout to finish: %d of %d updated replicas are available...", deploy.Status.AvailableReplicas, deploy.Status.UpdatedReplicas)}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
+
+func getStatefulSetHealth(obj *unstructured.Unstructured) (*Status, error) {
+	sts := &appsv1.StatefulSet{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, sts); err != nil {
+		return nil, err
+	}
+
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for statefulset spec update to be observed..."}, nil
+	}
+
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for %d pods to be ready...", replicas-sts.Status.ReadyReplicas)}, nil
+	}
+
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return &Status{Status: cdv1.HealthStatusHealthy, Message: "Statefulset is using OnDelete update strategy"}, nil
+	}
+
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		partition := *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+		if sts.Status.UpdatedReplicas < replicas-partition {
+			return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", sts.Status.UpdatedReplicas, replicas-partition)}, nil
+		}
+		return &Status{Status: cdv1.HealthStatusHealthy}, nil
+	}
+
+	if sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for rolling update to complete: %d pods at revision %s...", sts.Status.UpdatedReplicas, sts.Status.UpdateRevision)}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
+
+func getDaemonSetHealth(obj *unstructured.Unstructured) (*Status, error) {
+	ds := &appsv1.DaemonSet{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, ds); err != nil {
+		return nil, err
+	}
+
+	if ds.Generation > ds.Status.ObservedGeneration {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for daemon set spec update to be observed..."}, nil
+	}
+
+	if ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return &Status{Status: cdv1.HealthStatusHealthy, Message: "Daemon set is using OnDelete update strategy"}, nil
+	}
+
+	if ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for daemon set rollout to finish: %d out of %d new pods have been updated...", ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)}, nil
+	}
+	if ds.Status.NumberAvailable < ds.Status.DesiredNumberScheduled {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for daemon set rollout to finish: %d of %d updated pods are available...", ds.Status.NumberAvailable, ds.Status.DesiredNumberScheduled)}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
+
+func getReplicaSetHealth(obj *unstructured.Unstructured) (*Status, error) {
+	rs := &appsv1.ReplicaSet{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, rs); err != nil {
+		return nil, err
+	}
+
+	if rs.Generation > rs.Status.ObservedGeneration {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: "Waiting for rollout to finish: observed replica set generation less than desired generation"}, nil
+	}
+
+	for _, cond := range rs.Status.Conditions {
+		if cond.Type == appsv1.ReplicaSetReplicaFailure && cond.Status == corev1.ConditionTrue {
+			return &Status{Status: cdv1.HealthStatusDegraded, Message: cond.Message}, nil
+		}
+	}
+
+	replicas := int32(1)
+	if rs.Spec.Replicas != nil {
+		replicas = *rs.Spec.Replicas
+	}
+	if rs.Status.AvailableReplicas < replicas {
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Waiting for rollout to finish: %d out of %d new replicas are available...", rs.Status.AvailableReplicas, replicas)}, nil
+	}
+
+	return &Status{Status: cdv1.HealthStatusHealthy}, nil
+}
+
+func getPodHealth(obj *unstructured.Unstructured) (*Status, error) {
+	pod := &corev1.Pod{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, pod); err != nil {
+		return nil, err
+	}
+
+	// Containers which are stuck are not going to be ready, whatever the phase is
+	for _, s := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if s.State.Waiting != nil && podErrorReasons[s.State.Waiting.Reason] {
+			return &Status{Status: cdv1.HealthStatusDegraded, Message: s.State.Waiting.Message}, nil
+		}
+	}
+
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return &Status{Status: cdv1.HealthStatusHealthy, Message: pod.Status.Message}, nil
+	case corev1.PodFailed:
+		msg := pod.Status.Message
+		if msg == "" {
+			msg = "Pod failed"
+		}
+		return &Status{Status: cdv1.HealthStatusDegraded, Message: msg}, nil
+	case corev1.PodPending:
+		return &Status{Status: cdv1.HealthStatusProgressing, Message: pod.Status.Message}, nil
+	case corev1.PodRunning:
+		for _, s := range pod.Status.ContainerStatuses {
+			if !s.Ready {
+				return &Status{Status: cdv1.HealthStatusProgressing, Message: fmt.Sprintf("Container %s is not ready", s.Name)}, nil
+			}
+		}
+		return &Status{Status: cdv1.HealthStatusHealthy, Message: pod.Status.Message}, nil
+	default:
+		return &Status{Status: cdv1.HealthStatusUnknown, Message: pod.Status.Message}, nil
+	}
+}
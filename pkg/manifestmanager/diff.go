@@ -0,0 +1,320 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// DiffAction is an action which a sync would take on a resource
+type DiffAction string
+
+// Diff actions
+const (
+	// DiffActionCreate means that the resource does not exist in the cluster and will be created
+	DiffActionCreate DiffAction = "create"
+	// DiffActionUpdate means that the live resource differs from the manifest and will be updated
+	DiffActionUpdate DiffAction = "update"
+	// DiffActionDelete means that the resource is no longer defined in git and will be deleted
+	DiffActionDelete DiffAction = "delete"
+	// DiffActionPrune means that the resource is no longer defined in git, but requires pruning as it will be kept
+	DiffActionPrune DiffAction = "prune"
+	// DiffActionUntrack means that the resource is no longer defined in git, but it's owned by another application. It
+	// will be kept, and the application will stop tracking it
+	DiffActionUntrack DiffAction = "untrack"
+	// DiffActionRecreate means that the live resource can't be updated in place, and will be deleted and created again
+	DiffActionRecreate DiffAction = "recreate"
+	// DiffActionNone means that the live resource is in sync with the manifest
+	DiffActionNone DiffAction = "none"
+)
+
+// ResourceDiff is a difference between the desired and the live state of a resource
+type ResourceDiff struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	Action     DiffAction `json:"action"`
+	// Summary is a list of the fields which differ between the live and the desired state
+	Summary string `json:"summary,omitempty"`
+	// Diff is a unified diff from the live YAML to the desired YAML
+	Diff string `json:"diff,omitempty"`
+}
+
+// Maximum number of the fields listed in a diff summary
+const maxDiffSummaryFields = 5
+
+// Metadata fields which are managed by the server, and are not shown in diffs
+var serverManagedMetadataFields = []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp", "selfLink"}
+
+// diffResources compares the manifest objects and the prune targets with the live cluster, without applying anything.
+// Only the resources selected by the options are compared
+func diffResources(ctx context.Context, targetCli client.Client, app *cdv1.Application, manifestObjs []*unstructured.Unstructured, oldDeployResources *cdv1.DeployResourceList, opts SyncOptions) ([]ResourceDiff, error) {
+	var selected []*unstructured.Unstructured
+	for _, manifestObj := range manifestObjs {
+		if opts.isObjectSelected(manifestObj) {
+			selected = append(selected, manifestObj)
+		}
+	}
+
+	diffs := make([]ResourceDiff, len(selected))
+	if err := parallelize(ctx, len(selected), func(ctx context.Context, i int) error {
+		diff, err := diffResource(ctx, targetCli, app, selected[i].DeepCopy())
+		if err != nil {
+			return err
+		}
+		diffs[i] = *diff
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	prune := isPruneEnabled(app, SyncOptions{Forced: true, Prune: opts.Prune}, "")
+	pruneTargets, _, err := opts.selectPruneTargets(ctx, targetCli, getPruneTargets(app, oldDeployResources, manifestObjs))
+	if err != nil {
+		return nil, err
+	}
+	// Resources owned by other applications are not pruned, as a sync only stops tracking them
+	pruneTargets, sharedPruneTargets, sharedMessages, err := splitSharedDeployResources(ctx, targetCli, app, pruneTargets)
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range sharedPruneTargets {
+		diffs = append(diffs, ResourceDiff{
+			APIVersion: target.Spec.APIVersion,
+			Kind:       target.Spec.Kind,
+			Namespace:  target.Spec.Namespace,
+			Name:       target.Spec.Name,
+			Action:     DiffActionUntrack,
+			Summary:    sharedMessages[i],
+		})
+	}
+	for _, target := range pruneTargets {
+		deployedObj, err := getDeployedObject(ctx, targetCli, &target)
+		if err != nil {
+			return nil, err
+		}
+		if deployedObj == nil {
+			continue
+		}
+
+		diff := newResourceDiff(deployedObj, DiffActionDelete)
+		if !prune || hasSyncOption(deployedObj, cdv1.SyncOptionPruneDisabled) {
+			diff.Action = DiffActionPrune
+		}
+		diff.Diff, err = unifiedDiff(deployedObj, nil)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, *diff)
+	}
+
+	return diffs, nil
+}
+
+func diffResource(ctx context.Context, cli client.Client, app *cdv1.Application, manifestObj *unstructured.Unstructured) (*ResourceDiff, error) {
+	deployedObj := manifestObj.DeepCopy()
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: deployedObj.GetNamespace(), Name: deployedObj.GetName()}, deployedObj); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		diff := newResourceDiff(manifestObj, DiffActionCreate)
+		diff.Summary = diffSummary(nil, manifestObj)
+		diff.Diff, err = unifiedDiff(nil, manifestObj)
+		if err != nil {
+			return nil, err
+		}
+		return diff, nil
+	}
+
+	recreateReason, err := dryRunApply(ctx, cli, app, deployedObj, manifestObj)
+	if err != nil {
+		return nil, err
+	}
+	if recreateReason != "" {
+		diff := newResourceDiff(manifestObj, DiffActionRecreate)
+		diff.Summary = recreateReason
+		diff.Diff, err = unifiedDiff(deployedObj, manifestObj)
+		if err != nil {
+			return nil, err
+		}
+		return diff, nil
+	}
+
+	if fmt.Sprintf("%v", deployedObj) == fmt.Sprintf("%v", manifestObj) {
+		return newResourceDiff(manifestObj, DiffActionNone), nil
+	}
+
+	diff := newResourceDiff(manifestObj, DiffActionUpdate)
+	diff.Summary = diffSummary(deployedObj, manifestObj)
+	diff.Diff, err = unifiedDiff(deployedObj, manifestObj)
+	if err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+func newResourceDiff(obj *unstructured.Unstructured, action DiffAction) *ResourceDiff {
+	return &ResourceDiff{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		Action:     action,
+	}
+}
+
+// unifiedDiff returns a unified diff from the live object to the desired object. Nil objects are regarded as empty
+func unifiedDiff(liveObj, desiredObj *unstructured.Unstructured) (string, error) {
+	live, err := diffYAML(liveObj)
+	if err != nil {
+		return "", err
+	}
+	desired, err := diffYAML(desiredObj)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(live),
+		B:        difflib.SplitLines(desired),
+		FromFile: "live",
+		ToFile:   "desired",
+		Context:  3,
+	})
+}
+
+// diffYAML converts the object into YAML, without the fields which are not managed by manifests
+func diffYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+
+	b, err := yaml.Marshal(diffObject(obj))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// diffObject returns a copy of the object's content, without the fields which are not managed by manifests
+func diffObject(obj *unstructured.Unstructured) map[string]interface{} {
+	obj = obj.DeepCopy()
+	for _, field := range serverManagedMetadataFields {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+	return obj.Object
+}
+
+// diffSummary lists the paths of the fields which differ between the live object and the desired object,
+// e.g., "spec.replicas, data.key"
+func diffSummary(liveObj, desiredObj *unstructured.Unstructured) string {
+	if liveObj == nil {
+		return "resource does not exist"
+	}
+
+	var paths []string
+	diffPaths("", diffObject(liveObj), diffObject(desiredObj), &paths)
+	sort.Strings(paths)
+
+	if len(paths) > maxDiffSummaryFields {
+		return fmt.Sprintf("%s and %d more", strings.Join(paths[:maxDiffSummaryFields], ", "), len(paths)-maxDiffSummaryFields)
+	}
+	return strings.Join(paths, ", ")
+}
+
+// manifestFieldsDiffer checks if any field specified in the manifest differs in the live object. The fields which are
+// only in the live object, e.g., defaulted by the server, are ignored
+func manifestFieldsDiffer(liveObj, manifestObj *unstructured.Unstructured) bool {
+	return !manifestFieldsEqual(diffObject(liveObj), diffObject(manifestObj))
+}
+
+func manifestFieldsEqual(live, manifest interface{}) bool {
+	switch manifest := manifest.(type) {
+	case map[string]interface{}:
+		liveMap, ok := live.(map[string]interface{})
+		if !ok && live != nil {
+			return false
+		}
+		for k, v := range manifest {
+			if !manifestFieldsEqual(liveMap[k], v) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveList, ok := live.([]interface{})
+		if !ok || len(liveList) != len(manifest) {
+			return false
+		}
+		for i := range manifest {
+			if !manifestFieldsEqual(liveList[i], manifest[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Numbers may be decoded into different types, e.g., int64 from the server and float64 from JSON
+		if liveNum, ok := toFloat64(live); ok {
+			if manifestNum, ok := toFloat64(manifest); ok {
+				return liveNum == manifestNum
+			}
+		}
+		return equality.Semantic.DeepEqual(live, manifest)
+	}
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+func diffPaths(prefix string, live, desired map[string]interface{}, paths *[]string) {
+	keys := map[string]bool{}
+	for k := range live {
+		keys[k] = true
+	}
+	for k := range desired {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + k
+		}
+
+		liveMap, liveIsMap := live[k].(map[string]interface{})
+		desiredMap, desiredIsMap := desired[k].(map[string]interface{})
+		if liveIsMap && desiredIsMap {
+			diffPaths(path, liveMap, desiredMap, paths)
+			continue
+		}
+		if !equality.Semantic.DeepEqual(live[k], desired[k]) {
+			*paths = append(*paths, path)
+		}
+	}
+}
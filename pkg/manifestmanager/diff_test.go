@@ -0,0 +1,193 @@
+package manifestmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type diffResourcesTestCase struct {
+	prune bool
+	opts  SyncOptions
+
+	expectedActions map[string]DiffAction
+}
+
+func TestDiffResources(t *testing.T) {
+	tc := map[string]diffResourcesTestCase{
+		"prune": {
+			prune: true,
+			expectedActions: map[string]DiffAction{
+				"new":       DiffActionCreate,
+				"changed":   DiffActionUpdate,
+				"unchanged": DiffActionNone,
+				"removed":   DiffActionDelete,
+				"protected": DiffActionPrune,
+				"shared":    DiffActionUntrack,
+			},
+		},
+		"noPrune": {
+			prune: false,
+			expectedActions: map[string]DiffAction{
+				"new":       DiffActionCreate,
+				"changed":   DiffActionUpdate,
+				"unchanged": DiffActionNone,
+				"removed":   DiffActionPrune,
+				"protected": DiffActionPrune,
+				"shared":    DiffActionUntrack,
+			},
+		},
+		"selected": {
+			prune: true,
+			opts: SyncOptions{
+				Resources:      []cdv1.SyncOperationResource{{Kind: "Service", Name: "changed"}},
+				LabelSelectors: []metav1.LabelSelector{{MatchLabels: map[string]string{"tier": "db"}}},
+			},
+			expectedActions: map[string]DiffAction{
+				"changed": DiffActionUpdate,
+				"removed": DiffActionDelete,
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	newService := func(name string, targetPort int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": name, "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(80), "targetPort": targetPort}}}}}
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+				Spec:       cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{Prune: c.prune}},
+			}
+
+			protected := newService("protected", 80)
+			protected.SetAnnotations(map[string]string{cdv1.AnnotationSyncOptions: cdv1.SyncOptionPruneDisabled})
+			removed := newService("removed", 80)
+			removed.SetLabels(map[string]string{"tier": "db"})
+			// The resource owned by another application is kept
+			shared := newService("shared", 80)
+			shared.SetAnnotations(map[string]string{cdv1.AnnotationTrackingID: "default/other-app"})
+			mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(newService("changed", 8080), newService("unchanged", 80), removed, protected, shared).Build()
+
+			manifestObjs := []*unstructured.Unstructured{newService("new", 80), newService("changed", 80), newService("unchanged", 80)}
+			oldDeployResources := &cdv1.DeployResourceList{}
+			for _, obj := range append(manifestObjs, newService("removed", 80), protected, shared) {
+				oldDeployResources.Items = append(oldDeployResources.Items, cdv1.DeployResource{
+					ObjectMeta: metav1.ObjectMeta{Name: deployResourceName(app, obj), Namespace: app.Namespace},
+					Spec:       cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: obj.GetName(), Namespace: "test"},
+				})
+			}
+
+			diffs, err := diffResources(context.Background(), mockClient, app, manifestObjs, oldDeployResources, c.opts)
+			require.NoError(t, err)
+
+			actions := map[string]DiffAction{}
+			for _, d := range diffs {
+				actions[d.Name] = d.Action
+				if d.Action == DiffActionUntrack {
+					require.Contains(t, d.Summary, "default/other-app")
+				}
+				if d.Action == DiffActionNone || d.Action == DiffActionUntrack {
+					require.Empty(t, d.Diff)
+				} else {
+					require.NotEmpty(t, d.Diff)
+				}
+			}
+			require.Equal(t, c.expectedActions, actions)
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	live := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "resourceVersion": "1"}, "data": map[string]interface{}{"key": "old"}}}
+	desired := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "resourceVersion": "1"}, "data": map[string]interface{}{"key": "new"}}}
+
+	diff, err := unifiedDiff(live, desired)
+	require.NoError(t, err)
+	require.Equal(t, `--- live
++++ desired
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: old
++  key: new
+ kind: ConfigMap
+ metadata:
+   name: cm
+`, diff)
+}
+
+type diffSummaryTestCase struct {
+	live    *unstructured.Unstructured
+	desired *unstructured.Unstructured
+
+	expectedSummary string
+}
+
+func TestDiffSummary(t *testing.T) {
+	newConfigMap := func(data map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "resourceVersion": "1"}, "data": data}}
+	}
+
+	tc := map[string]diffSummaryTestCase{
+		"notExist": {
+			live:            nil,
+			desired:         newConfigMap(map[string]interface{}{"a": "1"}),
+			expectedSummary: "resource does not exist",
+		},
+		"changed": {
+			live:            newConfigMap(map[string]interface{}{"a": "1", "b": "2", "c": "3"}),
+			desired:         newConfigMap(map[string]interface{}{"a": "1", "b": "3", "d": "4"}),
+			expectedSummary: "data.b, data.c, data.d",
+		},
+		"tooMany": {
+			live:            newConfigMap(map[string]interface{}{"a": "1", "b": "1", "c": "1", "d": "1", "e": "1", "f": "1", "g": "1"}),
+			desired:         newConfigMap(map[string]interface{}{}),
+			expectedSummary: "data.a, data.b, data.c, data.d, data.e and 2 more",
+		},
+		"serverManagedFields": {
+			live:            newConfigMap(map[string]interface{}{"a": "1"}),
+			desired:         &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "metadata": map[string]interface{}{"name": "cm", "resourceVersion": "2"}, "data": map[string]interface{}{"a": "1"}}},
+			expectedSummary: "",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expectedSummary, diffSummary(c.live, c.desired))
+		})
+	}
+}
+
+func TestManifestFieldsDiffer(t *testing.T) {
+	newService := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "svc"}, "spec": spec}}
+	}
+	manifest := newService(map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(80)}}})
+
+	// Defaulted by the server
+	live := newService(map[string]interface{}{"clusterIP": "10.0.0.1", "ports": []interface{}{map[string]interface{}{"port": int64(80), "protocol": "TCP"}}})
+	live.SetResourceVersion("1")
+	live.SetLabels(map[string]string{"other": "true"})
+	require.False(t, manifestFieldsDiffer(live, manifest))
+
+	// Decoded from JSON
+	require.False(t, manifestFieldsDiffer(newService(map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": float64(80)}}}), manifest))
+
+	require.True(t, manifestFieldsDiffer(newService(map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": int64(8080)}}}), manifest))
+	require.True(t, manifestFieldsDiffer(newService(map[string]interface{}{"ports": []interface{}{}}), manifest))
+	require.True(t, manifestFieldsDiffer(newService(nil), manifest))
+}
@@ -1,8 +1,12 @@
 package manifestmanager
 
 import (
+	"bytes"
 	"context"
+	stderrors "errors"
+	"fmt"
 	"os"
+	"strings"
 
 	gohelm "github.com/mittwald/go-helm-client"
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
@@ -10,143 +14,396 @@ import (
 	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager/utils"
 	"github.com/tmax-cloud/cd-operator/util/gitclient"
 	"github.com/tmax-cloud/cd-operator/util/helmclient"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	helmResourcePolicyAnnotation = "helm.sh/resource-policy"
+	helmResourcePolicyKeep       = "keep"
+)
+
 type helmManager struct {
 	DefaultCli client.Client
-	context.Context
+	// Client for multi cluster
+	TargetCli  client.Client
 	helmClient *helmclient.Client
 }
 
-func NewHelmManager(ctx context.Context, cli client.Client) ManifestManager {
-	opt := &gohelm.Options{
-		RepositoryCache:  "/tmp/.helmcache",
-		RepositoryConfig: "/tmp/.helmrepo",
-		Debug:            true,
-		Linting:          true,
+// NewHelmManager creates a helm manager with the helm client and the client of the application's destination cluster.
+// It's registered as the factory of the helm applications
+func NewHelmManager(ctx context.Context, cli client.Client, app *cdv1.Application) (ManifestManager, error) {
+	m := &helmManager{
+		DefaultCli: cli,
+		helmClient: &helmclient.Client{},
+	}
+	if err := m.setTargetClient(ctx, app); err != nil {
+		log.Error(err, "setTargetClient failed..")
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *helmManager) Sync(ctx context.Context, app *cdv1.Application, opts SyncOptions) error {
+	// A chart is installed as a whole
+	if opts.IsSelective() {
+		return fmt.Errorf("syncing a subset of resources is not supported for helm applications")
 	}
 
-	goHelmClient, err := gohelm.New(opt)
+	forced := opts.Forced
+	chartSpec, revision, cleanup, err := m.prepareChart(ctx, app, opts.Revision)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	return &helmManager{
-		Context:    ctx,
-		DefaultCli: cli,
-		helmClient: &helmclient.Client{
-			Client: goHelmClient,
-		},
+	defer cleanup()
+	setComparedTo(app, revision)
+
+	oldDeployResources, err := getDeployResourceList(ctx, m.DefaultCli, app)
+	if err != nil {
+		log.Error(err, "GetDeployResourceList failed")
+		return err
 	}
-}
 
-func (m *helmManager) Sync(app *cdv1.Application, forced bool) error {
-	/* TODO : 이 로직으로는 분기 구별 불가. app.Spec.Source.Helm.ClonedRepoPath 값의 업데이트가 왜 2~3번만에 되는걸까?
-	if app.Spec.Source.Helm.ClonedRepoPath == "" {
-		log.Info("Start to clone..")
-		if err := m.gitRepoClone(app); err != nil {
-			return err
+	manifestRawobjs, err := m.objectFromManifest(ctx, chartSpec, app)
+	if err != nil {
+		log.Error(err, "Get object from manifest failed..")
+		return err
+	}
+	oldDeployResources, err = migrateDeployResources(ctx, m.DefaultCli, app, oldDeployResources)
+	if err != nil {
+		log.Error(err, "migrateDeployResources failed..")
+		return err
+	}
+	oldDeployResources, err = dropMisscopedDeployResources(ctx, m.DefaultCli, oldDeployResources, manifestRawobjs)
+	if err != nil {
+		log.Error(err, "dropMisscopedDeployResources failed..")
+		return err
+	}
+
+	prune := isPruneEnabled(app, opts, revision)
+	pruneTargets := getPruneTargets(app, oldDeployResources, manifestRawobjs)
+	// Resources owned by other applications are neither updated nor pruned
+	pruneTargets, sharedPruneTargets, sharedMessages, err := splitSharedDeployResources(ctx, m.TargetCli, app, pruneTargets)
+	if err != nil {
+		log.Error(err, "splitSharedDeployResources failed..")
+		return err
+	}
+	if err := checkPruneLimit(app, prune, len(pruneTargets), len(oldDeployResources.Items)); err != nil {
+		log.Error(err, "Prune limit exceeded..")
+		return err
+	}
+	if err := m.forgetDeployResources(ctx, sharedPruneTargets); err != nil {
+		log.Error(err, "forgetDeployResources failed..")
+		return err
+	}
+
+	// The unmarked live objects of the resources tracked before are adopted, as they were installed by the application
+	tracked := map[string]bool{}
+	for _, deployResource := range oldDeployResources.Items {
+		tracked[specDeployResourceName(app, deployResource.Spec)] = true
+	}
+
+	// The resources are compared concurrently, while the chart is installed as a whole
+	results := make([]*resourceResult, len(manifestRawobjs))
+	if err := parallelize(ctx, len(manifestRawobjs), func(ctx context.Context, i int) error {
+		obj := manifestRawobjs[i]
+		result, err := m.compareResource(ctx, app, obj, tracked[deployResourceName(app, obj)])
+		results[i] = result
+		return err
+	}); err != nil {
+		log.Error(err, "Compare resources failed..")
+		return err
+	}
+	setOutOfSync(app, results)
+	for _, result := range results {
+		if result.sharedMessage != "" {
+			sharedMessages = append(sharedMessages, result.sharedMessage)
 		}
-	} else {
-		log.Info("Already cloned..pull after fetch")
-		if err := m.gitFetchAndPull(app); err != nil {
+	}
+	setSharedResourceCondition(app, sharedMessages)
+
+	if prune && len(pruneTargets) > 0 && opts.isTerminated() {
+		return ErrTerminated
+	}
+	requirePruning, err := pruneDeployResources(ctx, m.TargetCli, app, pruneTargets, prune, m.pruneDeployResource)
+	if err != nil {
+		log.Error(err, "pruneDeployResources failed..")
+		return err
+	}
+
+	// Helm removes resources which are no longer in the chart on upgrade, unless they are marked to be kept
+	for i := range requirePruning {
+		if err := m.keepDeployedObject(ctx, &requirePruning[i].Spec); err != nil {
+			log.Error(err, "keepDeployedObject failed..")
 			return err
 		}
 	}
-	*/
-	expectedPath := "/tmp/repo-" + app.Name + "-" + app.Namespace
-	_, err := os.Stat(expectedPath)
-	if os.IsNotExist(err) {
-		log.Info("Start to clone..")
-		if err := m.gitRepoClone(app); err != nil {
+
+	// Autosync is regarded as a sync only if it changes anything
+	changed := len(requirePruning) < len(pruneTargets)
+	for _, result := range results {
+		changed = changed || result.status.SyncStatus != cdv1.SyncStatusCodeSynced
+	}
+
+	if forced || (opts.isApplyEnabled(revision) && changed) {
+		if opts.isTerminated() {
+			setSyncResult(opts, revision, results, pruneTargets, requirePruning)
+			return ErrTerminated
+		}
+		if err := ensureDestinationNamespace(ctx, m.TargetCli, app); err != nil {
+			log.Error(err, "ensureDestinationNamespace failed..")
 			return err
 		}
-	} else if err == nil {
-		log.Info("Already cloned..pull after fetch")
-		if err := m.gitPull(app); err != nil {
+		// A chart is installed as a whole, so none of it is installed while any resource is owned by others
+		err := sharedResourceError(results)
+		if err == nil {
+			_, err = m.installHelmChart(ctx, chartSpec, app, false)
+		}
+		if err != nil {
+			for _, result := range results {
+				result.setApplyError(err)
+			}
+			setSyncResult(opts, revision, results, pruneTargets, requirePruning)
+			if err := updateDeployResourceStatuses(ctx, m.DefaultCli, results); err != nil {
+				log.Error(err, "updateDeployResourceStatuses failed..")
+			}
 			return err
 		}
+		for _, result := range results {
+			result.setApplied(revision)
+		}
+		if len(requirePruning) == 0 {
+			app.Status.Sync.Status = cdv1.SyncStatusCodeSynced
+		}
+		now := metav1.Now()
+		app.Status.LastSyncedAt = &now
 	}
+	if app.Status.Sync.Status == cdv1.SyncStatusCodeUnknown {
+		app.Status.Sync.Status = cdv1.SyncStatusCodeSynced
+	}
+	setSyncResult(opts, revision, results, pruneTargets, requirePruning)
 
-	chartSpec := setChartSpec(app)
-
-	if err := m.setTargetClient(app); err != nil {
-		log.Error(err, "setTargetClient failed..")
+	if err := setApplicationHealth(ctx, m.TargetCli, app, results); err != nil {
+		log.Error(err, "setApplicationHealth failed..")
 		return err
 	}
+	setApplicationResources(app, results, requirePruning)
+	// The orphaned resources are only monitored, so the sync does not fail even if they can't be listed
+	if err := setOrphanedResources(ctx, m.DefaultCli, m.TargetCli, app); err != nil {
+		log.Error(err, "setOrphanedResources failed..")
+	}
+	return updateDeployResourceStatuses(ctx, m.DefaultCli, results)
+}
 
-	oldDeployResources, err := getDeployResourceList(m.DefaultCli, app)
+// compareResource compares the resource with the manifest object, and records the result in its status. The live
+// object which can't be managed by the application is not tracked
+func (m *helmManager) compareResource(ctx context.Context, app *cdv1.Application, obj *unstructured.Unstructured, tracked bool) (*resourceResult, error) {
+	liveObj, err := getLiveObject(ctx, m.TargetCli, obj)
 	if err != nil {
-		log.Error(err, "GetDeployResourceList failed")
-		return err
+		log.Error(err, "getLiveObject failed..")
+		return nil, err
+	}
+	if msg := checkSharedResource(app, obj, liveObj, tracked); msg != "" {
+		return newSharedResourceResult(obj, msg), nil
 	}
 
-	updatedDeployResources := make(map[string]*cdv1.DeployResource)
+	deployResource, err := updateDeployResource(ctx, m.DefaultCli, obj, app)
+	if err != nil {
+		log.Error(err, "NewDeployResource failed..")
+		return nil, err
+	}
+	result := newResourceResult(obj, deployResource)
+
+	diff, err := diffResource(ctx, m.TargetCli, app, obj.DeepCopy())
+	if err != nil {
+		log.Error(err, "diffResource failed..")
+		return nil, err
+	}
+	if diff.Action == DiffActionNone {
+		result.status.SyncStatus = cdv1.SyncStatusCodeSynced
+		result.status.DiffSummary = ""
+	} else {
+		result.status.SyncStatus = cdv1.SyncStatusCodeOutOfSync
+		result.status.DiffSummary = diff.Summary
+	}
+	return result, nil
+}
 
-	manifestRawobjs, err := m.objectFromManifest(chartSpec, app)
+func (m *helmManager) Diff(ctx context.Context, app *cdv1.Application, opts SyncOptions) ([]ResourceDiff, error) {
+	chartSpec, _, cleanup, err := m.prepareChart(ctx, app, opts.Revision)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	oldDeployResources, err := getDeployResourceList(ctx, m.DefaultCli, app)
+	if err != nil {
+		log.Error(err, "GetDeployResourceList failed")
+		return nil, err
+	}
+
+	manifestRawobjs, err := m.objectFromManifest(ctx, chartSpec, app)
 	if err != nil {
 		log.Error(err, "Get object from manifest failed..")
+		return nil, err
+	}
+	oldDeployResources, _ = splitMisscopedDeployResources(oldDeployResources, manifestRawobjs)
+
+	return diffResources(ctx, m.TargetCli, app, manifestRawobjs, oldDeployResources, opts)
+}
+
+func (m *helmManager) Clear(ctx context.Context, app *cdv1.Application) error {
+	deployedResourceList, err := getDeployResourceList(ctx, m.DefaultCli, app)
+	if err != nil {
 		return err
 	}
 
-	for _, manifestRawobj := range manifestRawobjs {
-		updatedDeployResource, err := updateDeployResource(m.DefaultCli, manifestRawobj, app)
-		if err != nil {
-			log.Error(err, "NewDeployResource failed..")
+	// Resources owned by other applications are kept by helm, and the application only stops tracking them
+	deployResources, shared, _, err := splitSharedDeployResources(ctx, m.TargetCli, app, deployedResourceList.Items)
+	if err != nil {
+		log.Error(err, "splitSharedDeployResources failed..")
+		return err
+	}
+	if err := m.forgetDeployResources(ctx, shared); err != nil {
+		log.Error(err, "forgetDeployResources failed..")
+		return err
+	}
+
+	// The release is kept with the orphan policy, so that it can be adopted by a new application of the same name
+	policy := app.GetDeletionPolicy()
+	if policy != cdv1.DeletionPolicyOrphan {
+		if err := m.uninstallRelease(app); err != nil && !stderrors.Is(err, driver.ErrReleaseNotFound) {
 			return err
 		}
-		updatedDeployResources[updatedDeployResource.Name] = updatedDeployResource
 	}
 
-	for _, oldDeployResource := range oldDeployResources.Items {
-		if updatedDeployResources[oldDeployResource.Name] == nil {
-			if err := deleteDeployResource(m.DefaultCli, &oldDeployResource); err != nil {
-				log.Error(err, "DeleteDeployResource failed..")
+	// Helm deletes the resources in the background. The resources which are kept by helm are not waited for
+	deleting := 0
+	for i := range deployResources {
+		deployResource := &deployResources[i]
+		if policy == cdv1.DeletionPolicyForeground {
+			deployedObj, err := getDeployedObject(ctx, m.TargetCli, deployResource)
+			if err != nil {
+				log.Error(err, "getDeployedObject failed..")
 				return err
 			}
+			if deployedObj != nil && deployedObj.GetDeletionTimestamp() != nil {
+				deleting++
+				continue
+			}
 		}
-	}
 
-	if forced || app.Spec.SyncPolicy.AutoSync {
-		if _, err := m.installHelmChart(chartSpec, app, false); err != nil {
+		if err := m.clearDeployResource(ctx, deployResource); err != nil && !errors.IsNotFound(err) {
 			return err
 		}
-		app.Status.Sync.Status = cdv1.SyncStatusCodeSynced
 	}
-	return nil
-}
 
-func (m *helmManager) Clear(app *cdv1.Application) error {
-	if err := m.setTargetClient(app); err != nil {
-		return err
+	if deleting > 0 {
+		return fmt.Errorf("%d of %d: %w", deleting, len(deployResources), ErrDeletionInProgress)
 	}
 
-	if err := m.uninstallRelease(app); err != nil {
+	// The namespace created by the application is deleted, after its resources are deleted
+	deletingNs, err := deleteOwnedNamespace(ctx, m.TargetCli, app)
+	if err != nil {
+		log.Error(err, "deleteOwnedNamespace failed..")
 		return err
 	}
+	if deletingNs {
+		return fmt.Errorf("namespace %s: %w", app.Status.OwnedNamespace, ErrDeletionInProgress)
+	}
+	return nil
+}
+
+// updateRepo clones the repository of the application, or pulls it if it is already cloned.
+// It returns the commit SHA of the cloned repository
+func (m *helmManager) updateRepo(ctx context.Context, app *cdv1.Application) (string, error) {
+	/* TODO : 이 로직으로는 분기 구별 불가. app.Spec.Source.Helm.ClonedRepoPath 값의 업데이트가 왜 2~3번만에 되는걸까?
+	if app.Spec.Source.Helm.ClonedRepoPath == "" {
+		log.Info("Start to clone..")
+		if err := m.gitRepoClone(app); err != nil {
+			return err
+		}
+	} else {
+		log.Info("Already cloned..pull after fetch")
+		if err := m.gitFetchAndPull(app); err != nil {
+			return err
+		}
+	}
+	*/
+	expectedPath := "/tmp/repo-" + app.Name + "-" + app.Namespace
+	_, err := os.Stat(expectedPath)
+	if os.IsNotExist(err) {
+		log.Info("Start to clone..")
+		if err := m.gitRepoClone(ctx, app); err != nil {
+			return "", err
+		}
+	} else if err == nil {
+		log.Info("Already cloned..pull after fetch")
+		if err := m.gitPull(ctx, app); err != nil {
+			return "", err
+		}
+	}
 
-	deployedResourceList, err := getDeployResourceList(m.DefaultCli, app)
+	repo, err := gitclient.Open(expectedPath)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return gitclient.Head(repo)
+}
 
-	for _, deployedResource := range deployedResourceList.Items {
-		if err := m.clearDeployResource(&deployedResource); err != nil {
-			return err
+// prepareChart gets the chart of the revision ready. If revision is empty, the synced repository is updated to the
+// target revision. Otherwise, the chart is rendered from a separate clone, not to change the checked out revision of
+// the synced repository. It returns the chart spec, the commit SHA of the chart and a function to clean up the clone
+func (m *helmManager) prepareChart(ctx context.Context, app *cdv1.Application, revision string) (*gohelm.ChartSpec, string, func(), error) {
+	chartSpec := setChartSpec(app)
+	if revision == "" {
+		sha, err := m.updateRepo(ctx, app)
+		if err != nil {
+			return nil, "", nil, err
 		}
+		return chartSpec, sha, func() {}, nil
 	}
 
-	return nil
+	tmpPath, err := os.MkdirTemp("", "repo-"+app.Name+"-"+app.Namespace+"-")
+	if err != nil {
+		return nil, "", nil, err
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(tmpPath)
+	}
+	sha, err := m.gitRepoCloneRevision(ctx, app, tmpPath, revision)
+	if err != nil {
+		cleanup()
+		return nil, "", nil, err
+	}
+	chartSpec.ChartName = tmpPath + "/" + app.Spec.Source.Path
+	return chartSpec, sha, cleanup, nil
+}
+
+// gitRepoCloneRevision clones the repository of the application into localPath, and checks out the revision.
+// It returns the commit SHA of the revision
+func (m *helmManager) gitRepoCloneRevision(ctx context.Context, app *cdv1.Application, localPath, revision string) (string, error) {
+	repo, err := gitclient.Clone(ctx, app.Spec.Source.RepoURL, localPath, app.Spec.Source.TargetRevision)
+	if err != nil {
+		return "", err
+	}
+
+	if err := gitclient.Checkout(repo, revision); err != nil {
+		return "", err
+	}
+	return gitclient.Head(repo)
 }
 
-func (m *helmManager) gitRepoClone(app *cdv1.Application) error {
+func (m *helmManager) gitRepoClone(ctx context.Context, app *cdv1.Application) error {
 	repo := app.Spec.Source.RepoURL
 	revision := app.Spec.Source.TargetRevision
 	localPath := "/tmp/repo-" + app.Name + "-" + app.Namespace
 
-	_, err := gitclient.Clone(repo, localPath, revision)
+	_, err := gitclient.Clone(ctx, repo, localPath, revision)
 	if err != nil {
 		return err
 	}
@@ -154,7 +411,7 @@ func (m *helmManager) gitRepoClone(app *cdv1.Application) error {
 	return nil
 }
 
-func (m *helmManager) gitPull(app *cdv1.Application) error {
+func (m *helmManager) gitPull(ctx context.Context, app *cdv1.Application) error {
 	clonedRepoPath := "/tmp/repo-" + app.Name + "-" + app.Namespace
 
 	repo, err := gitclient.Open(clonedRepoPath)
@@ -162,7 +419,7 @@ func (m *helmManager) gitPull(app *cdv1.Application) error {
 		return err
 	}
 
-	err = gitclient.Pull(repo)
+	err = gitclient.Pull(ctx, repo)
 	if err != nil {
 		return err
 	}
@@ -170,10 +427,10 @@ func (m *helmManager) gitPull(app *cdv1.Application) error {
 	return nil
 }
 
-func (m *helmManager) objectFromManifest(chartSpec *gohelm.ChartSpec, app *cdv1.Application) ([]*unstructured.Unstructured, error) {
+func (m *helmManager) objectFromManifest(ctx context.Context, chartSpec *gohelm.ChartSpec, app *cdv1.Application) ([]*unstructured.Unstructured, error) {
 	var manifestRawObjs []*unstructured.Unstructured
 
-	manifest, err := m.installHelmChart(chartSpec, app, true)
+	manifest, err := m.installHelmChart(ctx, chartSpec, app, true)
 	if err != nil {
 		return nil, err
 	}
@@ -193,31 +450,33 @@ func (m *helmManager) objectFromManifest(chartSpec *gohelm.ChartSpec, app *cdv1.
 			continue
 		}
 
-		if err := m.setTargetClient(app); err != nil {
-			log.Error(err, "setTargetClient failed..")
-			return nil, err
-		}
-
 		manifestRawObj, err := utils.BytesToUnstructuredObject(bytes)
 		if err != nil {
 			log.Error(err, "BytesToUnstructuredObject failed..")
 			return nil, err
 		}
 
-		if len(manifestRawObj.GetNamespace()) == 0 {
-			manifestRawObj.SetNamespace(app.Spec.Destination.Namespace)
-		}
 		manifestRawObjs = append(manifestRawObjs, manifestRawObj)
 	}
+
+	// Helm installs the objects without a namespace in the namespace of the release
+	if err := setNamespaces(m.TargetCli.RESTMapper(), manifestRawObjs, chartSpec.Namespace, false); err != nil {
+		return nil, err
+	}
+	// The objects are compared with the marks of the application, as they're post-rendered on install
+	for _, obj := range manifestRawObjs {
+		setTrackingMetadata(obj, app)
+	}
 	return manifestRawObjs, nil
 }
 
-func (m *helmManager) installHelmChart(chartSpec *gohelm.ChartSpec, app *cdv1.Application, dryRun bool) (string, error) {
+func (m *helmManager) installHelmChart(ctx context.Context, chartSpec *gohelm.ChartSpec, app *cdv1.Application, dryRun bool) (string, error) {
 	log.Info("Start to install helm chart...")
 	chartSpec.DryRun = dryRun
-	manifest, err := m.helmClient.InstallChart(chartSpec)
+	manifest, err := m.helmClient.InstallChart(ctx, chartSpec)
 	if err != nil {
-		panic(err)
+		log.Error(err, "InstallChart failed..")
+		return "", err
 	}
 
 	return manifest, nil
@@ -255,9 +514,9 @@ func setChartSpec(app *cdv1.Application) *gohelm.ChartSpec {
 	}
 }
 
-func (m *helmManager) setTargetClient(app *cdv1.Application) error {
+func (m *helmManager) setTargetClient(ctx context.Context, app *cdv1.Application) error {
 	if app.Spec.Destination.Name != "" {
-		cfg, err := cluster.GetApplicationClusterConfig(m.Context, m.DefaultCli, app)
+		cfg, err := cluster.GetApplicationClusterConfig(ctx, m.DefaultCli, app)
 		if err != nil {
 			log.Error(err, "GetConfig failed..")
 			return err
@@ -278,6 +537,12 @@ func (m *helmManager) setTargetClient(app *cdv1.Application) error {
 			return err
 		}
 		m.helmClient.Client = cli
+		m.helmClient.SetPostRenderer(&trackingPostRenderer{app: app})
+		targetCli, err := newTargetClient(ctx, m.DefaultCli, app)
+		if err != nil {
+			return err
+		}
+		m.TargetCli = targetCli
 	} else {
 		opt := &gohelm.Options{
 			Namespace:        app.Spec.Destination.Namespace,
@@ -291,12 +556,99 @@ func (m *helmManager) setTargetClient(app *cdv1.Application) error {
 			return err
 		}
 		m.helmClient.Client = cli
+		m.helmClient.SetPostRenderer(&trackingPostRenderer{app: app})
+		targetCli, err := newTargetClient(ctx, m.DefaultCli, app)
+		if err != nil {
+			return err
+		}
+		m.TargetCli = targetCli
 	}
 	return nil
 }
 
-func (m *helmManager) clearDeployResource(deployResource *cdv1.DeployResource) error {
-	if err := m.DefaultCli.Delete(m.Context, deployResource); err != nil {
+func (m *helmManager) pruneDeployResource(ctx context.Context, deployResource *cdv1.DeployResource) error {
+	return deleteDeployResource(ctx, m.DefaultCli, m.TargetCli, deployResource)
+}
+
+// keepDeployedObject sets helm's resource policy annotation on the deployed object, so that helm does not delete it
+func (m *helmManager) keepDeployedObject(ctx context.Context, spec *cdv1.DeployResourceSpec) error {
+	deployedObj, err := getDeployedObject(ctx, m.TargetCli, &cdv1.DeployResource{Spec: *spec})
+	if err != nil || deployedObj == nil {
+		return err
+	}
+
+	annotations := deployedObj.GetAnnotations()
+	if annotations[helmResourcePolicyAnnotation] == helmResourcePolicyKeep {
+		return nil
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[helmResourcePolicyAnnotation] = helmResourcePolicyKeep
+	deployedObj.SetAnnotations(annotations)
+
+	return m.TargetCli.Update(ctx, deployedObj)
+}
+
+// forgetDeployResources stops tracking the resources owned by other applications. They're kept by helm, even if they're
+// removed from the chart or the release is uninstalled
+func (m *helmManager) forgetDeployResources(ctx context.Context, deployResources []cdv1.DeployResource) error {
+	for i := range deployResources {
+		if err := m.keepDeployedObject(ctx, &deployResources[i].Spec); err != nil {
+			log.Error(err, "keepDeployedObject failed..")
+			return err
+		}
+	}
+	return forgetDeployResources(ctx, m.DefaultCli, deployResources)
+}
+
+// sharedResourceError returns the error of the results, whose resources are owned by other applications
+func sharedResourceError(results []*resourceResult) error {
+	var messages []string
+	for _, result := range results {
+		if result.sharedMessage != "" {
+			messages = append(messages, result.sharedMessage)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(messages, ", "))
+}
+
+// trackingPostRenderer marks the rendered objects with the application, before helm installs them
+type trackingPostRenderer struct {
+	app *cdv1.Application
+}
+
+func (r *trackingPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	modified := &bytes.Buffer{}
+	for _, manifest := range utils.SplitMultipleObjectsYAML(renderedManifests.Bytes()) {
+		b, err := yaml.YAMLToJSON([]byte(manifest))
+		if err != nil {
+			return nil, err
+		}
+		if string(b) == "null" {
+			continue
+		}
+		obj, err := utils.BytesToUnstructuredObject(b)
+		if err != nil {
+			return nil, err
+		}
+		setTrackingMetadata(obj, r.app)
+
+		b, err = yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		modified.WriteString("---\n")
+		modified.Write(b)
+	}
+	return modified, nil
+}
+
+func (m *helmManager) clearDeployResource(ctx context.Context, deployResource *cdv1.DeployResource) error {
+	if err := removeDeployResource(ctx, m.DefaultCli, deployResource); err != nil {
 		log.Error(err, "Delete DeployResource error..")
 		return err
 	}
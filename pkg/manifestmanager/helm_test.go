@@ -1,17 +1,30 @@
 package manifestmanager
 
 import (
+	"bytes"
+	"context"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	gohelm "github.com/mittwald/go-helm-client"
 	"github.com/stretchr/testify/require"
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager/utils"
 	"github.com/tmax-cloud/cd-operator/util/helmclient"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/release"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
 )
 
 type gitRepoCloneTestCase struct {
@@ -58,7 +71,7 @@ func TestGitRepoClone(t *testing.T) {
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			err := m.gitRepoClone(c.app)
+			err := m.gitRepoClone(context.Background(), c.app)
 			os.RemoveAll("/tmp/repo-" + c.app.Name + "-" + c.app.Namespace)
 			require.NoError(t, err)
 		})
@@ -108,7 +121,7 @@ func TestInstallHelmChart(t *testing.T) {
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			err := m.gitRepoClone(c.app)
+			err := m.gitRepoClone(context.Background(), c.app)
 			require.NoError(t, err)
 
 			chartSpec := setChartSpec(c.app)
@@ -116,8 +129,152 @@ func TestInstallHelmChart(t *testing.T) {
 				err := m.uninstallRelease(c.app)
 				require.NoError(t, err)
 			}()
-			_, err = m.installHelmChart(chartSpec, c.app, false)
+			_, err = m.installHelmChart(context.Background(), chartSpec, c.app, false)
 			require.NoError(t, err)
 		})
 	}
 }
+
+// chartLoadingHelmClient loads the chart as helm does before installing it, without a cluster
+type chartLoadingHelmClient struct {
+	gohelm.Client
+}
+
+func (c *chartLoadingHelmClient) InstallOrUpgradeChart(_ context.Context, spec *gohelm.ChartSpec) (*release.Release, error) {
+	if _, err := loader.Load(spec.ChartName); err != nil {
+		return nil, err
+	}
+	return &release.Release{}, nil
+}
+
+// newHelmTestRepo creates a local git repository with the files, and returns its path
+func newHelmTestRepo(t *testing.T, files map[string]string) string {
+	path := t.TempDir()
+	repo, err := gogit.PlainInit(path, false)
+	require.NoError(t, err)
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(path, name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(path, name), []byte(content), 0644))
+		_, err := worktree.Add(name)
+		require.NoError(t, err)
+	}
+	_, err = worktree.Commit("init", &gogit.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@tmax.co.kr", When: time.Now()}})
+	require.NoError(t, err)
+	return path
+}
+
+func TestHelmDiffInvalidChart(t *testing.T) {
+	repoPath := newHelmTestRepo(t, map[string]string{
+		"chart/Chart.yaml":         "apiVersion: v2\nversion: 0.1.0\n",
+		"chart/templates/svc.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n",
+	})
+	app := &cdv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "invalid-chart", Namespace: "default"},
+		Spec: cdv1.ApplicationSpec{
+			Source: cdv1.ApplicationSource{
+				RepoURL:        repoPath,
+				Path:           "chart",
+				TargetRevision: "master",
+				Type:           cdv1.ApplicationSourceTypeHelm,
+				Helm:           &cdv1.ApplicationSourceHelm{},
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+	cli := fake.NewClientBuilder().WithScheme(s).Build()
+	m := &helmManager{DefaultCli: cli, TargetCli: cli, helmClient: &helmclient.Client{Client: &chartLoadingHelmClient{}}}
+
+	// An invalid chart fails the diff, instead of crashing the process
+	_, err := m.Diff(context.Background(), app, SyncOptions{Revision: "master"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "chart.metadata.name is required")
+}
+
+func TestTrackingPostRenderer(t *testing.T) {
+	app := newTrackingTestApp("test-app")
+	rendered := bytes.NewBufferString("---\n# Source: chart/templates/svc.yaml\napiVersion: v1\nkind: Service\nmetadata:\n  name: svc\n  labels:\n    app: svc\n---\n# Source: chart/templates/empty.yaml\n\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")
+
+	modified, err := (&trackingPostRenderer{app: app}).Run(rendered)
+	require.NoError(t, err)
+
+	var objs []*unstructured.Unstructured
+	for _, manifest := range utils.SplitMultipleObjectsYAML(modified.Bytes()) {
+		obj := &unstructured.Unstructured{}
+		require.NoError(t, yaml.Unmarshal([]byte(manifest), &obj.Object))
+		objs = append(objs, obj)
+	}
+	require.Len(t, objs, 2)
+	require.Equal(t, map[string]string{"app": "svc", cdv1.LabelAppInstance: instanceLabelValue(app)}, objs[0].GetLabels())
+	require.Equal(t, "ConfigMap", objs[1].GetKind())
+	require.Equal(t, map[string]string{cdv1.LabelAppInstance: instanceLabelValue(app)}, objs[1].GetLabels())
+}
+
+// uninstallingHelmClient uninstalls releases without a cluster
+type uninstallingHelmClient struct {
+	gohelm.Client
+}
+
+func (c *uninstallingHelmClient) UninstallReleaseByName(string) error {
+	return nil
+}
+
+func TestHelmClearSharedResource(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := newTrackingTestApp("test-app")
+	app.Spec.DeletionPolicy = cdv1.DeletionPolicyBackground
+	app.Spec.Source.Helm = &cdv1.ApplicationSourceHelm{}
+	owned := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "owned-svc", Namespace: "test", Labels: map[string]string{cdv1.LabelAppInstance: instanceLabelValue(app)}}}
+	shared := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "shared-svc", Namespace: "test", Labels: map[string]string{cdv1.LabelAppInstance: instanceLabelValue(newTrackingTestApp("other-app"))}}}
+	ownedDR := newDeployResource(app, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "owned-svc"})
+	sharedDR := newDeployResource(app, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "shared-svc"})
+
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(ownedDR, sharedDR).Build()
+	targetCli := fake.NewClientBuilder().WithScheme(s).WithObjects(owned, shared).Build()
+	m := &helmManager{DefaultCli: cli, TargetCli: targetCli, helmClient: &helmclient.Client{Client: &uninstallingHelmClient{}}}
+
+	require.NoError(t, m.Clear(context.Background(), app))
+
+	// Both DeployResources are gone, while the shared resource is kept by helm when the release is uninstalled
+	list, err := getDeployResourceList(context.Background(), cli, app)
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+
+	kept := &corev1.Service{}
+	require.NoError(t, targetCli.Get(context.Background(), types.NamespacedName{Name: "shared-svc", Namespace: "test"}, kept))
+	require.Equal(t, helmResourcePolicyKeep, kept.Annotations[helmResourcePolicyAnnotation])
+	notKept := &corev1.Service{}
+	require.NoError(t, targetCli.Get(context.Background(), types.NamespacedName{Name: "owned-svc", Namespace: "test"}, notKept))
+	require.Empty(t, notKept.Annotations[helmResourcePolicyAnnotation])
+}
+
+func TestHelmCompareSharedResource(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := newTrackingTestApp("test-app")
+	shared := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "test", Labels: map[string]string{cdv1.LabelAppInstance: instanceLabelValue(newTrackingTestApp("other-app"))}}}
+	cli := fake.NewClientBuilder().WithScheme(s).Build()
+	m := &helmManager{DefaultCli: cli, TargetCli: fake.NewClientBuilder().WithScheme(s).WithObjects(shared).Build()}
+
+	obj := newNamespaceTestObj("v1", "Service", "test", "svc")
+	result, err := m.compareResource(context.Background(), app, obj, true)
+	require.NoError(t, err)
+	require.Equal(t, "Service test/svc is owned by application default_other-app", result.sharedMessage)
+	require.Nil(t, result.deployResource)
+	require.EqualError(t, sharedResourceError([]*resourceResult{result}), result.sharedMessage)
+
+	// The shared resource is not tracked by the application
+	list, err := getDeployResourceList(context.Background(), cli, app)
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+}
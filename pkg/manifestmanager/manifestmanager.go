@@ -2,68 +2,387 @@ package manifestmanager
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
 	"strings"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/cluster"
+	"github.com/tmax-cloud/cd-operator/pkg/health"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var log = logf.Log.WithName("manifest-manager")
 
+// SyncOptions are options of a sync
+type SyncOptions struct {
+	// Forced applies the manifests regardless of the automated sync policy. The sync is regarded as a sync, even if
+	// nothing is changed
+	Forced bool
+	// AutoSync controls which changes are applied, if the sync is not forced.
+	// If none of them is enabled, the application is only compared with the live state
+	AutoSync AutoSyncOptions
+	// Revision overrides the target revision of the application, e.g., for a rollback. It can be a commit SHA
+	Revision string
+	// Terminated is checked before applying or pruning resources. The sync stops with ErrTerminated if it returns true
+	Terminated func() bool
+	// Result collects the results of the resources which are applied or pruned, if it's not nil
+	Result *cdv1.SyncOperationResult
+	// Resources to be synced. If neither Resources nor LabelSelectors is set, all resources are synced
+	Resources []cdv1.SyncOperationResource
+	// LabelSelectors select the resources to be synced by their labels, in addition to Resources
+	LabelSelectors []metav1.LabelSelector
+	// Prune overrides syncPolicy.prune of the application
+	Prune *bool
+}
+
+// AutoSyncOptions are options of an automated sync
+type AutoSyncOptions struct {
+	// OnCommit applies a revision, which is not synced yet
+	OnCommit bool
+	// SelfHeal reverts the drift of the live state from the synced revision
+	SelfHeal bool
+	// SyncedRevision is the revision synced last. It's empty if the source of the application is changed since then
+	SyncedRevision string
+}
+
+// ErrTerminated is returned when the sync is terminated
+var ErrTerminated = fmt.Errorf("operation is terminated")
+
+// ErrDeletionInProgress is returned by Clear, while the resources deleted in the foreground are still being deleted
+var ErrDeletionInProgress = fmt.Errorf("resources are being deleted")
+
+// isApplyEnabled checks if the manifests of the revision can be applied
+func (o SyncOptions) isApplyEnabled(revision string) bool {
+	if o.Forced {
+		return true
+	}
+	if revision != o.AutoSync.SyncedRevision {
+		return o.AutoSync.OnCommit
+	}
+	return o.AutoSync.SelfHeal
+}
+
+// isTerminated checks if the sync is terminated
+func (o SyncOptions) isTerminated() bool {
+	return o.Terminated != nil && o.Terminated()
+}
+
+// IsSelective checks if only a subset of the resources is to be synced
+func (o SyncOptions) IsSelective() bool {
+	return len(o.Resources) > 0 || len(o.LabelSelectors) > 0
+}
+
+// IsSelected checks if the resource of the group/kind, namespace, name and labels is to be synced, i.e., it's one of
+// the resources or it matches any of the label selectors
+func (o SyncOptions) IsSelected(group, kind, namespace, name string, objLabels map[string]string) bool {
+	if !o.IsSelective() {
+		return true
+	}
+	for _, r := range o.Resources {
+		if r.Matches(group, kind, namespace, name) {
+			return true
+		}
+	}
+	for i := range o.LabelSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&o.LabelSelectors[i])
+		if err != nil {
+			log.Error(err, "LabelSelectorAsSelector failed..")
+			continue
+		}
+		if selector.Matches(labels.Set(objLabels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isObjectSelected checks if the object is to be synced
+func (o SyncOptions) isObjectSelected(obj *unstructured.Unstructured) bool {
+	return o.IsSelected(obj.GroupVersionKind().Group, obj.GetKind(), obj.GetNamespace(), obj.GetName(), obj.GetLabels())
+}
+
+// selectPruneTargets splits the prune targets into the ones to be synced and the others. The label selectors are
+// matched against the live objects of the targets
+func (o SyncOptions) selectPruneTargets(ctx context.Context, targetCli client.Client, targets []cdv1.DeployResource) ([]cdv1.DeployResource, []cdv1.DeployResource, error) {
+	var selected, others []cdv1.DeployResource
+	for i := range targets {
+		target := targets[i]
+		var objLabels map[string]string
+		if len(o.LabelSelectors) > 0 {
+			deployedObj, err := getDeployedObject(ctx, targetCli, &target)
+			if err != nil {
+				return nil, nil, err
+			}
+			if deployedObj != nil {
+				objLabels = deployedObj.GetLabels()
+			}
+		}
+
+		gv, _ := schema.ParseGroupVersion(target.Spec.APIVersion)
+		if o.IsSelected(gv.Group, target.Spec.Kind, target.Spec.Namespace, target.Spec.Name, objLabels) {
+			selected = append(selected, target)
+		} else {
+			others = append(others, target)
+		}
+	}
+	return selected, others, nil
+}
+
+// ManifestManager syncs the resources of an application with its manifests. The requests to git and to the clusters
+// are cancelled when the context is done
 type ManifestManager interface {
-	Sync(app *cdv1.Application, forced bool) error
-	Clear(app *cdv1.Application) error
+	Sync(ctx context.Context, app *cdv1.Application, opts SyncOptions) error
+	// Clear deletes the resources of the application following its deletion policy, and stops tracking them.
+	// It returns ErrDeletionInProgress, if it's to be called again to wait for the resources deleted in the foreground
+	Clear(ctx context.Context, app *cdv1.Application) error
+	// Diff compares the desired state of the revision of the options with the live cluster, without applying anything.
+	// Only the resources selected by the options are compared
+	// If revision is empty, the target revision of the application is used
+	Diff(ctx context.Context, app *cdv1.Application, opts SyncOptions) ([]ResourceDiff, error)
 }
 
-func getDeployResourceList(cli client.Client, app *cdv1.Application) (*cdv1.DeployResourceList, error) {
+// newTargetClient creates a client for the application's destination cluster
+func newTargetClient(ctx context.Context, defaultCli client.Client, app *cdv1.Application) (client.Client, error) {
+	// The requests to the cluster are limited, as the resources are synced concurrently
+	limiter, err := cluster.GetRateLimiter(ctx, defaultCli, app)
+	if err != nil {
+		log.Error(err, "GetRateLimiter failed..")
+		return nil, err
+	}
+	if app.Spec.Destination.Name == "" {
+		return cluster.NewRateLimitedClient(defaultCli, limiter), nil
+	}
+
+	cfg, err := cluster.GetApplicationClusterConfig(ctx, defaultCli, app)
+	if err != nil {
+		log.Error(err, "GetConfig failed..")
+		return nil, err
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+	c, err := client.New(cfg, client.Options{Scheme: s})
+	if err != nil {
+		log.Error(err, "Create client failed..")
+		return nil, err
+	}
+	return cluster.NewRateLimitedClient(c, limiter), nil
+}
+
+// mergeManifest merges the manifest into the deployed object, and fills manifestObj with the object which the
+// server would persist on update
+func mergeManifest(ctx context.Context, cli client.Client, deployedObj, manifestObj *unstructured.Unstructured) error {
+	bytedDeployedObj, _ := deployedObj.MarshalJSON()
+	bytedManifestObj, _ := manifestObj.MarshalJSON()
+
+	patchedByte, _ := jsonpatch.MergePatch(bytedDeployedObj, bytedManifestObj)
+
+	patchedObj := make(map[string]interface{})
+	if err := json.Unmarshal(patchedByte, &patchedObj); err != nil {
+		return err
+	}
+
+	manifestObj.SetUnstructuredContent(patchedObj)
+	return cli.Update(ctx, manifestObj, client.DryRunAll)
+}
+
+func getDeployResourceList(ctx context.Context, cli client.Client, app *cdv1.Application) (*cdv1.DeployResourceList, error) {
 	deployResourceList := &cdv1.DeployResourceList{}
 
-	if err := cli.List(context.Background(), deployResourceList, client.MatchingLabels{"cd.tmax.io/application": app.Name + "-" + app.Namespace}); err != nil {
+	if err := cli.List(ctx, deployResourceList, client.MatchingLabels{cdv1.DeployResourceLabelApplication: applicationLabelValue(app)}); err != nil {
 		return nil, err
 	}
 	return deployResourceList, nil
 }
 
-func updateDeployResource(cli client.Client, unstObj *unstructured.Unstructured, app *cdv1.Application) (*cdv1.DeployResource, error) {
-	deployResource := &cdv1.DeployResource{
+// maxDeployResourceNamePrefix is the maximum length of the readable prefix of a DeployResource name, which is
+// followed by the hash. A name must not be longer than 253 characters
+const maxDeployResourceNamePrefix = 200
+
+// deployResourceName returns the name of the DeployResource, which tracks the object of the application
+func deployResourceName(app *cdv1.Application, unstObj *unstructured.Unstructured) string {
+	return newDeployResourceName(app, unstObj.GroupVersionKind().Group, unstObj.GetKind(), unstObj.GetNamespace(), unstObj.GetName())
+}
+
+// specDeployResourceName returns the name of the DeployResource of the spec. It differs from the name of the
+// DeployResource, if the DeployResource was created with the old naming
+func specDeployResourceName(app *cdv1.Application, spec cdv1.DeployResourceSpec) string {
+	gv, _ := schema.ParseGroupVersion(spec.APIVersion)
+	return newDeployResourceName(app, gv.Group, spec.Kind, spec.Namespace, spec.Name)
+}
+
+// newDeployResourceName returns a deterministic name of the DeployResource, i.e., <application>-<kind>-<name>-<hash>.
+// The readable prefix is sanitized and truncated, and the hash of the application, group, kind, namespace and name
+// keeps the name unique
+func newDeployResourceName(app *cdv1.Application, group, kind, namespace, name string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{app.Name, group, kind, namespace, name}, "/")))
+
+	prefix := invalidNameChars.ReplaceAllString(strings.ToLower(app.Name+"-"+kind+"-"+name), "-")
+	if len(prefix) > maxDeployResourceNamePrefix {
+		prefix = prefix[:maxDeployResourceNamePrefix]
+	}
+	return strings.Trim(prefix, "-") + "-" + hex.EncodeToString(sum[:])[:10]
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9]+")
+
+// deployResourceLabels returns the labels of the DeployResource, with which the DeployResources are looked up
+func deployResourceLabels(app *cdv1.Application, spec cdv1.DeployResourceSpec) map[string]string {
+	gv, _ := schema.ParseGroupVersion(spec.APIVersion)
+	return map[string]string{
+		cdv1.DeployResourceLabelApplication: applicationLabelValue(app),
+		cdv1.DeployResourceLabelGroup:       shortenLabelValue(gv.Group),
+		cdv1.DeployResourceLabelKind:        shortenLabelValue(spec.Kind),
+		cdv1.DeployResourceLabelNamespace:   shortenLabelValue(spec.Namespace),
+		cdv1.DeployResourceLabelName:        shortenLabelValue(spec.Name),
+	}
+}
+
+// applicationLabelValue returns the application label value of the DeployResources of the application
+func applicationLabelValue(app *cdv1.Application) string {
+	return shortenLabelValue(app.Name + "-" + app.Namespace)
+}
+
+// shortenLabelValue shortens the value longer than 63 characters with its hash, so that it can be a label value
+func shortenLabelValue(v string) string {
+	if len(v) <= validation.LabelValueMaxLength {
+		return v
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(v))
+	return fmt.Sprintf("%s-%08x", v[:validation.LabelValueMaxLength-9], h.Sum32())
+}
+
+// newDeployResource returns a DeployResource of the spec, which is owned by the application. It's garbage-collected
+// with the application, even if the application is deleted without its finalizer
+func newDeployResource(app *cdv1.Application, spec cdv1.DeployResourceSpec) *cdv1.DeployResource {
+	return &cdv1.DeployResource{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      strings.ToLower(app.Name + "-" + unstObj.GetKind() + "-" + unstObj.GetName() + "-" + unstObj.GetNamespace()),
+			Name:      specDeployResourceName(app, spec),
 			Namespace: app.Namespace,
-			Labels:    map[string]string{"cd.tmax.io/application": app.Name + "-" + app.Namespace},
+			Labels:    deployResourceLabels(app, spec),
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         cdv1.GroupVersion.String(),
+				Kind:               "Application",
+				Name:               app.Name,
+				UID:                app.UID,
+				Controller:         pointer.BoolPtr(true),
+				BlockOwnerDeletion: pointer.BoolPtr(true),
+			}},
+			Finalizers: []string{cdv1.DeployResourceFinalizer},
 		},
 		Application: app.Name,
-		Spec: cdv1.DeployResourceSpec{
-			APIVersion: unstObj.GetAPIVersion(),
-			Name:       unstObj.GetName(),
-			Kind:       unstObj.GetKind(),
-			Namespace:  unstObj.GetNamespace(),
-		},
+		Spec:        spec,
 	}
+}
 
-	if err := cli.Get(context.Background(), types.NamespacedName{
-		Name:      strings.ToLower(app.Name + "-" + unstObj.GetKind() + "-" + unstObj.GetName() + "-" + unstObj.GetNamespace()),
+func updateDeployResource(ctx context.Context, cli client.Client, unstObj *unstructured.Unstructured, app *cdv1.Application) (*cdv1.DeployResource, error) {
+	deployResource := newDeployResource(app, cdv1.DeployResourceSpec{
+		APIVersion: unstObj.GetAPIVersion(),
+		Name:       unstObj.GetName(),
+		Kind:       unstObj.GetKind(),
+		Namespace:  unstObj.GetNamespace(),
+	})
+
+	if err := cli.Get(ctx, types.NamespacedName{
+		Name:      deployResource.Name,
 		Namespace: app.Namespace}, deployResource); err != nil {
 		if !errors.IsNotFound(err) {
 			return nil, err
 		}
-		if err := cli.Create(context.Background(), deployResource); err != nil {
+		if err := cli.Create(ctx, deployResource); err != nil {
 			return nil, err
 		}
 		return deployResource, nil
 	}
 
+	// The DeployResources created before the finalizer was introduced get it on the next sync
+	if !controllerutil.ContainsFinalizer(deployResource, cdv1.DeployResourceFinalizer) {
+		patch := client.MergeFrom(deployResource.DeepCopy())
+		controllerutil.AddFinalizer(deployResource, cdv1.DeployResourceFinalizer)
+		if err := cli.Patch(ctx, deployResource, patch); err != nil {
+			return nil, err
+		}
+	}
 	return deployResource, nil
 }
 
-func deleteDeployResource(cli client.Client, deployResource *cdv1.DeployResource) error {
+// removeDeployResource removes the finalizer of the DeployResource and deletes it
+func removeDeployResource(ctx context.Context, cli client.Client, deployResource *cdv1.DeployResource) error {
+	if controllerutil.ContainsFinalizer(deployResource, cdv1.DeployResourceFinalizer) {
+		patch := client.MergeFrom(deployResource.DeepCopy())
+		controllerutil.RemoveFinalizer(deployResource, cdv1.DeployResourceFinalizer)
+		if err := cli.Patch(ctx, deployResource, patch); err != nil {
+			return err
+		}
+		// The DeployResource is gone with its finalizer, if it's already being deleted
+		if deployResource.DeletionTimestamp != nil {
+			return nil
+		}
+	}
+	return cli.Delete(ctx, deployResource)
+}
+
+// migrateDeployResources renames the DeployResources created with the old naming, i.e., lower(app-kind-name-namespace),
+// so that each resource is tracked by a single DeployResource. The statuses are kept
+func migrateDeployResources(ctx context.Context, cli client.Client, app *cdv1.Application, deployResources *cdv1.DeployResourceList) (*cdv1.DeployResourceList, error) {
+	migrated := &cdv1.DeployResourceList{}
+	for i := range deployResources.Items {
+		old := &deployResources.Items[i]
+		if old.Name == specDeployResourceName(app, old.Spec) {
+			migrated.Items = append(migrated.Items, *old)
+			continue
+		}
+
+		log.Info(fmt.Sprintf("Renaming DeployResource %s to %s", old.Name, specDeployResourceName(app, old.Spec)))
+		deployResource := newDeployResource(app, old.Spec)
+		if err := cli.Create(ctx, deployResource); err != nil {
+			if !errors.IsAlreadyExists(err) {
+				return nil, err
+			}
+			if err := cli.Get(ctx, types.NamespacedName{Name: deployResource.Name, Namespace: deployResource.Namespace}, deployResource); err != nil {
+				return nil, err
+			}
+		} else {
+			deployResource.Status = old.Status
+			if err := cli.Status().Update(ctx, deployResource); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := removeDeployResource(ctx, cli, old); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		migrated.Items = append(migrated.Items, *deployResource)
+	}
+	return migrated, nil
+}
+
+// deleteDeployResource deletes the DeployResource, and its live object on the destination cluster
+func deleteDeployResource(ctx context.Context, cli, targetCli client.Client, deployResource *cdv1.DeployResource) error {
 	deployedObj := &unstructured.Unstructured{}
 
-	if err := cli.Delete(context.Background(), deployResource); err != nil {
+	if err := removeDeployResource(ctx, cli, deployResource); err != nil {
 		log.Error(err, "Delete DeployResource error..")
 		return err
 	}
@@ -73,7 +392,7 @@ func deleteDeployResource(cli client.Client, deployResource *cdv1.DeployResource
 	deployedObj.SetName(deployResource.Spec.Name)
 	deployedObj.SetNamespace(deployResource.Spec.Namespace)
 
-	if err := cli.Get(context.Background(), types.NamespacedName{Namespace: deployedObj.GetNamespace(), Name: deployedObj.GetName()}, deployedObj); err != nil {
+	if err := targetCli.Get(ctx, types.NamespacedName{Namespace: deployedObj.GetNamespace(), Name: deployedObj.GetName()}, deployedObj); err != nil {
 		if !errors.IsNotFound(err) {
 			log.Error(err, "Get deprecated resource error..")
 			return err
@@ -81,10 +400,371 @@ func deleteDeployResource(cli client.Client, deployResource *cdv1.DeployResource
 		return nil
 	}
 
-	if err := cli.Delete(context.Background(), deployedObj); err != nil {
+	if err := targetCli.Delete(ctx, deployedObj); err != nil {
 		log.Error(err, "Delete deprecated resource error..")
 		return err
 	}
 
 	return nil
 }
+
+// getPruneTargets returns the tracked resources which are not in the desired manifests
+func getPruneTargets(app *cdv1.Application, oldDeployResources *cdv1.DeployResourceList, manifestObjs []*unstructured.Unstructured) []cdv1.DeployResource {
+	desired := make(map[string]bool)
+	for _, obj := range manifestObjs {
+		desired[deployResourceName(app, obj)] = true
+	}
+
+	var targets []cdv1.DeployResource
+	for _, oldDeployResource := range oldDeployResources.Items {
+		if !desired[specDeployResourceName(app, oldDeployResource.Spec)] {
+			targets = append(targets, oldDeployResource)
+		}
+	}
+	return targets
+}
+
+// isPruneEnabled checks if the sync is allowed to prune resources. Pruning is done only if it is enabled in the
+// sync policy (or the options) and the sync actually applies the manifests of the revision
+func isPruneEnabled(app *cdv1.Application, opts SyncOptions, revision string) bool {
+	prune := app.Spec.SyncPolicy.Prune
+	if opts.Prune != nil {
+		prune = *opts.Prune
+	}
+	return prune && opts.isApplyEnabled(revision)
+}
+
+// checkPruneLimit refuses a sync if it would prune more resources than the application allows
+func checkPruneLimit(app *cdv1.Application, prune bool, pruneCount, trackedCount int) error {
+	policy := app.Spec.SyncPolicy
+
+	var msg string
+	if prune && policy.MaxPruneCount > 0 && pruneCount > policy.MaxPruneCount {
+		msg = fmt.Sprintf("sync would prune %d resources, which exceeds maxPruneCount %d", pruneCount, policy.MaxPruneCount)
+	} else if prune && policy.MaxPrunePercentage > 0 && pruneCount*100 > policy.MaxPrunePercentage*trackedCount {
+		msg = fmt.Sprintf("sync would prune %d of %d resources, which exceeds maxPrunePercentage %d%%", pruneCount, trackedCount, policy.MaxPrunePercentage)
+	}
+
+	if msg == "" {
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionPruneLimitExceeded)
+		return nil
+	}
+
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:    cdv1.ApplicationConditionPruneLimitExceeded,
+		Status:  metav1.ConditionTrue,
+		Reason:  "PruneLimitExceeded",
+		Message: msg,
+	})
+	return fmt.Errorf("%s", msg)
+}
+
+// pruneDeployResources deletes the prune targets with clear. It returns the targets which are kept, as pruning is
+// disabled for this sync or for the resource itself
+func pruneDeployResources(ctx context.Context, targetCli client.Client, app *cdv1.Application, targets []cdv1.DeployResource, prune bool, clear func(context.Context, *cdv1.DeployResource) error) ([]cdv1.DeployResource, error) {
+	var kept []cdv1.DeployResource
+
+	for i := range targets {
+		target := &targets[i]
+
+		pruneTarget := prune
+		if pruneTarget {
+			deployedObj, err := getDeployedObject(ctx, targetCli, target)
+			if err != nil {
+				return nil, err
+			}
+			if deployedObj != nil && hasSyncOption(deployedObj, cdv1.SyncOptionPruneDisabled) {
+				pruneTarget = false
+			}
+		}
+
+		if !pruneTarget {
+			log.Info(fmt.Sprintf("Skip pruning %s %s/%s", target.Spec.Kind, target.Spec.Namespace, target.Spec.Name))
+			kept = append(kept, *target)
+			continue
+		}
+
+		if err := clear(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(kept) > 0 {
+		app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
+	}
+
+	return kept, nil
+}
+
+// deleteDeployedObject deletes the live object of the deploy resource with the propagation policy, unless it's
+// already being deleted or it's owned by another application. It returns true if the object still exists and is
+// being deleted
+func deleteDeployedObject(ctx context.Context, cli client.Client, app *cdv1.Application, deployResource *cdv1.DeployResource, propagation metav1.DeletionPropagation) (bool, error) {
+	deployedObj, err := getDeployedObject(ctx, cli, deployResource)
+	if err != nil || deployedObj == nil {
+		return false, err
+	}
+	if owner, owned := getTrackingOwner(deployedObj, app); owner != "" && !owned {
+		log.Info(fmt.Sprintf("Skip deleting %s, which is owned by application %s", objectString(deployedObj), owner))
+		return false, nil
+	}
+	if deployedObj.GetDeletionTimestamp() != nil {
+		return true, nil
+	}
+
+	if err := cli.Delete(ctx, deployedObj, client.PropagationPolicy(propagation)); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// getDeployedObject gets the live object of the deploy resource. It returns nil if the object does not exist
+func getDeployedObject(ctx context.Context, cli client.Client, deployResource *cdv1.DeployResource) (*unstructured.Unstructured, error) {
+	deployedObj := &unstructured.Unstructured{}
+	deployedObj.SetAPIVersion(deployResource.Spec.APIVersion)
+	deployedObj.SetKind(deployResource.Spec.Kind)
+
+	if err := cli.Get(ctx, types.NamespacedName{Namespace: deployResource.Spec.Namespace, Name: deployResource.Spec.Name}, deployedObj); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return deployedObj, nil
+}
+
+// hasSyncOption checks if the option is set in the sync options annotation of the object
+func hasSyncOption(obj metav1.Object, option string) bool {
+	options, exist := obj.GetAnnotations()[cdv1.AnnotationSyncOptions]
+	if !exist {
+		return false
+	}
+	for _, o := range strings.Split(options, ",") {
+		if strings.TrimSpace(o) == option {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceResult is a result of the comparison/application of a resource in the manifests,
+// which is recorded in the status of its DeployResource
+type resourceResult struct {
+	obj            *unstructured.Unstructured
+	deployResource *cdv1.DeployResource
+	status         cdv1.DeployResourceStatus
+
+	// Result of the sync operation, which is empty if the resource is not applied
+	resultCode    cdv1.ResultCode
+	resultMessage string
+
+	// sharedMessage is the reason why the resource can't be managed by the application. The DeployResource is nil then
+	sharedMessage string
+}
+
+func newResourceResult(obj *unstructured.Unstructured, deployResource *cdv1.DeployResource) *resourceResult {
+	return &resourceResult{
+		obj:            obj,
+		deployResource: deployResource,
+		status:         *deployResource.Status.DeepCopy(),
+	}
+}
+
+// newSharedResourceResult returns the result of the resource, which can't be managed by the application
+func newSharedResourceResult(obj *unstructured.Unstructured, msg string) *resourceResult {
+	return &resourceResult{
+		obj:           obj,
+		status:        cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeOutOfSync, DiffSummary: msg},
+		sharedMessage: msg,
+	}
+}
+
+// setApplied records that the resource is applied successfully
+func (r *resourceResult) setApplied(revision string) {
+	now := metav1.Now()
+	r.status.SyncStatus = cdv1.SyncStatusCodeSynced
+	r.status.LastAppliedRevision = revision
+	r.status.LastAppliedTime = &now
+	r.status.LastApplyError = ""
+	r.status.DiffSummary = ""
+	r.resultCode = cdv1.ResultCodeSynced
+	r.resultMessage = "applied"
+}
+
+// setRecreated records that the resource is deleted and created again, and why
+func (r *resourceResult) setRecreated(revision, reason string) {
+	r.setApplied(revision)
+	r.resultCode = cdv1.ResultCodeRecreated
+	r.resultMessage = "recreated, as " + reason
+}
+
+// setApplyError records that the resource failed to be applied
+func (r *resourceResult) setApplyError(err error) {
+	r.status.SyncStatus = cdv1.SyncStatusCodeOutOfSync
+	r.status.LastApplyError = err.Error()
+	r.resultCode = cdv1.ResultCodeSyncFailed
+	r.resultMessage = err.Error()
+}
+
+// updateDeployResourceStatus updates the status of the DeployResource, if it's changed
+func updateDeployResourceStatus(ctx context.Context, cli client.Client, result *resourceResult) error {
+	if result.deployResource == nil || equality.Semantic.DeepEqual(result.deployResource.Status, result.status) {
+		return nil
+	}
+
+	result.deployResource.Status = result.status
+	return cli.Status().Update(ctx, result.deployResource)
+}
+
+// updateDeployResourceStatuses updates the statuses of all DeployResources of the results
+func updateDeployResourceStatuses(ctx context.Context, cli client.Client, results []*resourceResult) error {
+	for _, result := range results {
+		if err := updateDeployResourceStatus(ctx, cli, result); err != nil {
+			log.Error(err, "updateDeployResourceStatus failed..")
+			return err
+		}
+	}
+	return nil
+}
+
+// setComparedTo records the revision and the snapshot of the source and the destination, which the application
+// was compared to
+func setComparedTo(app *cdv1.Application, revision string) {
+	source := app.Spec.Source.DeepCopy()
+	source.Token = nil
+
+	app.Status.Sync.Revision = revision
+	app.Status.Sync.ComparedTo = cdv1.ComparedTo{
+		Source:      *source,
+		Destination: app.Spec.Destination,
+	}
+}
+
+// setApplicationResources sets the resources of the application status, from the results of the resources in the
+// manifests and the resources which require pruning
+func setApplicationResources(app *cdv1.Application, results []*resourceResult, requirePruning []cdv1.DeployResource) {
+	resources := []cdv1.ResourceStatus{}
+	for _, result := range results {
+		gvk := result.obj.GroupVersionKind()
+		resource := cdv1.ResourceStatus{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: result.obj.GetNamespace(),
+			Name:      result.obj.GetName(),
+			Status:    result.status.SyncStatus,
+		}
+		if result.status.HealthStatus != "" {
+			resource.Health = &cdv1.HealthStatus{Status: result.status.HealthStatus, Message: result.status.HealthMessage}
+		}
+		resources = append(resources, resource)
+	}
+
+	for _, dr := range requirePruning {
+		gv, _ := schema.ParseGroupVersion(dr.Spec.APIVersion)
+		resources = append(resources, cdv1.ResourceStatus{
+			Group:           gv.Group,
+			Version:         gv.Version,
+			Kind:            dr.Spec.Kind,
+			Namespace:       dr.Spec.Namespace,
+			Name:            dr.Spec.Name,
+			Status:          cdv1.SyncStatusCodeOutOfSync,
+			RequiresPruning: true,
+		})
+	}
+
+	app.Status.Resources = resources
+}
+
+// setSyncResult records the results of the resources which are applied or pruned by the sync, if the result is
+// requested in the options. pruneTargets are pruned, except the ones which are kept
+func setSyncResult(opts SyncOptions, revision string, results []*resourceResult, pruneTargets, kept []cdv1.DeployResource) {
+	if opts.Result == nil {
+		return
+	}
+
+	opts.Result.Revision = revision
+	opts.Result.Resources = nil
+	for _, result := range results {
+		if result.resultCode == "" {
+			continue
+		}
+		gvk := result.obj.GroupVersionKind()
+		opts.Result.Resources = append(opts.Result.Resources, cdv1.ResourceResult{
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Kind:      gvk.Kind,
+			Namespace: result.obj.GetNamespace(),
+			Name:      result.obj.GetName(),
+			Status:    result.resultCode,
+			Message:   result.resultMessage,
+		})
+	}
+
+	keptNames := map[string]bool{}
+	for _, dr := range kept {
+		keptNames[dr.Name] = true
+	}
+	for _, dr := range pruneTargets {
+		gv, _ := schema.ParseGroupVersion(dr.Spec.APIVersion)
+		resource := cdv1.ResourceResult{
+			Group:     gv.Group,
+			Version:   gv.Version,
+			Kind:      dr.Spec.Kind,
+			Namespace: dr.Spec.Namespace,
+			Name:      dr.Spec.Name,
+			Status:    cdv1.ResultCodePruned,
+			Message:   "pruned",
+		}
+		if keptNames[dr.Name] {
+			resource.Status = cdv1.ResultCodePruneSkipped
+			resource.Message = "requires pruning"
+		}
+		opts.Result.Resources = append(opts.Result.Resources, resource)
+	}
+}
+
+// setApplicationHealth assesses the health of the live resources and sets the aggregated health of the application
+func setApplicationHealth(ctx context.Context, cli client.Client, app *cdv1.Application, results []*resourceResult) error {
+	statuses := map[string]*health.Status{}
+	for _, result := range results {
+		liveObj := &unstructured.Unstructured{}
+		liveObj.SetGroupVersionKind(result.obj.GroupVersionKind())
+		if err := cli.Get(ctx, types.NamespacedName{Namespace: result.obj.GetNamespace(), Name: result.obj.GetName()}, liveObj); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			liveObj = nil
+		}
+
+		status, err := health.GetResourceHealth(liveObj)
+		if err != nil {
+			log.Error(err, "GetResourceHealth failed..")
+		}
+		statuses[healthKey(result.obj)] = status
+
+		if status != nil {
+			result.status.HealthStatus = status.Status
+			result.status.HealthMessage = status.Message
+		} else {
+			result.status.HealthStatus = ""
+			result.status.HealthMessage = ""
+		}
+	}
+
+	app.Status.Health = health.Aggregate(statuses)
+	return nil
+}
+
+// healthKey identifies the resource in the aggregated health. Resources of the same kind and name in different groups
+// or namespaces are distinguished
+func healthKey(obj *unstructured.Unstructured) string {
+	groupKind := obj.GroupVersionKind().GroupKind().String()
+	if obj.GetNamespace() == "" {
+		return groupKind + "/" + obj.GetName()
+	}
+	return groupKind + " " + obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -2,7 +2,9 @@ package manifestmanager
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus"
@@ -11,11 +13,13 @@ import (
 	"github.com/tmax-cloud/cd-operator/pkg/httpclient"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -111,11 +115,11 @@ func TestGetDeployResourceList(t *testing.T) {
 
 	mockHTTPClient := &httpclient.MockHTTPClient{}
 	mockClient := fake.NewClientBuilder().WithLists(testDeployList).WithScheme(s).Build()
-	m := plainYamlManager{DefaultCli: mockClient, Context: context.Background(), HTTPClient: mockHTTPClient}
+	m := plainYamlManager{DefaultCli: mockClient, HTTPClient: mockHTTPClient}
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			deployList, err := getDeployResourceList(m.DefaultCli, c.app)
+			deployList, err := getDeployResourceList(context.Background(), m.DefaultCli, c.app)
 			if c.expectedErrOccur {
 				require.Equal(t, c.expectedErrMsg, err.Error())
 			} else {
@@ -135,53 +139,6 @@ type updateDeployResourceTestCase struct {
 }
 
 func TestUpdateDeployResource(t *testing.T) {
-	tc := map[string]updateDeployResourceTestCase{
-		"exist": {
-			unstObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "exist-obj", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 80}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
-
-			expectedDeployResource: &cdv1.DeployResource{
-				TypeMeta: metav1.TypeMeta{
-					Kind:       "DeployResource",
-					APIVersion: "cd.tmax.io/v1",
-				},
-				ObjectMeta: metav1.ObjectMeta{
-					Name:            "application-service-exist-obj-test",
-					Namespace:       "default",
-					ResourceVersion: "999",
-					Labels:          map[string]string{"cd.tmax.io/application": "application-default"},
-				},
-				Application: "application",
-				Spec: cdv1.DeployResourceSpec{
-					APIVersion: "v1",
-					Name:       "exist-obj",
-					Kind:       "Service",
-					Namespace:  "test",
-				},
-			},
-			expectedErrOccur: false,
-		},
-		"create": {
-			unstObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "new-obj", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 80}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
-
-			expectedDeployResource: &cdv1.DeployResource{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:            "application-service-new-obj-test",
-					Namespace:       "default",
-					Labels:          map[string]string{"cd.tmax.io/application": "application-default"},
-					ResourceVersion: "1",
-				},
-				Application: "application",
-				Spec: cdv1.DeployResourceSpec{
-					APIVersion: "v1",
-					Name:       "new-obj",
-					Kind:       "Service",
-					Namespace:  "test",
-				},
-			},
-			expectedErrOccur: false,
-		},
-	}
-
 	app := &cdv1.Application{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "application",
@@ -189,18 +146,37 @@ func TestUpdateDeployResource(t *testing.T) {
 		},
 	}
 
-	testDeployResource := &cdv1.DeployResource{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      "application-service-exist-obj-test",
-			Namespace: "default",
-			Labels:    map[string]string{"cd.tmax.io/application": "application-default"},
+	testDeployResource := newDeployResource(app, cdv1.DeployResourceSpec{
+		APIVersion: "v1",
+		Name:       "exist-obj",
+		Kind:       "Service",
+		Namespace:  "test",
+	})
+
+	expectedExist := testDeployResource.DeepCopy()
+	expectedExist.TypeMeta = metav1.TypeMeta{Kind: "DeployResource", APIVersion: "cd.tmax.io/v1"}
+	expectedExist.ResourceVersion = "999"
+
+	expectedCreated := newDeployResource(app, cdv1.DeployResourceSpec{
+		APIVersion: "v1",
+		Name:       "new-obj",
+		Kind:       "Service",
+		Namespace:  "test",
+	})
+	expectedCreated.ResourceVersion = "1"
+
+	tc := map[string]updateDeployResourceTestCase{
+		"exist": {
+			unstObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "exist-obj", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 80}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+
+			expectedDeployResource: expectedExist,
+			expectedErrOccur:       false,
 		},
-		Application: "application",
-		Spec: cdv1.DeployResourceSpec{
-			APIVersion: "v1",
-			Name:       "exist-obj",
-			Kind:       "Service",
-			Namespace:  "test",
+		"create": {
+			unstObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "new-obj", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 80}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+
+			expectedDeployResource: expectedCreated,
+			expectedErrOccur:       false,
 		},
 	}
 
@@ -212,7 +188,7 @@ func TestUpdateDeployResource(t *testing.T) {
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			deployResource, err := updateDeployResource(mockClient, c.unstObj, app)
+			deployResource, err := updateDeployResource(context.Background(), mockClient, c.unstObj, app)
 			if !c.expectedErrOccur {
 				require.NoError(t, err)
 				require.Equal(t, c.expectedDeployResource, deployResource)
@@ -225,6 +201,96 @@ func TestUpdateDeployResource(t *testing.T) {
 	}
 }
 
+type deployResourceNameTestCase struct {
+	group     string
+	kind      string
+	namespace string
+	name      string
+
+	expectedPrefix string
+}
+
+func TestDeployResourceName(t *testing.T) {
+	app := &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "application", Namespace: "default"}}
+
+	tc := map[string]deployResourceNameTestCase{
+		"namespaced": {
+			group:          "apps",
+			kind:           "Deployment",
+			namespace:      "test",
+			name:           "guestbook-ui",
+			expectedPrefix: "application-deployment-guestbook-ui-",
+		},
+		"clusterScoped": {
+			group:          "rbac.authorization.k8s.io",
+			kind:           "ClusterRole",
+			name:           "system:guestbook",
+			expectedPrefix: "application-clusterrole-system-guestbook-",
+		},
+		"longName": {
+			kind:           "ConfigMap",
+			namespace:      "test",
+			name:           strings.Repeat("a", 253),
+			expectedPrefix: "application-configmap-" + strings.Repeat("a", maxDeployResourceNamePrefix-len("application-configmap-")) + "-",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			drName := newDeployResourceName(app, c.group, c.kind, c.namespace, c.name)
+			require.True(t, strings.HasPrefix(drName, c.expectedPrefix))
+			require.Len(t, drName, len(c.expectedPrefix)+10)
+			require.Empty(t, validation.IsDNS1123Subdomain(drName))
+			require.Equal(t, drName, newDeployResourceName(app, c.group, c.kind, c.namespace, c.name))
+		})
+	}
+
+	// Objects differing only in their groups must not share a DeployResource
+	require.NotEqual(t, newDeployResourceName(app, "apps", "Deployment", "test", "obj"), newDeployResourceName(app, "extensions", "Deployment", "test", "obj"))
+}
+
+func TestMigrateDeployResources(t *testing.T) {
+	app := &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "application", Namespace: "default"}}
+
+	spec := cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "old-obj"}
+	old := &cdv1.DeployResource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "application-service-old-obj",
+			Namespace: "default",
+			Labels:    map[string]string{cdv1.DeployResourceLabelApplication: "application-default"},
+		},
+		Spec:   spec,
+		Status: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeSynced},
+	}
+	current := newDeployResource(app, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "current-obj"})
+
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+	mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(app, old, current).Build()
+
+	list, err := getDeployResourceList(context.Background(), mockClient, app)
+	require.NoError(t, err)
+
+	migrated, err := migrateDeployResources(context.Background(), mockClient, app, list)
+	require.NoError(t, err)
+	require.Len(t, migrated.Items, 2)
+
+	renamed := &cdv1.DeployResource{}
+	require.NoError(t, mockClient.Get(context.Background(), types.NamespacedName{Name: specDeployResourceName(app, spec), Namespace: "default"}, renamed))
+	require.Equal(t, spec, renamed.Spec)
+	require.Equal(t, cdv1.SyncStatusCodeSynced, renamed.Status.SyncStatus)
+	require.Equal(t, "Service", renamed.Labels[cdv1.DeployResourceLabelKind])
+	require.Len(t, renamed.OwnerReferences, 1)
+	require.Equal(t, []string{cdv1.DeployResourceFinalizer}, renamed.Finalizers)
+
+	err = mockClient.Get(context.Background(), types.NamespacedName{Name: old.Name, Namespace: old.Namespace}, &cdv1.DeployResource{})
+	require.True(t, errors.IsNotFound(err))
+
+	list, err = getDeployResourceList(context.Background(), mockClient, app)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+}
+
 type deleteDeployResourceTestCase struct {
 	deployResource *cdv1.DeployResource
 
@@ -333,7 +399,7 @@ func TestDeleteDeployResource(t *testing.T) {
 			}
 			mockClient := fake.NewClientBuilder().WithLists(testDeployList).WithObjects(testDeployResource1, testDeployResource2, testDeployedObject).WithScheme(s).Build()
 
-			err := deleteDeployResource(mockClient, c.deployResource)
+			err := deleteDeployResource(context.Background(), mockClient, mockClient, c.deployResource)
 
 			if !c.expectedErrOccur {
 				require.NoError(t, err)
@@ -347,3 +413,312 @@ func TestDeleteDeployResource(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteDeployResourceOnTarget(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "application", Namespace: "default"}}
+	deployResource := newDeployResource(app, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "svc"})
+	newService := func() *corev1.Service {
+		return &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: "test"}}
+	}
+
+	// The same-named service in the operator's cluster must not be deleted
+	defaultCli := fake.NewClientBuilder().WithScheme(s).WithObjects(deployResource, newService()).Build()
+	targetCli := fake.NewClientBuilder().WithScheme(s).WithObjects(newService()).Build()
+
+	require.NoError(t, deleteDeployResource(context.Background(), defaultCli, targetCli, deployResource))
+
+	err := defaultCli.Get(context.Background(), types.NamespacedName{Namespace: deployResource.Namespace, Name: deployResource.Name}, &cdv1.DeployResource{})
+	require.True(t, errors.IsNotFound(err))
+	err = targetCli.Get(context.Background(), types.NamespacedName{Namespace: "test", Name: "svc"}, &corev1.Service{})
+	require.True(t, errors.IsNotFound(err))
+	require.NoError(t, defaultCli.Get(context.Background(), types.NamespacedName{Namespace: "test", Name: "svc"}, &corev1.Service{}))
+}
+
+type checkPruneLimitTestCase struct {
+	syncPolicy   cdv1.SyncPolicy
+	prune        bool
+	pruneCount   int
+	trackedCount int
+
+	expectedErrOccur bool
+	expectedErrMsg   string
+}
+
+func TestCheckPruneLimit(t *testing.T) {
+	tc := map[string]checkPruneLimitTestCase{
+		"noLimit": {
+			syncPolicy:       cdv1.SyncPolicy{Prune: true},
+			prune:            true,
+			pruneCount:       10,
+			trackedCount:     10,
+			expectedErrOccur: false,
+		},
+		"withinCount": {
+			syncPolicy:       cdv1.SyncPolicy{Prune: true, MaxPruneCount: 2},
+			prune:            true,
+			pruneCount:       2,
+			trackedCount:     10,
+			expectedErrOccur: false,
+		},
+		"exceedCount": {
+			syncPolicy:       cdv1.SyncPolicy{Prune: true, MaxPruneCount: 2},
+			prune:            true,
+			pruneCount:       3,
+			trackedCount:     10,
+			expectedErrOccur: true,
+			expectedErrMsg:   "sync would prune 3 resources, which exceeds maxPruneCount 2",
+		},
+		"exceedPercentage": {
+			syncPolicy:       cdv1.SyncPolicy{Prune: true, MaxPrunePercentage: 50},
+			prune:            true,
+			pruneCount:       6,
+			trackedCount:     10,
+			expectedErrOccur: true,
+			expectedErrMsg:   "sync would prune 6 of 10 resources, which exceeds maxPrunePercentage 50%",
+		},
+		"pruneDisabled": {
+			syncPolicy:       cdv1.SyncPolicy{MaxPruneCount: 2},
+			prune:            false,
+			pruneCount:       3,
+			trackedCount:     10,
+			expectedErrOccur: false,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{Spec: cdv1.ApplicationSpec{SyncPolicy: c.syncPolicy}}
+			err := checkPruneLimit(app, c.prune, c.pruneCount, c.trackedCount)
+			cond := meta.FindStatusCondition(app.Status.Conditions, cdv1.ApplicationConditionPruneLimitExceeded)
+			if c.expectedErrOccur {
+				require.Equal(t, c.expectedErrMsg, err.Error())
+				require.NotNil(t, cond)
+				require.Equal(t, c.expectedErrMsg, cond.Message)
+			} else {
+				require.NoError(t, err)
+				require.Nil(t, cond)
+			}
+		})
+	}
+}
+
+type pruneDeployResourcesTestCase struct {
+	prune bool
+
+	expectedKept    []string
+	expectedDeleted []string
+}
+
+func TestPruneDeployResources(t *testing.T) {
+	tc := map[string]pruneDeployResourcesTestCase{
+		"prune": {
+			prune:           true,
+			expectedKept:    []string{"protected"},
+			expectedDeleted: []string{"unprotected"},
+		},
+		"noPrune": {
+			prune:        false,
+			expectedKept: []string{"protected", "unprotected"},
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			protected := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "protected",
+					Namespace:   "test",
+					Annotations: map[string]string{cdv1.AnnotationSyncOptions: "Replace=true, Prune=false"},
+				},
+			}
+			unprotected := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "unprotected",
+					Namespace: "test",
+				},
+			}
+			mockClient := fake.NewClientBuilder().WithObjects(protected, unprotected).WithScheme(s).Build()
+
+			var targets []cdv1.DeployResource
+			for _, name := range []string{"protected", "unprotected"} {
+				targets = append(targets, cdv1.DeployResource{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: name, Namespace: "test"}})
+			}
+
+			app := &cdv1.Application{}
+			var deleted []string
+			kept, err := pruneDeployResources(context.Background(), mockClient, app, targets, c.prune, func(_ context.Context, dr *cdv1.DeployResource) error {
+				deleted = append(deleted, dr.Spec.Name)
+				return nil
+			})
+			require.NoError(t, err)
+
+			var keptNames []string
+			for _, k := range kept {
+				keptNames = append(keptNames, k.Spec.Name)
+			}
+			require.Equal(t, c.expectedKept, keptNames)
+			require.Equal(t, c.expectedDeleted, deleted)
+			require.Equal(t, cdv1.SyncStatusCodeOutOfSync, app.Status.Sync.Status)
+		})
+	}
+}
+
+func TestSetApplicationResources(t *testing.T) {
+	app := &cdv1.Application{}
+	deployment := &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": "deploy", "namespace": "test"}}}
+	results := []*resourceResult{{
+		obj:    deployment,
+		status: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeSynced, HealthStatus: cdv1.HealthStatusProgressing, HealthMessage: "rolling out"},
+	}}
+	requirePruning := []cdv1.DeployResource{{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: "svc", Namespace: "test"}}}
+
+	setApplicationResources(app, results, requirePruning)
+	require.Equal(t, []cdv1.ResourceStatus{
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test", Name: "deploy", Status: cdv1.SyncStatusCodeSynced, Health: &cdv1.HealthStatus{Status: cdv1.HealthStatusProgressing, Message: "rolling out"}},
+		{Version: "v1", Kind: "Service", Namespace: "test", Name: "svc", Status: cdv1.SyncStatusCodeOutOfSync, RequiresPruning: true},
+	}, app.Status.Resources)
+}
+
+func TestSetComparedTo(t *testing.T) {
+	app := &cdv1.Application{Spec: cdv1.ApplicationSpec{
+		Source:      cdv1.ApplicationSource{RepoURL: "https://github.com/tmax-cloud/cd-example-apps", TargetRevision: "main", Token: &cdv1.GitToken{Value: "secret"}},
+		Destination: cdv1.ApplicationDestination{Namespace: "test"},
+	}}
+
+	setComparedTo(app, "0123abcd")
+	require.Equal(t, "0123abcd", app.Status.Sync.Revision)
+	require.Nil(t, app.Status.Sync.ComparedTo.Source.Token)
+	require.Equal(t, "main", app.Status.Sync.ComparedTo.Source.TargetRevision)
+	require.Equal(t, "test", app.Status.Sync.ComparedTo.Destination.Namespace)
+	require.NotNil(t, app.Spec.Source.Token)
+}
+
+func TestSetSyncResult(t *testing.T) {
+	newDeployment := func(name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "metadata": map[string]interface{}{"name": name, "namespace": "test"}}}
+	}
+	applied := &resourceResult{obj: newDeployment("applied")}
+	applied.setApplied("0123abcd")
+	failed := &resourceResult{obj: newDeployment("failed")}
+	failed.setApplyError(fmt.Errorf("invalid spec"))
+	unchanged := &resourceResult{obj: newDeployment("unchanged")}
+
+	pruneTargets := []cdv1.DeployResource{
+		{ObjectMeta: metav1.ObjectMeta{Name: "pruned"}, Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: "pruned", Namespace: "test"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "kept"}, Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: "kept", Namespace: "test"}},
+	}
+
+	result := &cdv1.SyncOperationResult{}
+	setSyncResult(SyncOptions{Result: result}, "0123abcd", []*resourceResult{applied, failed, unchanged}, pruneTargets, pruneTargets[1:])
+	require.Equal(t, &cdv1.SyncOperationResult{
+		Revision: "0123abcd",
+		Resources: []cdv1.ResourceResult{
+			{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test", Name: "applied", Status: cdv1.ResultCodeSynced, Message: "applied"},
+			{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test", Name: "failed", Status: cdv1.ResultCodeSyncFailed, Message: "invalid spec"},
+			{Version: "v1", Kind: "Service", Namespace: "test", Name: "pruned", Status: cdv1.ResultCodePruned, Message: "pruned"},
+			{Version: "v1", Kind: "Service", Namespace: "test", Name: "kept", Status: cdv1.ResultCodePruneSkipped, Message: "requires pruning"},
+		},
+	}, result)
+
+	// Nothing is recorded if the result is not requested
+	setSyncResult(SyncOptions{}, "0123abcd", []*resourceResult{applied}, nil, nil)
+}
+
+func TestSyncOptionsSelection(t *testing.T) {
+	opts := SyncOptions{
+		Resources: []cdv1.SyncOperationResource{
+			{Group: "apps", Kind: "Deployment", Name: "deploy"},
+			{Kind: "Service", Namespace: "test", Name: "svc"},
+		},
+		LabelSelectors: []metav1.LabelSelector{
+			{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"db", "cache"}}}},
+		},
+	}
+
+	require.True(t, opts.IsSelected("apps", "Deployment", "any", "deploy", nil))
+	require.True(t, opts.IsSelected("", "Service", "test", "svc", nil))
+	require.False(t, opts.IsSelected("", "Service", "other", "svc", nil))
+	require.False(t, opts.IsSelected("extensions", "Deployment", "test", "deploy", nil))
+	require.True(t, opts.IsSelected("", "ConfigMap", "test", "cm", map[string]string{"tier": "cache"}))
+	require.False(t, opts.IsSelected("", "ConfigMap", "test", "cm", map[string]string{"tier": "web"}))
+	require.True(t, SyncOptions{}.IsSelected("", "ConfigMap", "test", "cm", nil))
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "db-config", Namespace: "test", Labels: map[string]string{"tier": "db"}}}
+	mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(cm).Build()
+
+	targets := []cdv1.DeployResource{
+		{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: "svc"}},
+		{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "db-config"}},
+		{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "cm"}},
+	}
+	selected, others, err := opts.selectPruneTargets(context.Background(), mockClient, targets)
+	require.NoError(t, err)
+	require.Equal(t, targets[:2], selected)
+	require.Equal(t, targets[2:], others)
+}
+
+func TestIsPruneEnabled(t *testing.T) {
+	enabled, disabled := true, false
+	app := &cdv1.Application{Spec: cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{Prune: true}}}
+
+	require.False(t, isPruneEnabled(app, SyncOptions{}, "new"))
+	require.True(t, isPruneEnabled(app, SyncOptions{Forced: true}, "new"))
+	require.False(t, isPruneEnabled(app, SyncOptions{Forced: true, Prune: &disabled}, "new"))
+
+	// Automated syncs prune only if they apply the revision
+	onCommit := SyncOptions{AutoSync: AutoSyncOptions{OnCommit: true, SyncedRevision: "old"}}
+	require.True(t, isPruneEnabled(app, onCommit, "new"))
+	require.False(t, isPruneEnabled(app, onCommit, "old"))
+
+	app.Spec.SyncPolicy.Prune = false
+	require.True(t, isPruneEnabled(app, SyncOptions{Forced: true, Prune: &enabled}, "new"))
+}
+
+func TestIsApplyEnabled(t *testing.T) {
+	require.True(t, SyncOptions{Forced: true}.isApplyEnabled("old"))
+	require.False(t, SyncOptions{}.isApplyEnabled("new"))
+
+	onCommit := SyncOptions{AutoSync: AutoSyncOptions{OnCommit: true, SyncedRevision: "old"}}
+	require.True(t, onCommit.isApplyEnabled("new"))
+	require.False(t, onCommit.isApplyEnabled("old"))
+
+	selfHeal := SyncOptions{AutoSync: AutoSyncOptions{SelfHeal: true, SyncedRevision: "old"}}
+	require.False(t, selfHeal.isApplyEnabled("new"))
+	require.True(t, selfHeal.isApplyEnabled("old"))
+}
+
+func TestSetApplicationHealth(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	failed := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "a"}, Status: corev1.PodStatus{Phase: corev1.PodFailed}}
+	succeeded := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "b"}, Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+	mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(failed, succeeded).Build()
+
+	// The pods of the same name in different namespaces must not overwrite each other's health
+	var results []*resourceResult
+	for _, namespace := range []string{"a", "b"} {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("Pod")
+		obj.SetNamespace(namespace)
+		obj.SetName("pod")
+		results = append(results, newResourceResult(obj, &cdv1.DeployResource{}))
+	}
+
+	app := &cdv1.Application{}
+	require.NoError(t, setApplicationHealth(context.Background(), mockClient, app, results))
+	require.Equal(t, cdv1.HealthStatus{Status: cdv1.HealthStatusDegraded, Message: "Pod a/pod: Pod failed"}, app.Status.Health)
+	require.Equal(t, cdv1.HealthStatusDegraded, results[0].status.HealthStatus)
+	require.Equal(t, cdv1.HealthStatusHealthy, results[1].status.HealthStatus)
+}
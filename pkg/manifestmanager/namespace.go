@@ -0,0 +1,231 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// setNamespaces sets the namespaces of the manifest objects, by the scopes of their kinds in the destination cluster.
+// Namespace-scoped objects without a namespace are placed in the namespace (or in any case, if force is set), and
+// cluster-scoped objects are never placed in a namespace.
+// Kinds which are not known to the cluster are looked up in the CustomResourceDefinitions of the manifests, and
+// regarded as namespace-scoped otherwise
+func setNamespaces(mapper meta.RESTMapper, objs []*unstructured.Unstructured, namespace string, force bool) error {
+	manifestScopes := getManifestCRDScopes(objs)
+
+	for _, obj := range objs {
+		namespaced, err := isNamespaced(mapper, manifestScopes, obj.GroupVersionKind())
+		if err != nil {
+			log.Error(err, "isNamespaced failed..")
+			return err
+		}
+
+		switch {
+		case !namespaced:
+			obj.SetNamespace("")
+		case obj.GetNamespace() == "" || (force && namespace != ""):
+			obj.SetNamespace(namespace)
+		}
+	}
+	return nil
+}
+
+// isNamespaced checks if the kind is namespace-scoped
+func isNamespaced(mapper meta.RESTMapper, manifestScopes map[schema.GroupKind]bool, gvk schema.GroupVersionKind) (bool, error) {
+	if mapper != nil {
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err == nil {
+			return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+		}
+		if !meta.IsNoMatchError(err) {
+			return false, err
+		}
+	}
+
+	if namespaced, exist := manifestScopes[gvk.GroupKind()]; exist {
+		return namespaced, nil
+	}
+	return true, nil
+}
+
+// crdClusterScope is the scope of the CustomResourceDefinitions, whose kinds are cluster-scoped
+const crdClusterScope = "Cluster"
+
+// getManifestCRDScopes returns the scopes of the kinds, which are defined by the CustomResourceDefinitions of the
+// manifests. The value is true, if the kind is namespace-scoped
+func getManifestCRDScopes(objs []*unstructured.Unstructured) map[schema.GroupKind]bool {
+	scopes := map[schema.GroupKind]bool{}
+	for _, obj := range objs {
+		if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}) {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		scopes[schema.GroupKind{Group: group, Kind: kind}] = scope != crdClusterScope
+	}
+	return scopes
+}
+
+// splitMisscopedDeployResources splits the DeployResources which track the cluster-scoped objects of the manifests
+// with a namespace from the others. They were recorded before the scopes were looked up, and the objects are tracked
+// by the DeployResources without the namespace now, so only the DeployResources themselves are to be deleted
+func splitMisscopedDeployResources(deployResources *cdv1.DeployResourceList, objs []*unstructured.Unstructured) (*cdv1.DeployResourceList, []cdv1.DeployResource) {
+	clusterScoped := map[string]bool{}
+	for _, obj := range objs {
+		if obj.GetNamespace() == "" {
+			clusterScoped[obj.GroupVersionKind().Group+"/"+obj.GetKind()+"/"+obj.GetName()] = true
+		}
+	}
+
+	kept := &cdv1.DeployResourceList{}
+	var misscoped []cdv1.DeployResource
+	for _, dr := range deployResources.Items {
+		gv, _ := schema.ParseGroupVersion(dr.Spec.APIVersion)
+		if dr.Spec.Namespace != "" && clusterScoped[gv.Group+"/"+dr.Spec.Kind+"/"+dr.Spec.Name] {
+			misscoped = append(misscoped, dr)
+			continue
+		}
+		kept.Items = append(kept.Items, dr)
+	}
+	return kept, misscoped
+}
+
+// dropMisscopedDeployResources deletes the DeployResources, which track the cluster-scoped objects with a namespace.
+// It returns the other DeployResources
+func dropMisscopedDeployResources(ctx context.Context, cli client.Client, deployResources *cdv1.DeployResourceList, objs []*unstructured.Unstructured) (*cdv1.DeployResourceList, error) {
+	kept, misscoped := splitMisscopedDeployResources(deployResources, objs)
+	for i := range misscoped {
+		log.Info(fmt.Sprintf("Deleting DeployResource %s of cluster-scoped %s %s, which is recorded with namespace %s", misscoped[i].Name, misscoped[i].Spec.Kind, misscoped[i].Spec.Name, misscoped[i].Spec.Namespace))
+		if err := removeDeployResource(ctx, cli, &misscoped[i]); err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
+
+// ensureDestinationNamespace creates the destination namespace of the application in the target cluster, if
+// CreateNamespace=true is set. The namespace created by the application is annotated as owned by it, and the managed
+// namespace metadata is applied only to the owned namespace. The owned namespace is recorded in status.ownedNamespace
+func ensureDestinationNamespace(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	name := app.Spec.Destination.Namespace
+	if !app.HasSyncOption(cdv1.SyncOptionCreateNamespace) || name == "" {
+		return nil
+	}
+
+	ns := newNamespaceObject(name)
+	if err := cli.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+
+		ns = newNamespaceObject(name)
+		ns.SetAnnotations(map[string]string{cdv1.AnnotationNamespaceOwner: app.NamespaceOwnerKey()})
+		setManagedNamespaceMetadata(ns, app.Spec.SyncPolicy.ManagedNamespaceMetadata)
+		log.Info(fmt.Sprintf("Creating namespace %s of application %s", name, app.NamespaceOwnerKey()))
+		if err := cli.Create(ctx, ns); err != nil {
+			return err
+		}
+		app.Status.OwnedNamespace = name
+		return nil
+	}
+
+	// The namespace which already existed is left as it is
+	if ns.GetAnnotations()[cdv1.AnnotationNamespaceOwner] != app.NamespaceOwnerKey() {
+		if app.Status.OwnedNamespace == name {
+			app.Status.OwnedNamespace = ""
+		}
+		return nil
+	}
+
+	app.Status.OwnedNamespace = name
+	if !setManagedNamespaceMetadata(ns, app.Spec.SyncPolicy.ManagedNamespaceMetadata) {
+		return nil
+	}
+	return cli.Update(ctx, ns)
+}
+
+// deleteOwnedNamespace deletes the namespace created by the application, once its resources are deleted. The namespace
+// is kept with the orphan policy, or if it's not owned by the application anymore. It returns true if the namespace
+// deleted in the foreground may still exist, so that the application waits for it
+func deleteOwnedNamespace(ctx context.Context, cli client.Client, app *cdv1.Application) (bool, error) {
+	name := app.Status.OwnedNamespace
+	policy := app.GetDeletionPolicy()
+	if name == "" || policy == cdv1.DeletionPolicyOrphan {
+		return false, nil
+	}
+
+	ns := newNamespaceObject(name)
+	if err := cli.Get(ctx, types.NamespacedName{Name: name}, ns); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if ns.GetAnnotations()[cdv1.AnnotationNamespaceOwner] != app.NamespaceOwnerKey() {
+		return false, nil
+	}
+
+	if ns.GetDeletionTimestamp() == nil {
+		propagation := metav1.DeletePropagationBackground
+		if policy == cdv1.DeletionPolicyForeground {
+			propagation = metav1.DeletePropagationForeground
+		}
+		log.Info(fmt.Sprintf("Deleting namespace %s of application %s", name, app.NamespaceOwnerKey()))
+		if err := cli.Delete(ctx, ns, client.PropagationPolicy(propagation)); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return policy == cdv1.DeletionPolicyForeground, nil
+}
+
+func newNamespaceObject(name string) *unstructured.Unstructured {
+	ns := &unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(name)
+	return ns
+}
+
+// setManagedNamespaceMetadata merges the labels and the annotations of the metadata into the namespace.
+// It returns true if the namespace is changed
+func setManagedNamespaceMetadata(ns *unstructured.Unstructured, metadata *cdv1.ManagedNamespaceMetadata) bool {
+	if metadata == nil {
+		return false
+	}
+
+	labels, labelsChanged := mergeStringMap(ns.GetLabels(), metadata.Labels)
+	annotations, annotationsChanged := mergeStringMap(ns.GetAnnotations(), metadata.Annotations)
+	if labelsChanged {
+		ns.SetLabels(labels)
+	}
+	if annotationsChanged {
+		ns.SetAnnotations(annotations)
+	}
+	return labelsChanged || annotationsChanged
+}
+
+// mergeStringMap sets the entries of src to dst. It returns the merged map, and whether any entry is changed
+func mergeStringMap(dst, src map[string]string) (map[string]string, bool) {
+	changed := false
+	for k, v := range src {
+		if cur, exist := dst[k]; exist && cur == v {
+			continue
+		}
+		if dst == nil {
+			dst = map[string]string{}
+		}
+		dst[k] = v
+		changed = true
+	}
+	return dst, changed
+}
@@ -0,0 +1,270 @@
+package manifestmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newNamespaceTestObj(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+type setNamespacesTestCase struct {
+	obj   *unstructured.Unstructured
+	force bool
+
+	expectedNamespace string
+}
+
+func TestSetNamespaces(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Service"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+
+	crd := newNamespaceTestObj("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "clusterthings.test.io")
+	crd.Object["spec"] = map[string]interface{}{
+		"group": "test.io",
+		"names": map[string]interface{}{"kind": "ClusterThing"},
+		"scope": "Cluster",
+	}
+
+	tc := map[string]setNamespacesTestCase{
+		"namespacedWithoutNamespace": {
+			obj:               newNamespaceTestObj("v1", "Service", "", "svc"),
+			expectedNamespace: "dest",
+		},
+		"namespacedWithNamespace": {
+			obj:               newNamespaceTestObj("v1", "Service", "other", "svc"),
+			expectedNamespace: "other",
+		},
+		"forced": {
+			obj:               newNamespaceTestObj("v1", "Service", "other", "svc"),
+			force:             true,
+			expectedNamespace: "dest",
+		},
+		"clusterScoped": {
+			obj:               newNamespaceTestObj("rbac.authorization.k8s.io/v1", "ClusterRole", "", "role"),
+			expectedNamespace: "",
+		},
+		"misscoped": {
+			obj:               newNamespaceTestObj("v1", "Namespace", "dest", "ns"),
+			force:             true,
+			expectedNamespace: "",
+		},
+		"crdInManifests": {
+			obj:               newNamespaceTestObj("test.io/v1", "ClusterThing", "other", "thing"),
+			expectedNamespace: "",
+		},
+		"unknownKind": {
+			obj:               newNamespaceTestObj("test.io/v1", "Thing", "", "thing"),
+			expectedNamespace: "dest",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, setNamespaces(mapper, []*unstructured.Unstructured{crd.DeepCopy(), c.obj}, "dest", c.force))
+			require.Equal(t, c.expectedNamespace, c.obj.GetNamespace())
+		})
+	}
+}
+
+func TestSplitMisscopedDeployResources(t *testing.T) {
+	objs := []*unstructured.Unstructured{
+		newNamespaceTestObj("rbac.authorization.k8s.io/v1", "ClusterRole", "", "role"),
+		newNamespaceTestObj("v1", "Service", "dest", "svc"),
+	}
+	newDeployResource := func(name, apiVersion, kind, namespace string) cdv1.DeployResource {
+		dr := cdv1.DeployResource{Spec: cdv1.DeployResourceSpec{APIVersion: apiVersion, Kind: kind, Namespace: namespace, Name: name}}
+		dr.Name = name
+		return dr
+	}
+	list := &cdv1.DeployResourceList{Items: []cdv1.DeployResource{
+		newDeployResource("role", "rbac.authorization.k8s.io/v1", "ClusterRole", "dest"),
+		newDeployResource("svc", "v1", "Service", "dest"),
+		newDeployResource("removed", "v1", "ConfigMap", "dest"),
+	}}
+
+	kept, misscoped := splitMisscopedDeployResources(list, objs)
+	require.Len(t, misscoped, 1)
+	require.Equal(t, "role", misscoped[0].Name)
+	require.Len(t, kept.Items, 2)
+	require.Equal(t, "svc", kept.Items[0].Name)
+	require.Equal(t, "removed", kept.Items[1].Name)
+}
+
+type ensureDestinationNamespaceTestCase struct {
+	syncOptions []string
+	existingNs  *corev1.Namespace
+
+	expectedNs             *corev1.Namespace
+	expectedOwnedNamespace string
+}
+
+func TestEnsureDestinationNamespace(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	metadata := &cdv1.ManagedNamespaceMetadata{
+		Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+		Annotations: map[string]string{"team": "cd"},
+	}
+
+	tc := map[string]ensureDestinationNamespaceTestCase{
+		"disabled": {},
+		"created": {
+			syncOptions: []string{cdv1.SyncOptionCreateNamespace},
+			expectedNs: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "dest",
+				Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+				Annotations: map[string]string{"team": "cd", cdv1.AnnotationNamespaceOwner: "default/test-app"},
+			}},
+			expectedOwnedNamespace: "dest",
+		},
+		"notOwned": {
+			syncOptions: []string{cdv1.SyncOptionCreateNamespace},
+			existingNs:  &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dest"}},
+			expectedNs:  &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dest"}},
+		},
+		"owned": {
+			syncOptions: []string{cdv1.SyncOptionCreateNamespace},
+			existingNs: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "dest",
+				Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "baseline", "env": "dev"},
+				Annotations: map[string]string{cdv1.AnnotationNamespaceOwner: "default/test-app"},
+			}},
+			expectedNs: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "dest",
+				Labels:      map[string]string{"pod-security.kubernetes.io/enforce": "restricted", "env": "dev"},
+				Annotations: map[string]string{"team": "cd", cdv1.AnnotationNamespaceOwner: "default/test-app"},
+			}},
+			expectedOwnedNamespace: "dest",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(s)
+			if c.existingNs != nil {
+				builder = builder.WithObjects(c.existingNs)
+			}
+			cli := builder.Build()
+
+			app := &cdv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default"},
+				Spec: cdv1.ApplicationSpec{
+					Destination: cdv1.ApplicationDestination{Namespace: "dest"},
+					SyncPolicy:  cdv1.SyncPolicy{SyncOptions: c.syncOptions, ManagedNamespaceMetadata: metadata},
+				},
+			}
+			require.NoError(t, ensureDestinationNamespace(context.Background(), cli, app))
+			require.Equal(t, c.expectedOwnedNamespace, app.Status.OwnedNamespace)
+
+			ns := &corev1.Namespace{}
+			err := cli.Get(context.Background(), types.NamespacedName{Name: "dest"}, ns)
+			if c.expectedNs == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expectedNs.Labels, ns.Labels)
+			require.Equal(t, c.expectedNs.Annotations, ns.Annotations)
+		})
+	}
+}
+
+type deleteOwnedNamespaceTestCase struct {
+	policy         cdv1.DeletionPolicy
+	ownedNamespace string
+	owner          string
+
+	expectedDeleting bool
+	expectedDeleted  bool
+}
+
+func TestDeleteOwnedNamespace(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	tc := map[string]deleteOwnedNamespaceTestCase{
+		"foreground": {
+			policy:           cdv1.DeletionPolicyForeground,
+			ownedNamespace:   "dest",
+			owner:            "default/test-app",
+			expectedDeleting: true,
+			expectedDeleted:  true,
+		},
+		"background": {
+			policy:          cdv1.DeletionPolicyBackground,
+			ownedNamespace:  "dest",
+			owner:           "default/test-app",
+			expectedDeleted: true,
+		},
+		"orphan": {
+			policy:         cdv1.DeletionPolicyOrphan,
+			ownedNamespace: "dest",
+			owner:          "default/test-app",
+		},
+		"notOwned": {
+			policy: cdv1.DeletionPolicyForeground,
+			owner:  "default/test-app",
+		},
+		"ownedByOther": {
+			policy:         cdv1.DeletionPolicyForeground,
+			ownedNamespace: "dest",
+			owner:          "default/other-app",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "dest",
+				Annotations: map[string]string{cdv1.AnnotationNamespaceOwner: c.owner},
+			}}
+			cli := fake.NewClientBuilder().WithScheme(s).WithObjects(ns).Build()
+
+			app := &cdv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default"},
+				Spec: cdv1.ApplicationSpec{
+					Destination:    cdv1.ApplicationDestination{Namespace: "dest"},
+					DeletionPolicy: c.policy,
+				},
+				Status: cdv1.ApplicationStatus{OwnedNamespace: c.ownedNamespace},
+			}
+			deleting, err := deleteOwnedNamespace(context.Background(), cli, app)
+			require.NoError(t, err)
+			require.Equal(t, c.expectedDeleting, deleting)
+
+			err = cli.Get(context.Background(), types.NamespacedName{Name: "dest"}, &corev1.Namespace{})
+			if c.expectedDeleted {
+				require.True(t, errors.IsNotFound(err))
+				// The namespace deleted in the foreground is waited for, until it's gone
+				deleting, err := deleteOwnedNamespace(context.Background(), cli, app)
+				require.NoError(t, err)
+				require.False(t, deleting)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// orphanedResourceKinds are the kinds of the resources, which are monitored by the orphaned resources monitor
+var orphanedResourceKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Version: "v1", Kind: "PersistentVolumeClaim"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "batch", Version: "v1", Kind: "Job"},
+	{Group: "batch", Version: "v1", Kind: "CronJob"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+}
+
+// defaultIgnoredOrphanedResources are created in every namespace by kubernetes, so they're never orphaned
+var defaultIgnoredOrphanedResources = []cdv1.OrphanedResourceKey{
+	{Kind: "ServiceAccount", Name: "default"},
+	{Kind: "ConfigMap", Name: "kube-root-ca.crt"},
+}
+
+// ignoredOrphanedSecretTypes are the types of the secrets, which are managed by kubernetes or helm
+var ignoredOrphanedSecretTypes = map[string]bool{
+	"kubernetes.io/service-account-token": true,
+	"helm.sh/release.v1":                  true,
+}
+
+// maxOrphanedResources is the maximum number of the orphaned resources in the application status
+const maxOrphanedResources = 100
+
+// maxOrphanedResourcesInMessage is the maximum number of the orphaned resources listed in the warning condition
+const maxOrphanedResourcesInMessage = 10
+
+// setOrphanedResources sets the orphaned resources in the destination namespaces of the application and the warning
+// condition. Both are removed, if the monitor is disabled
+func setOrphanedResources(ctx context.Context, cli, targetCli client.Client, app *cdv1.Application) error {
+	settings := app.Spec.OrphanedResources
+	if settings == nil {
+		app.Status.OrphanedResources = nil
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionOrphanedResourceWarning)
+		return nil
+	}
+
+	var orphans []cdv1.OrphanedResource
+	for _, namespace := range getDestinationNamespaces(app) {
+		found, err := getOrphanedResources(ctx, cli, targetCli, app, namespace)
+		if err != nil {
+			return err
+		}
+		orphans = append(orphans, found...)
+	}
+
+	setOrphanedResourceCondition(app, orphans)
+	if len(orphans) > maxOrphanedResources {
+		orphans = orphans[:maxOrphanedResources]
+	}
+	app.Status.OrphanedResources = orphans
+	return nil
+}
+
+// getDestinationNamespaces returns the destination namespace and the namespaces of the resources of the application
+func getDestinationNamespaces(app *cdv1.Application) []string {
+	namespaces := map[string]bool{}
+	if app.Spec.Destination.Namespace != "" {
+		namespaces[app.Spec.Destination.Namespace] = true
+	}
+	for _, resource := range app.Status.Resources {
+		if resource.Namespace != "" {
+			namespaces[resource.Namespace] = true
+		}
+	}
+
+	var sorted []string
+	for namespace := range namespaces {
+		sorted = append(sorted, namespace)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// getOrphanedResources lists the resources of the monitored kinds in the namespace, which are neither tracked by the
+// DeployResources nor marked by any application. The kinds which are not served or not allowed to be listed are skipped
+func getOrphanedResources(ctx context.Context, cli, targetCli client.Client, app *cdv1.Application, namespace string) ([]cdv1.OrphanedResource, error) {
+	tracked, err := getTrackedResources(ctx, cli, app, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []cdv1.OrphanedResource
+	for _, gvk := range orphanedResourceKinds {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := targetCli.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			if meta.IsNoMatchError(err) || errors.IsNotFound(err) || errors.IsForbidden(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if tracked[resourceKey(gvk.Group, gvk.Kind, namespace, obj.GetName())] || isOrphanedResourceIgnored(app, gvk, obj) {
+				continue
+			}
+			if owner, _ := getTrackingOwner(obj, app); owner != "" {
+				continue
+			}
+			orphans = append(orphans, cdv1.OrphanedResource{
+				Group:     gvk.Group,
+				Version:   gvk.Version,
+				Kind:      gvk.Kind,
+				Namespace: namespace,
+				Name:      obj.GetName(),
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// getTrackedResources returns the keys of the resources in the namespace, which are tracked by the DeployResources of
+// any application deploying to the same cluster as the application. The DeployResources whose applications are gone
+// don't track anything
+func getTrackedResources(ctx context.Context, cli client.Client, app *cdv1.Application, namespace string) (map[string]bool, error) {
+	deployResources := &cdv1.DeployResourceList{}
+	if err := cli.List(ctx, deployResources, client.MatchingLabels{cdv1.DeployResourceLabelNamespace: shortenLabelValue(namespace)}); err != nil {
+		return nil, err
+	}
+
+	// Destination clusters of the owning applications, keyed by their namespaced names
+	clusters := map[types.NamespacedName]*string{}
+	tracked := map[string]bool{}
+	for _, dr := range deployResources.Items {
+		key := types.NamespacedName{Namespace: dr.Namespace, Name: dr.Application}
+		cluster, ok := clusters[key]
+		if !ok {
+			owner := &cdv1.Application{}
+			if err := cli.Get(ctx, key, owner); err != nil {
+				if !errors.IsNotFound(err) {
+					return nil, err
+				}
+			} else {
+				cluster = &owner.Spec.Destination.Name
+			}
+			clusters[key] = cluster
+		}
+		if cluster == nil || *cluster != app.Spec.Destination.Name {
+			continue
+		}
+
+		gv, _ := schema.ParseGroupVersion(dr.Spec.APIVersion)
+		tracked[resourceKey(gv.Group, dr.Spec.Kind, dr.Spec.Namespace, dr.Spec.Name)] = true
+	}
+	return tracked, nil
+}
+
+// isOrphanedResourceIgnored checks if the resource is ignored by the application or by default. The resources owned
+// by other resources are ignored, as they're managed by their owners
+func isOrphanedResourceIgnored(app *cdv1.Application, gvk schema.GroupVersionKind, obj *unstructured.Unstructured) bool {
+	if len(obj.GetOwnerReferences()) > 0 {
+		return true
+	}
+	if gvk.Kind == "Secret" && gvk.Group == "" {
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		if ignoredOrphanedSecretTypes[secretType] {
+			return true
+		}
+	}
+
+	for _, key := range defaultIgnoredOrphanedResources {
+		if key.Matches(gvk.Group, gvk.Kind, obj.GetName()) {
+			return true
+		}
+	}
+	for _, key := range app.Spec.OrphanedResources.Ignore {
+		if key.Matches(gvk.Group, gvk.Kind, obj.GetName()) {
+			return true
+		}
+	}
+	return false
+}
+
+// setOrphanedResourceCondition sets the orphaned resource warning of the application, if there are any orphaned
+// resources and the warning is enabled. Otherwise, it removes the warning
+func setOrphanedResourceCondition(app *cdv1.Application, orphans []cdv1.OrphanedResource) {
+	if len(orphans) == 0 || !app.Spec.OrphanedResources.IsWarn() {
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionOrphanedResourceWarning)
+		return
+	}
+
+	var names []string
+	for i, orphan := range orphans {
+		if i == maxOrphanedResourcesInMessage {
+			names = append(names, fmt.Sprintf("and %d more", len(orphans)-maxOrphanedResourcesInMessage))
+			break
+		}
+		names = append(names, orphan.Kind+" "+orphan.Namespace+"/"+orphan.Name)
+	}
+
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:    cdv1.ApplicationConditionOrphanedResourceWarning,
+		Status:  metav1.ConditionTrue,
+		Reason:  "OrphanedResourceWarning",
+		Message: fmt.Sprintf("%d orphaned resources found: %s", len(orphans), strings.Join(names, ", ")),
+	})
+}
+
+func resourceKey(group, kind, namespace, name string) string {
+	return strings.Join([]string{group, kind, namespace, name}, "/")
+}
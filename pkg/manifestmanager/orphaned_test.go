@@ -0,0 +1,139 @@
+package manifestmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type setOrphanedResourcesTestCase struct {
+	settings *cdv1.OrphanedResourcesMonitorSettings
+
+	expectedOrphans   []cdv1.OrphanedResource
+	expectedCondition bool
+}
+
+func TestSetOrphanedResources(t *testing.T) {
+	warnDisabled := false
+	tc := map[string]setOrphanedResourcesTestCase{
+		"disabled": {},
+		"enabled": {
+			settings: &cdv1.OrphanedResourcesMonitorSettings{},
+			expectedOrphans: []cdv1.OrphanedResource{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "manual-cm"},
+				{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "temp-cm"},
+				{Version: "v1", Kind: "Service", Namespace: "test", Name: "manual-svc"},
+				{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test", Name: "manual-deploy"},
+			},
+			expectedCondition: true,
+		},
+		"ignored": {
+			settings: &cdv1.OrphanedResourcesMonitorSettings{
+				Ignore: []cdv1.OrphanedResourceKey{{Kind: "ConfigMap", Name: "temp-*"}, {Group: "apps"}},
+			},
+			expectedOrphans: []cdv1.OrphanedResource{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "manual-cm"},
+				{Version: "v1", Kind: "Service", Namespace: "test", Name: "manual-svc"},
+			},
+			expectedCondition: true,
+		},
+		"warnDisabled": {
+			settings: &cdv1.OrphanedResourcesMonitorSettings{Warn: &warnDisabled},
+			expectedOrphans: []cdv1.OrphanedResource{
+				{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "manual-cm"},
+				{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "temp-cm"},
+				{Version: "v1", Kind: "Service", Namespace: "test", Name: "manual-svc"},
+				{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test", Name: "manual-deploy"},
+			},
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	otherApp := newTrackingTestApp("other-app")
+	marked := newNamespaceTestObj("v1", "ConfigMap", "test", "marked-cm")
+	setTrackingMetadata(marked, otherApp)
+	owned := newNamespaceTestObj("apps/v1", "ReplicaSet", "test", "owned-rs")
+	owned.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "manual-deploy", UID: "uid"}})
+	tokenSecret := newNamespaceTestObj("v1", "Secret", "test", "default-token")
+	tokenSecret.Object["type"] = "kubernetes.io/service-account-token"
+
+	objs := []client.Object{
+		newNamespaceTestObj("v1", "ConfigMap", "test", "tracked-cm"),
+		newNamespaceTestObj("v1", "ConfigMap", "test", "manual-cm"),
+		newNamespaceTestObj("v1", "ConfigMap", "test", "temp-cm"),
+		newNamespaceTestObj("v1", "ConfigMap", "test", "kube-root-ca.crt"),
+		newNamespaceTestObj("v1", "ConfigMap", "other", "other-cm"),
+		newNamespaceTestObj("v1", "ServiceAccount", "test", "default"),
+		newNamespaceTestObj("v1", "Service", "test", "manual-svc"),
+		newNamespaceTestObj("apps/v1", "Deployment", "test", "manual-deploy"),
+		marked,
+		owned,
+		tokenSecret,
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := newTrackingTestApp("test-app")
+			app.Spec.Destination.Namespace = "test"
+			app.Spec.OrphanedResources = c.settings
+			app.Status.OrphanedResources = []cdv1.OrphanedResource{{Version: "v1", Kind: "ConfigMap", Namespace: "test", Name: "stale"}}
+			meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{Type: cdv1.ApplicationConditionOrphanedResourceWarning, Status: metav1.ConditionTrue, Reason: "OrphanedResourceWarning"})
+
+			// A DeployResource of another application tracks the resource as well
+			tracked := newDeployResource(otherApp, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "tracked-cm"})
+			cli := fake.NewClientBuilder().WithScheme(s).WithObjects(otherApp.DeepCopy(), tracked).Build()
+			targetCli := fake.NewClientBuilder().WithScheme(s).WithObjects(objs...).Build()
+
+			require.NoError(t, setOrphanedResources(context.Background(), cli, targetCli, app))
+			require.Equal(t, c.expectedOrphans, app.Status.OrphanedResources)
+			require.Equal(t, c.expectedCondition, meta.IsStatusConditionTrue(app.Status.Conditions, cdv1.ApplicationConditionOrphanedResourceWarning))
+		})
+	}
+}
+
+func TestGetTrackedResources(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	localApp := newTrackingTestApp("local-app")
+	remoteApp := newTrackingTestApp("remote-app")
+	remoteApp.Spec.Destination.Name = "remote"
+	deletedApp := newTrackingTestApp("deleted-app")
+
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		localApp, remoteApp,
+		newDeployResource(localApp, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "local-cm"}),
+		newDeployResource(remoteApp, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "remote-cm"}),
+		newDeployResource(deletedApp, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "ConfigMap", Namespace: "test", Name: "deleted-cm"}),
+	).Build()
+
+	app := newTrackingTestApp("test-app")
+	tracked, err := getTrackedResources(context.Background(), cli, app, "test")
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{resourceKey("", "ConfigMap", "test", "local-cm"): true}, tracked)
+
+	app.Spec.Destination.Name = "remote"
+	tracked, err = getTrackedResources(context.Background(), cli, app, "test")
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{resourceKey("", "ConfigMap", "test", "remote-cm"): true}, tracked)
+}
+
+func TestOrphanedResourceKeyMatches(t *testing.T) {
+	require.True(t, cdv1.OrphanedResourceKey{}.Matches("apps", "Deployment", "test"))
+	require.True(t, cdv1.OrphanedResourceKey{Kind: "ConfigMap", Name: "temp-*"}.Matches("", "ConfigMap", "temp-1"))
+	require.False(t, cdv1.OrphanedResourceKey{Kind: "ConfigMap", Name: "temp-*"}.Matches("", "Secret", "temp-1"))
+	require.False(t, cdv1.OrphanedResourceKey{Group: "apps"}.Matches("", "ConfigMap", "test"))
+	require.False(t, cdv1.OrphanedResourceKey{Name: "[invalid"}.Matches("", "ConfigMap", "[invalid"))
+}
@@ -0,0 +1,87 @@
+package manifestmanager
+
+import (
+	"context"
+	"errors"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// defaultSyncWorkers is used if syncWorkers of the cd-config config map is not set
+const defaultSyncWorkers = 10
+
+// Kinds which are synced before the other resources, as the others may depend on them
+var prerequisiteKinds = map[string]bool{
+	"Namespace":                true,
+	"CustomResourceDefinition": true,
+}
+
+// getSyncWorkers returns the number of resources, which are compared and applied concurrently
+func getSyncWorkers() int {
+	if configs.SyncWorkers > 0 {
+		return configs.SyncWorkers
+	}
+	return defaultSyncWorkers
+}
+
+// getSyncWaves splits the objects into the waves, which are synced in order. The objects of a wave are synced
+// concurrently. Namespaces and CustomResourceDefinitions are synced in the first wave, and the others in the next one.
+// It returns the indices of the objects of each wave
+func getSyncWaves(objs []*unstructured.Unstructured) [][]int {
+	var prerequisites, others []int
+	for i, obj := range objs {
+		if prerequisiteKinds[obj.GetKind()] {
+			prerequisites = append(prerequisites, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+
+	var waves [][]int
+	for _, wave := range [][]int{prerequisites, others} {
+		if len(wave) > 0 {
+			waves = append(waves, wave)
+		}
+	}
+	return waves
+}
+
+// isTerminatedError checks if the error, which may be aggregated, is caused by the termination of the sync
+func isTerminatedError(err error) bool {
+	return errors.Is(err, ErrTerminated)
+}
+
+// compactResults removes the results of the resources, which are not compared as they failed before
+func compactResults(results []*resourceResult) []*resourceResult {
+	var compacted []*resourceResult
+	for _, result := range results {
+		if result != nil {
+			compacted = append(compacted, result)
+		}
+	}
+	return compacted
+}
+
+// setOutOfSync sets the application out of sync, if any of the resources is out of sync
+func setOutOfSync(app *cdv1.Application, results []*resourceResult) {
+	for _, result := range results {
+		if result.status.SyncStatus == cdv1.SyncStatusCodeOutOfSync {
+			app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
+			return
+		}
+	}
+}
+
+// parallelize calls f for the pieces with at most getSyncWorkers() workers, and aggregates all errors which are
+// returned. The pieces which are not started yet are skipped, when the context is done
+func parallelize(ctx context.Context, pieces int, f func(ctx context.Context, i int) error) error {
+	errs := make([]error, pieces)
+	workqueue.ParallelizeUntil(ctx, getSyncWorkers(), pieces, func(i int) {
+		errs[i] = f(ctx, i)
+	})
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,89 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type getSyncWavesTestCase struct {
+	kinds []string
+
+	expectedWaves [][]int
+}
+
+func TestGetSyncWaves(t *testing.T) {
+	tc := map[string]getSyncWavesTestCase{
+		"empty": {},
+		"oneWave": {
+			kinds:         []string{"Service", "Deployment"},
+			expectedWaves: [][]int{{0, 1}},
+		},
+		"prerequisites": {
+			kinds:         []string{"Deployment", "Namespace", "Service", "CustomResourceDefinition"},
+			expectedWaves: [][]int{{1, 3}, {0, 2}},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			var objs []*unstructured.Unstructured
+			for i, kind := range c.kinds {
+				obj := &unstructured.Unstructured{}
+				obj.SetKind(kind)
+				obj.SetName(fmt.Sprintf("obj-%d", i))
+				objs = append(objs, obj)
+			}
+			require.Equal(t, c.expectedWaves, getSyncWaves(objs))
+		})
+	}
+}
+
+func TestParallelize(t *testing.T) {
+	configs.SyncWorkers = 3
+	defer func() {
+		configs.SyncWorkers = 0
+	}()
+
+	var running, maxRunning int32
+	done := make([]bool, 20)
+	err := parallelize(context.Background(), len(done), func(ctx context.Context, i int) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+
+		done[i] = true
+		if i%5 == 0 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+
+	// All pieces are done, and all failures are reported
+	for i := range done {
+		require.True(t, done[i])
+	}
+	require.LessOrEqual(t, maxRunning, int32(3))
+	require.EqualError(t, err, "[failed 0, failed 5, failed 10, failed 15]")
+}
+
+func TestParallelizeTerminated(t *testing.T) {
+	err := parallelize(context.Background(), 3, func(ctx context.Context, i int) error {
+		if i == 1 {
+			return ErrTerminated
+		}
+		return fmt.Errorf("failed %d", i)
+	})
+	require.True(t, isTerminatedError(err))
+	require.False(t, isTerminatedError(fmt.Errorf("failed")))
+}
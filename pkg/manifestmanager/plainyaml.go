@@ -2,19 +2,16 @@ package manifestmanager
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"net/http"
 
-	jsonpatch "github.com/evanphx/json-patch"
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
-	"github.com/tmax-cloud/cd-operator/pkg/cluster"
+	"github.com/tmax-cloud/cd-operator/internal/utils"
 	"github.com/tmax-cloud/cd-operator/pkg/git"
 	"github.com/tmax-cloud/cd-operator/pkg/httpclient"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
-	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -22,204 +19,400 @@ type plainYamlManager struct {
 	DefaultCli client.Client
 	// Client for multi cluster
 	TargetCli client.Client
-	context.Context
 	httpclient.HTTPClient
 	GitCli git.Client
 }
 
-func NewPlainYamlManager(ctx context.Context, cli client.Client, httpCli httpclient.HTTPClient, gitCli git.Client) ManifestManager {
-	return &plainYamlManager{
+// NewPlainYamlManager creates a plain yaml manager with the git client and the destination cluster client of the
+// application. It's registered as the factory of the plain yaml applications
+func NewPlainYamlManager(ctx context.Context, cli client.Client, app *cdv1.Application) (ManifestManager, error) {
+	gitCli, err := utils.GetGitCli(app, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &plainYamlManager{
 		DefaultCli: cli,
-		TargetCli:  cli,
-		Context:    ctx,
-		HTTPClient: httpCli,
+		HTTPClient: http.DefaultClient,
 		GitCli:     gitCli,
 	}
+	if err := m.setTargetClient(ctx, app); err != nil {
+		log.Error(err, "setTargetClient failed..")
+		return nil, err
+	}
+	return m, nil
 }
 
-func (m *plainYamlManager) Sync(app *cdv1.Application, forced bool) error {
-	if err := m.setTargetClient(app); err != nil {
-		log.Error(err, "setTargetClient failed..")
-		return err
+func (m *plainYamlManager) Sync(ctx context.Context, app *cdv1.Application, opts SyncOptions) error {
+
+	forced := opts.Forced
+	revision := opts.Revision
+	if revision == "" {
+		revision = app.Spec.Source.TargetRevision
 	}
+	revision = m.resolveRevision(ctx, revision)
+	setComparedTo(app, revision)
 
-	var manifestInfos []string
-	manifestInfos, err := m.GitCli.GetManifestInfos(app.Spec.Source.Path, app.Spec.Source.TargetRevision, manifestInfos)
+	manifestRawobjs, err := m.objectsFromGit(ctx, app, revision)
 	if err != nil {
-		log.Error(err, "GetManifestURLList failed..")
 		return err
 	}
-	oldDeployResources, err := getDeployResourceList(m.DefaultCli, app)
+	oldDeployResources, err := getDeployResourceList(ctx, m.DefaultCli, app)
 	if err != nil {
 		log.Error(err, "GetDeployResourceList failed")
 		return err
 	}
+	oldDeployResources, err = migrateDeployResources(ctx, m.DefaultCli, app, oldDeployResources)
+	if err != nil {
+		log.Error(err, "migrateDeployResources failed..")
+		return err
+	}
+	oldDeployResources, err = dropMisscopedDeployResources(ctx, m.DefaultCli, oldDeployResources, manifestRawobjs)
+	if err != nil {
+		log.Error(err, "dropMisscopedDeployResources failed..")
+		return err
+	}
 
-	updatedDeployResources := make(map[string]*cdv1.DeployResource)
+	// Check the prune limit before applying anything, so that a refused sync does not leave a half-applied state
+	// Resources which are not selected to be synced are neither applied nor pruned
+	prune := isPruneEnabled(app, opts, revision)
+	pruneTargets, unselectedPruneTargets, err := opts.selectPruneTargets(ctx, m.TargetCli, getPruneTargets(app, oldDeployResources, manifestRawobjs))
+	if err != nil {
+		log.Error(err, "selectPruneTargets failed..")
+		return err
+	}
+	// Resources owned by other applications are neither updated nor pruned
+	pruneTargets, sharedPruneTargets, sharedMessages, err := splitSharedDeployResources(ctx, m.TargetCli, app, pruneTargets)
+	if err != nil {
+		log.Error(err, "splitSharedDeployResources failed..")
+		return err
+	}
+	if err := checkPruneLimit(app, prune, len(pruneTargets), len(oldDeployResources.Items)); err != nil {
+		log.Error(err, "Prune limit exceeded..")
+		return err
+	}
+	if err := forgetDeployResources(ctx, m.DefaultCli, sharedPruneTargets); err != nil {
+		log.Error(err, "forgetDeployResources failed..")
+		return err
+	}
 
-	for _, info := range manifestInfos {
-		manifestRawobjs, err := m.GitCli.ObjectFromManifest(info, app.Spec.Destination.Namespace)
-		if err != nil {
-			log.Error(err, "Get object from manifest failed..")
+	if opts.isApplyEnabled(revision) {
+		if err := ensureDestinationNamespace(ctx, m.TargetCli, app); err != nil {
+			log.Error(err, "ensureDestinationNamespace failed..")
 			return err
 		}
+	}
 
-		for _, manifestRawobj := range manifestRawobjs {
-			updatedDeployResource, err := updateDeployResource(m.DefaultCli, manifestRawobj, app)
-			if err != nil {
-				log.Error(err, "NewDeployResource failed..")
-				return err
-			}
-			updatedDeployResources[updatedDeployResource.Name] = updatedDeployResource
+	// The unmarked live objects of the resources tracked before are adopted, as they were applied by the application
+	tracked := map[string]bool{}
+	for _, deployResource := range oldDeployResources.Items {
+		tracked[specDeployResourceName(app, deployResource.Spec)] = true
+	}
 
-			manifestModifiedObj, err := m.compareDeployWithManifest(app, manifestRawobj)
-			if manifestModifiedObj == nil && err != nil {
-				log.Error(err, "Compare deployed resource with manifest failed..")
-				return err
-			}
-			if manifestModifiedObj != nil && (app.Spec.SyncPolicy.AutoSync || forced) {
-				exist := (err == nil)
-				if err := m.applyManifest(exist, manifestModifiedObj); err != nil {
-					log.Error(err, "Apply manifest failed..")
-					return err
-				}
-			}
+	// The resources of a wave are compared and applied concurrently. All failures of the wave are reported together
+	results := make([]*resourceResult, len(manifestRawobjs))
+	var syncErr error
+	for _, wave := range getSyncWaves(manifestRawobjs) {
+		wave := wave
+		syncErr = parallelize(ctx, len(wave), func(ctx context.Context, i int) error {
+			obj := manifestRawobjs[wave[i]]
+			result, err := m.syncResource(ctx, app, obj, revision, opts, tracked[deployResourceName(app, obj)])
+			results[wave[i]] = result
+			return err
+		})
+		if syncErr != nil {
+			break
+		}
+	}
+	results = compactResults(results)
+	setOutOfSync(app, results)
+	for _, result := range results {
+		if result.sharedMessage != "" {
+			sharedMessages = append(sharedMessages, result.sharedMessage)
 		}
 	}
+	setSharedResourceCondition(app, sharedMessages)
 
-	for _, oldDeployResource := range oldDeployResources.Items {
-		if updatedDeployResources[oldDeployResource.Name] == nil {
-			if err := m.clearApplicationResources(&oldDeployResource); err != nil {
-				log.Error(err, "clearApplicationResources failed..")
-				return err
-			}
+	if syncErr != nil {
+		setSyncResult(opts, revision, results, nil, nil)
+		if err := updateDeployResourceStatuses(ctx, m.DefaultCli, results); err != nil {
+			log.Error(err, "updateDeployResourceStatuses failed..")
 		}
+		if isTerminatedError(syncErr) {
+			return ErrTerminated
+		}
+		log.Error(syncErr, "Sync resources failed..")
+		return syncErr
+	}
+	applied := false
+	for _, result := range results {
+		applied = applied || result.resultCode == cdv1.ResultCodeSynced || result.resultCode == cdv1.ResultCodeRecreated
+	}
+
+	if prune && len(pruneTargets) > 0 && opts.isTerminated() {
+		setSyncResult(opts, revision, results, nil, nil)
+		return ErrTerminated
+	}
+	requirePruning, err := pruneDeployResources(ctx, m.TargetCli, app, pruneTargets, prune, m.clearApplicationResources)
+	if err != nil {
+		log.Error(err, "pruneDeployResources failed..")
+		return err
+	}
+	setSyncResult(opts, revision, results, pruneTargets, requirePruning)
+	pruned := prune && len(requirePruning) < len(pruneTargets)
+	if len(unselectedPruneTargets) > 0 {
+		app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
+		requirePruning = append(requirePruning, unselectedPruneTargets...)
 	}
 
 	if app.Status.Sync.Status == cdv1.SyncStatusCodeUnknown {
 		app.Status.Sync.Status = cdv1.SyncStatusCodeSynced
 	}
+	// Autosync is regarded as a sync only if it changed anything
+	if forced || applied || pruned {
+		now := metav1.Now()
+		app.Status.LastSyncedAt = &now
+	}
 
-	return nil
+	if err := setApplicationHealth(ctx, m.TargetCli, app, results); err != nil {
+		log.Error(err, "setApplicationHealth failed..")
+		return err
+	}
+	setApplicationResources(app, results, requirePruning)
+	// The orphaned resources are only monitored, so the sync does not fail even if they can't be listed
+	if err := setOrphanedResources(ctx, m.DefaultCli, m.TargetCli, app); err != nil {
+		log.Error(err, "setOrphanedResources failed..")
+	}
+
+	return updateDeployResourceStatuses(ctx, m.DefaultCli, results)
 }
 
-func (m *plainYamlManager) Clear(app *cdv1.Application) error {
-	if err := m.setTargetClient(app); err != nil {
-		return err
+func (m *plainYamlManager) Diff(ctx context.Context, app *cdv1.Application, opts SyncOptions) ([]ResourceDiff, error) {
+	revision := opts.Revision
+	if revision == "" {
+		revision = app.Spec.Source.TargetRevision
+	}
+	manifestRawobjs, err := m.objectsFromGit(ctx, app, m.resolveRevision(ctx, revision))
+	if err != nil {
+		return nil, err
+	}
+	oldDeployResources, err := getDeployResourceList(ctx, m.DefaultCli, app)
+	if err != nil {
+		log.Error(err, "GetDeployResourceList failed")
+		return nil, err
+	}
+	oldDeployResources, _ = splitMisscopedDeployResources(oldDeployResources, manifestRawobjs)
+
+	// The objects are compared with the marks of the application, as they're applied
+	for _, obj := range manifestRawobjs {
+		setTrackingMetadata(obj, app)
+	}
+
+	return diffResources(ctx, m.TargetCli, app, manifestRawobjs, oldDeployResources, opts)
+}
+
+// resolveRevision resolves the branch into its commit SHA, so that all manifests are read from the same commit.
+// Other revisions (tags, commit SHAs) are returned as they are
+func (m *plainYamlManager) resolveRevision(ctx context.Context, revision string) string {
+	branch, err := m.GitCli.GetBranch(ctx, revision)
+	if err != nil || branch.CommitID == "" {
+		return revision
 	}
+	return branch.CommitID
+}
 
-	deployedResourceList, err := getDeployResourceList(m.DefaultCli, app)
+// objectsFromGit gets all objects of the manifests in the source path at the revision
+func (m *plainYamlManager) objectsFromGit(ctx context.Context, app *cdv1.Application, revision string) ([]*unstructured.Unstructured, error) {
+	var manifestInfos []string
+	manifestInfos, err := m.GitCli.GetManifestInfos(ctx, app.Spec.Source.Path, revision, manifestInfos)
+	if err != nil {
+		log.Error(err, "GetManifestURLList failed..")
+		return nil, err
+	}
+
+	var manifestRawobjs []*unstructured.Unstructured
+	for _, info := range manifestInfos {
+		objs, err := m.GitCli.ObjectFromManifest(ctx, info)
+		if err != nil {
+			log.Error(err, "Get object from manifest failed..")
+			return nil, err
+		}
+		manifestRawobjs = append(manifestRawobjs, objs...)
+	}
+
+	destination := app.Spec.Destination
+	if err := setNamespaces(m.TargetCli.RESTMapper(), manifestRawobjs, destination.Namespace, destination.ForceNamespace); err != nil {
+		return nil, err
+	}
+	return manifestRawobjs, nil
+}
+
+func (m *plainYamlManager) Clear(ctx context.Context, app *cdv1.Application) error {
+	deployedResourceList, err := getDeployResourceList(ctx, m.DefaultCli, app)
 	if err != nil {
 		return err
 	}
 
-	for _, deployedResource := range deployedResourceList.Items {
-		if err := m.clearApplicationResources(&deployedResource); err != nil {
+	// The resources deleted in the foreground are tracked until they're gone
+	policy := app.GetDeletionPolicy()
+	deleting := 0
+	for i := range deployedResourceList.Items {
+		deployResource := &deployedResourceList.Items[i]
+		switch policy {
+		case cdv1.DeletionPolicyForeground:
+			exist, err := deleteDeployedObject(ctx, m.TargetCli, app, deployResource, metav1.DeletePropagationForeground)
+			if err != nil {
+				log.Error(err, "deleteDeployedObject failed..")
+				return err
+			}
+			if exist {
+				deleting++
+				continue
+			}
+		case cdv1.DeletionPolicyBackground:
+			if _, err := deleteDeployedObject(ctx, m.TargetCli, app, deployResource, metav1.DeletePropagationBackground); err != nil {
+				log.Error(err, "deleteDeployedObject failed..")
+				return err
+			}
+		}
+
+		if err := removeDeployResource(ctx, m.DefaultCli, deployResource); err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Delete DeployResource error..")
 			return err
 		}
 	}
 
+	if deleting > 0 {
+		return fmt.Errorf("%d of %d: %w", deleting, len(deployedResourceList.Items), ErrDeletionInProgress)
+	}
+
+	// The namespace created by the application is deleted, after its resources are deleted
+	deletingNs, err := deleteOwnedNamespace(ctx, m.TargetCli, app)
+	if err != nil {
+		log.Error(err, "deleteOwnedNamespace failed..")
+		return err
+	}
+	if deletingNs {
+		return fmt.Errorf("namespace %s: %w", app.Status.OwnedNamespace, ErrDeletionInProgress)
+	}
 	return nil
 }
 
-func (m *plainYamlManager) compareDeployWithManifest(app *cdv1.Application, manifestObj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
-	deployedObj := manifestObj.DeepCopy()
-	if err := m.TargetCli.Get(m.Context, types.NamespacedName{
-		Namespace: deployedObj.GetNamespace(),
-		Name:      deployedObj.GetName()}, deployedObj); err != nil {
-		if errors.IsNotFound(err) {
-			app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
-			return manifestObj, err
+// syncResource compares the resource with the manifest object, and applies it if they differ and the options allow.
+// It returns the result of the resource, even if it fails to be applied
+func (m *plainYamlManager) syncResource(ctx context.Context, app *cdv1.Application, obj *unstructured.Unstructured, revision string, opts SyncOptions, tracked bool) (*resourceResult, error) {
+	// The live object which can't be managed by the application is not tracked, and it fails to be applied
+	liveObj, err := getLiveObject(ctx, m.TargetCli, obj)
+	if err != nil {
+		log.Error(err, "getLiveObject failed..")
+		return nil, err
+	}
+	if msg := checkSharedResource(app, obj, liveObj, tracked); msg != "" {
+		result := newSharedResourceResult(obj, msg)
+		if !opts.isApplyEnabled(revision) || !opts.isObjectSelected(obj) {
+			return result, nil
 		}
+		err := fmt.Errorf("%s", msg)
+		result.setApplyError(err)
+		return result, err
+	}
+	setTrackingMetadata(obj, app)
+
+	deployResource, err := updateDeployResource(ctx, m.DefaultCli, obj, app)
+	if err != nil {
+		log.Error(err, "NewDeployResource failed..")
 		return nil, err
 	}
+	result := newResourceResult(obj, deployResource)
 
-	bytedDeployedObj, _ := deployedObj.MarshalJSON()
-	bytedManifestObj, _ := manifestObj.MarshalJSON()
+	manifestModifiedObj, recreateReason, err := m.compareDeployWithManifest(ctx, app, obj, liveObj, &result.status)
+	if err != nil {
+		log.Error(err, "Compare deployed resource with manifest failed..")
+		return result, err
+	}
+	if manifestModifiedObj == nil || !opts.isApplyEnabled(revision) || !opts.isObjectSelected(obj) {
+		return result, nil
+	}
 
-	patchedByte, _ := jsonpatch.MergePatch(bytedDeployedObj, bytedManifestObj)
+	if opts.isTerminated() {
+		return result, ErrTerminated
+	}
+	if recreateReason != "" {
+		if err := recreateObject(ctx, m.TargetCli, app, manifestModifiedObj); err != nil {
+			log.Error(err, "Recreate object failed..")
+			result.setApplyError(err)
+			return result, err
+		}
+		result.setRecreated(revision, recreateReason)
+		return result, nil
+	}
+	if err := m.applyManifest(ctx, liveObj != nil, manifestModifiedObj); err != nil {
+		log.Error(err, "Apply manifest failed..")
+		result.setApplyError(err)
+		return result, err
+	}
+	result.setApplied(revision)
+	return result, nil
+}
 
-	patchedObj := make(map[string]interface{})
-	if err := json.Unmarshal(patchedByte, &patchedObj); err != nil {
-		return nil, err
+// compareDeployWithManifest compares the live object of the resource with the manifest, and records the result in the
+// status. The live object is nil if it does not exist. It returns the object to be applied, if they differ, and the
+// reason if the resource must be recreated instead of being updated
+func (m *plainYamlManager) compareDeployWithManifest(ctx context.Context, app *cdv1.Application, manifestObj, liveObj *unstructured.Unstructured, status *cdv1.DeployResourceStatus) (*unstructured.Unstructured, string, error) {
+	if liveObj == nil {
+		status.SyncStatus = cdv1.SyncStatusCodeOutOfSync
+		status.DiffSummary = diffSummary(nil, manifestObj)
+		return manifestObj, "", nil
 	}
+	deployedObj := liveObj.DeepCopy()
 
-	manifestObj.SetUnstructuredContent(patchedObj)
-	if err := m.TargetCli.Update(m.Context, manifestObj, client.DryRunAll); err != nil {
-		return nil, err
+	recreateReason, err := dryRunApply(ctx, m.TargetCli, app, deployedObj, manifestObj)
+	if err != nil {
+		return nil, "", err
+	}
+	if recreateReason != "" {
+		status.SyncStatus = cdv1.SyncStatusCodeOutOfSync
+		status.DiffSummary = recreateReason
+		log.Info("Deployed resource can't be updated in place. Recreate..")
+		return manifestObj, recreateReason, nil
 	}
 
 	if fmt.Sprintf("%v", deployedObj) != fmt.Sprintf("%v", manifestObj) {
-		app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
+		status.SyncStatus = cdv1.SyncStatusCodeOutOfSync
+		status.DiffSummary = diffSummary(deployedObj, manifestObj)
 		log.Info("Deployed resource is not in-synced with manifests. Sync..")
-		return manifestObj, nil
+		return manifestObj, "", nil
 	}
 
-	return nil, nil
+	status.SyncStatus = cdv1.SyncStatusCodeSynced
+	status.DiffSummary = ""
+	return nil, "", nil
 }
 
-func (m *plainYamlManager) applyManifest(exist bool, manifestObj *unstructured.Unstructured) error {
+func (m *plainYamlManager) applyManifest(ctx context.Context, exist bool, manifestObj *unstructured.Unstructured) error {
 	if !exist {
 		log.Info("Create..")
-		if err := m.TargetCli.Create(m.Context, manifestObj); err != nil {
+		if err := m.TargetCli.Create(ctx, manifestObj); err != nil {
 			log.Error(err, "Creating Object failed..")
 			return err
 		}
 	} else {
-		if err := m.TargetCli.Update(m.Context, manifestObj); err != nil {
+		if err := m.TargetCli.Update(ctx, manifestObj); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (m *plainYamlManager) setTargetClient(app *cdv1.Application) error {
-	if app.Spec.Destination.Name != "" {
-		cfg, err := cluster.GetApplicationClusterConfig(m.Context, m.DefaultCli, app)
-		if err != nil {
-			log.Error(err, "GetConfig failed..")
-			return err
-		}
-
-		s := runtime.NewScheme()
-		utilruntime.Must(cdv1.AddToScheme(s))
-		c, err := client.New(cfg, client.Options{Scheme: s})
-		if err != nil {
-			log.Error(err, "Create client failed..")
-			return err
-		}
-		m.TargetCli = c
-	} else {
-		m.TargetCli = m.DefaultCli
+func (m *plainYamlManager) setTargetClient(ctx context.Context, app *cdv1.Application) error {
+	c, err := newTargetClient(ctx, m.DefaultCli, app)
+	if err != nil {
+		return err
 	}
+	m.TargetCli = c
 	return nil
 }
 
-func (m *plainYamlManager) clearApplicationResources(deployResource *cdv1.DeployResource) error {
-	deployedObj := &unstructured.Unstructured{}
-
-	if err := m.DefaultCli.Delete(m.Context, deployResource); err != nil {
-		log.Error(err, "Delete DeployResource error..")
-		return err
-	}
-
-	deployedObj.SetAPIVersion(deployResource.Spec.APIVersion)
-	deployedObj.SetKind(deployResource.Spec.Kind)
-	deployedObj.SetName(deployResource.Spec.Name)
-	deployedObj.SetNamespace(deployResource.Spec.Namespace)
-
-	if err := m.TargetCli.Get(m.Context, types.NamespacedName{Namespace: deployedObj.GetNamespace(), Name: deployedObj.GetName()}, deployedObj); err != nil {
-		if !errors.IsNotFound(err) {
-			log.Error(err, "Get deprecated resource error..")
-			return err
-		}
-		return nil
-	}
-
-	if err := m.TargetCli.Delete(m.Context, deployedObj); err != nil {
-		log.Error(err, "Delete deprecated resource error..")
-		return err
-	}
-
-	return nil
+func (m *plainYamlManager) clearApplicationResources(ctx context.Context, deployResource *cdv1.DeployResource) error {
+	return deleteDeployResource(ctx, m.DefaultCli, m.TargetCli, deployResource)
 }
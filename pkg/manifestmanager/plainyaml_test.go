@@ -10,10 +10,13 @@ import (
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
 	"github.com/tmax-cloud/cd-operator/pkg/httpclient"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
@@ -33,10 +36,11 @@ type compareDeployWithTestCase struct {
 	manifestObj *unstructured.Unstructured
 	deployedObj *unstructured.Unstructured
 
-	expectedObj        *unstructured.Unstructured
-	expectedSyncStatus cdv1.SyncStatusCode
-	expectedErrOccur   bool
-	expectedErrMsg     string
+	expectedObj            *unstructured.Unstructured
+	expectedRecreateReason string
+	expectedResourceStatus cdv1.DeployResourceStatus
+	expectedErrOccur       bool
+	expectedErrMsg         string
 }
 
 func TestCompareDeployWithManifest(t *testing.T) {
@@ -45,26 +49,34 @@ func TestCompareDeployWithManifest(t *testing.T) {
 			manifestObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
 			deployedObj: nil,
 
-			expectedObj:        &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
-			expectedSyncStatus: cdv1.SyncStatusCodeOutOfSync,
-			expectedErrOccur:   true,
-			expectedErrMsg:     `services "guestbook-ui" not found`,
+			expectedObj:            &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+			expectedResourceStatus: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeOutOfSync, DiffSummary: "resource does not exist"},
+			expectedErrOccur:       false,
 		},
 		"inSync": {
 			manifestObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
 			deployedObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 80}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
 
-			expectedObj:        nil,
-			expectedSyncStatus: cdv1.SyncStatusCodeUnknown,
-			expectedErrOccur:   false,
+			expectedObj:            nil,
+			expectedResourceStatus: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeSynced},
+			expectedErrOccur:       false,
 		},
 		"outSync": {
 			manifestObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
 			deployedObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 8080}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
 
-			expectedObj:        &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"creationTimestamp": interface{}(nil), "name": "guestbook-ui", "namespace": "test", "resourceVersion": "999"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}, "status": map[string]interface{}{"loadBalancer": map[string]interface{}{}}}},
-			expectedSyncStatus: cdv1.SyncStatusCodeOutOfSync,
-			expectedErrOccur:   false,
+			expectedObj:            &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"creationTimestamp": interface{}(nil), "name": "guestbook-ui", "namespace": "test", "resourceVersion": "999"}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}, "status": map[string]interface{}{"loadBalancer": map[string]interface{}{}}}},
+			expectedResourceStatus: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeOutOfSync, DiffSummary: "spec.ports"},
+			expectedErrOccur:       false,
+		},
+		"recreate": {
+			manifestObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test", "annotations": map[string]interface{}{cdv1.AnnotationSyncOptions: cdv1.SyncOptionRecreate}}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+			deployedObj: &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test", "annotations": map[string]interface{}{cdv1.AnnotationSyncOptions: cdv1.SyncOptionRecreate}}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": 80, "targetPort": 8080}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+
+			expectedObj:            &unstructured.Unstructured{Object: map[string]interface{}{"apiVersion": "v1", "kind": "Service", "metadata": map[string]interface{}{"name": "guestbook-ui", "namespace": "test", "annotations": map[string]interface{}{cdv1.AnnotationSyncOptions: cdv1.SyncOptionRecreate}}, "spec": map[string]interface{}{"ports": []interface{}{map[string]interface{}{"port": "80", "targetPort": "80"}}, "selector": map[string]interface{}{"app": "guestbook-ui"}}}},
+			expectedRecreateReason: "Recreate=true is set",
+			expectedResourceStatus: cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeOutOfSync, DiffSummary: "Recreate=true is set"},
+			expectedErrOccur:       false,
 		},
 	}
 
@@ -74,35 +86,96 @@ func TestCompareDeployWithManifest(t *testing.T) {
 
 	mockHTTPClient := &httpclient.MockHTTPClient{}
 	mockClient := fake.NewClientBuilder().Build()
-	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, Context: context.Background(), HTTPClient: mockHTTPClient}
+	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, HTTPClient: mockHTTPClient}
+	app := &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: "application", Namespace: "default"}}
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			app := &cdv1.Application{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      "test",
-					Namespace: "test",
-				},
-				Status: cdv1.ApplicationStatus{
-					Sync: cdv1.SyncStatus{
-						Status: cdv1.SyncStatusCodeUnknown,
-					},
-				},
-			}
 			if c.deployedObj != nil {
 				m.TargetCli = fake.NewClientBuilder().WithScheme(s).WithObjects(c.deployedObj).Build()
 			} else {
 				m.TargetCli = fake.NewClientBuilder().WithScheme(s).Build()
 			}
-			manifestObj, err := m.compareDeployWithManifest(app, c.manifestObj)
+			liveObj, err := getLiveObject(context.Background(), m.TargetCli, c.manifestObj)
+			require.NoError(t, err)
+			status := cdv1.DeployResourceStatus{}
+			manifestObj, recreateReason, err := m.compareDeployWithManifest(context.Background(), app, c.manifestObj, liveObj, &status)
 
 			if c.expectedErrOccur {
 				require.Equal(t, c.expectedErrMsg, err.Error())
 			} else {
 				require.NoError(t, err)
 			}
-			require.Equal(t, c.expectedSyncStatus, app.Status.Sync.Status)
+			require.Equal(t, c.expectedResourceStatus, status)
 			require.Equal(t, c.expectedObj, manifestObj)
+			require.Equal(t, c.expectedRecreateReason, recreateReason)
+		})
+	}
+}
+
+func TestCompareDeployWithManifestRecreateOnce(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	newManifest := func() *unstructured.Unstructured {
+		return newReplaceTestObj("new", cdv1.SyncOptionRecreate, nil)
+	}
+	m := plainYamlManager{TargetCli: fake.NewClientBuilder().WithScheme(s).WithObjects(newReplaceTestObj("old", "", nil)).Build()}
+	app := newTrackingTestApp("test-app")
+
+	live, err := getLiveObject(context.Background(), m.TargetCli, newManifest())
+	require.NoError(t, err)
+	status := cdv1.DeployResourceStatus{}
+	manifestObj, recreateReason, err := m.compareDeployWithManifest(context.Background(), app, newManifest(), live, &status)
+	require.NoError(t, err)
+	require.Equal(t, "Recreate=true is set", recreateReason)
+	require.NoError(t, recreateObject(context.Background(), m.TargetCli, app, manifestObj))
+
+	// The server and the other controllers set the fields, which are not specified in the manifest
+	live, err = getLiveObject(context.Background(), m.TargetCli, newManifest())
+	require.NoError(t, err)
+	live.SetLabels(map[string]string{"other": "true"})
+	require.NoError(t, m.TargetCli.Update(context.Background(), live))
+
+	// The second sync leaves the resource as it is
+	status = cdv1.DeployResourceStatus{}
+	manifestObj, recreateReason, err = m.compareDeployWithManifest(context.Background(), app, newManifest(), live, &status)
+	require.NoError(t, err)
+	require.Empty(t, recreateReason)
+	require.Nil(t, manifestObj)
+	require.Equal(t, cdv1.DeployResourceStatus{SyncStatus: cdv1.SyncStatusCodeSynced}, status)
+}
+
+// getCountingClient counts the objects got from the cluster
+type getCountingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *getCountingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestSyncResourceGetsLiveObjectOnce(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	for name, exist := range map[string]bool{"exist": true, "notExist": false} {
+		t.Run(name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(s)
+			if exist {
+				builder = builder.WithObjects(newReplaceTestObj("old", "", nil))
+			}
+			targetCli := &getCountingClient{Client: builder.Build()}
+			m := plainYamlManager{DefaultCli: fake.NewClientBuilder().WithScheme(s).Build(), TargetCli: targetCli}
+			app := newTrackingTestApp("test-app")
+
+			result, err := m.syncResource(context.Background(), app, newReplaceTestObj("new", "", nil), "", SyncOptions{Forced: true}, exist)
+			require.NoError(t, err)
+			require.Equal(t, cdv1.ResultCodeSynced, result.resultCode)
+			require.Equal(t, 1, targetCli.gets)
 		})
 	}
 }
@@ -153,11 +226,11 @@ func TestApplyManifest(t *testing.T) {
 
 	mockHTTPClient := &httpclient.MockHTTPClient{}
 	mockClient := fake.NewClientBuilder().WithScheme(s).WithObjects(existObj).Build()
-	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, Context: context.Background(), HTTPClient: mockHTTPClient}
+	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, HTTPClient: mockHTTPClient}
 
 	for name, c := range tc {
 		t.Run(name, func(t *testing.T) {
-			err := m.applyManifest(c.exist, c.manifestObj)
+			err := m.applyManifest(context.Background(), c.exist, c.manifestObj)
 			if c.expectedErrOccur {
 				require.Equal(t, c.expectedErrMsg, err.Error())
 			} else {
@@ -197,7 +270,7 @@ func TestSetTargetClient(t *testing.T) {
 
 	mockHTTPClient := &httpclient.MockHTTPClient{}
 	mockClient := fake.NewClientBuilder().Build()
-	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, Context: context.Background(), HTTPClient: mockHTTPClient}
+	m := plainYamlManager{DefaultCli: mockClient, TargetCli: mockClient, HTTPClient: mockHTTPClient}
 
 	server := newTestServer()
 
@@ -244,7 +317,7 @@ users:
 			sec.Data["value"] = []byte(sec.StringData["value"])
 
 			m.DefaultCli = fake.NewClientBuilder().WithScheme(s).WithObjects(app, sec).Build()
-			err := m.setTargetClient(app)
+			err := m.setTargetClient(context.Background(), app)
 			if c.expectedErrOccur {
 				require.Equal(t, c.expectedErrMsg, err.Error())
 			} else {
@@ -259,3 +332,93 @@ func newTestServer() *httptest.Server {
 
 	return httptest.NewServer(router)
 }
+
+type clearTestCase struct {
+	policy cdv1.DeletionPolicy
+
+	expectedInProgress bool
+	expectedObjExist   bool
+}
+
+func TestClear(t *testing.T) {
+	tc := map[string]clearTestCase{
+		"foreground": {
+			policy:             cdv1.DeletionPolicyForeground,
+			expectedInProgress: true,
+		},
+		"background": {
+			policy: cdv1.DeletionPolicyBackground,
+		},
+		"orphan": {
+			policy:           cdv1.DeletionPolicyOrphan,
+			expectedObjExist: true,
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default"},
+				Spec:       cdv1.ApplicationSpec{DeletionPolicy: c.policy},
+			}
+			svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test-svc", Namespace: "test"}}
+			dr := &cdv1.DeployResource{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-app-service-test-svc-test",
+					Namespace: "default",
+					Labels:    map[string]string{"cd.tmax.io/application": "test-app-default"},
+				},
+				Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: "test-svc", Namespace: "test"},
+			}
+			cli := fake.NewClientBuilder().WithScheme(s).WithObjects(svc, dr).Build()
+			m := &plainYamlManager{DefaultCli: cli, TargetCli: cli}
+
+			err := m.Clear(context.Background(), app)
+			if c.expectedInProgress {
+				require.ErrorIs(t, err, ErrDeletionInProgress)
+				require.NoError(t, cli.Get(context.Background(), types.NamespacedName{Name: dr.Name, Namespace: dr.Namespace}, &cdv1.DeployResource{}))
+				// The DeployResource is removed, after the object is gone
+				err = m.Clear(context.Background(), app)
+			}
+			require.NoError(t, err)
+
+			require.True(t, errors.IsNotFound(cli.Get(context.Background(), types.NamespacedName{Name: dr.Name, Namespace: dr.Namespace}, &cdv1.DeployResource{})))
+			err = cli.Get(context.Background(), types.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}, &corev1.Service{})
+			if c.expectedObjExist {
+				require.NoError(t, err)
+			} else {
+				require.True(t, errors.IsNotFound(err))
+			}
+		})
+	}
+}
+
+func TestClearAfterGarbageCollection(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := &cdv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default"},
+		Spec:       cdv1.ApplicationSpec{DeletionPolicy: cdv1.DeletionPolicyBackground},
+	}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "test-svc", Namespace: "test"}}
+	dr := newDeployResource(app, cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Name: "test-svc", Namespace: "test"})
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(svc, dr).Build()
+	m := &plainYamlManager{DefaultCli: cli, TargetCli: cli}
+
+	// The garbage collector deletes the DeployResource first, if the application is deleted in the foreground
+	require.NoError(t, cli.Delete(context.Background(), dr.DeepCopy()))
+	deleting := &cdv1.DeployResource{}
+	require.NoError(t, cli.Get(context.Background(), types.NamespacedName{Name: dr.Name, Namespace: dr.Namespace}, deleting))
+	require.NotNil(t, deleting.DeletionTimestamp)
+
+	// The DeployResource is kept until the resource is cleared
+	require.NoError(t, m.Clear(context.Background(), app))
+	require.True(t, errors.IsNotFound(cli.Get(context.Background(), types.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}, &corev1.Service{})))
+	require.True(t, errors.IsNotFound(cli.Get(context.Background(), types.NamespacedName{Name: dr.Name, Namespace: dr.Namespace}, &cdv1.DeployResource{})))
+}
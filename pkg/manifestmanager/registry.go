@@ -0,0 +1,45 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Factory creates a manifest manager for a sync of the application. The manager is set up with the application's own
+// git client and the client of its destination cluster, so it should not be shared between applications
+type Factory func(ctx context.Context, cli client.Client, app *cdv1.Application) (ManifestManager, error)
+
+var (
+	factories     = map[cdv1.ApplicationSourceType]Factory{}
+	factoriesLock sync.RWMutex
+)
+
+func init() {
+	Register(cdv1.ApplicationSourceTypePlainYAML, NewPlainYamlManager)
+	Register(cdv1.ApplicationSourceTypeHelm, NewHelmManager)
+}
+
+// Register registers the factory of the manifest managers for the source type. The factory which is already
+// registered for the source type is replaced
+func Register(sourceType cdv1.ApplicationSourceType, factory Factory) {
+	factoriesLock.Lock()
+	defer factoriesLock.Unlock()
+
+	factories[sourceType] = factory
+}
+
+// New creates a manifest manager for the source type of the application
+func New(ctx context.Context, cli client.Client, app *cdv1.Application) (ManifestManager, error) {
+	factoriesLock.RLock()
+	factory, exist := factories[app.Spec.Source.Type]
+	factoriesLock.RUnlock()
+
+	if !exist {
+		return nil, fmt.Errorf("source type %q is not supported", app.Spec.Source.Type)
+	}
+	return factory(ctx, cli, app)
+}
@@ -0,0 +1,44 @@
+package manifestmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type fakeManifestManager struct {
+	ManifestManager
+	app *cdv1.Application
+}
+
+func TestRegistry(t *testing.T) {
+	const sourceType cdv1.ApplicationSourceType = "Kustomize"
+
+	Register(sourceType, func(ctx context.Context, cli client.Client, app *cdv1.Application) (ManifestManager, error) {
+		return &fakeManifestManager{app: app}, nil
+	})
+	defer func() {
+		factoriesLock.Lock()
+		delete(factories, sourceType)
+		factoriesLock.Unlock()
+	}()
+
+	cli := fake.NewClientBuilder().Build()
+
+	// Each application gets its own manager
+	app1 := &cdv1.Application{Spec: cdv1.ApplicationSpec{Source: cdv1.ApplicationSource{Type: sourceType}}}
+	app2 := app1.DeepCopy()
+	m1, err := New(context.Background(), cli, app1)
+	require.NoError(t, err)
+	m2, err := New(context.Background(), cli, app2)
+	require.NoError(t, err)
+	require.Same(t, app1, m1.(*fakeManifestManager).app)
+	require.Same(t, app2, m2.(*fakeManifestManager).app)
+
+	_, err = New(context.Background(), cli, &cdv1.Application{Spec: cdv1.ApplicationSpec{Source: cdv1.ApplicationSource{Type: "unknown"}}})
+	require.EqualError(t, err, `source type "unknown" is not supported`)
+}
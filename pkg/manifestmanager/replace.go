@@ -0,0 +1,121 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Period to check if the resource being recreated is deleted, and the maximum time to wait for it
+const (
+	recreatePollInterval = time.Second
+	recreateTimeout      = 2 * time.Minute
+)
+
+// dryRunApply fills manifestObj with the object which the server would persist on update. The manifest is merged into
+// the deployed object, or replaces it with Replace=true or Recreate=true.
+// With Recreate=true, manifestObj is filled with the deployed object, if the fields of the dry-run result are not
+// changed.
+// If the resource must be recreated instead, it returns the reason and leaves manifestObj as it is, so that the
+// manifest can be created again
+func dryRunApply(ctx context.Context, cli client.Client, app *cdv1.Application, deployedObj, manifestObj *unstructured.Unstructured) (string, error) {
+	original := manifestObj.DeepCopy()
+	recreate := hasSyncOption(original, cdv1.SyncOptionRecreate)
+
+	var err error
+	if recreate || hasSyncOption(original, cdv1.SyncOptionReplace) {
+		err = replaceManifest(ctx, cli, deployedObj, manifestObj)
+	} else {
+		err = mergeManifest(ctx, cli, deployedObj, manifestObj)
+	}
+
+	var reason string
+	switch {
+	case err == nil && recreate && !manifestFieldsDiffer(deployedObj, manifestObj):
+		// Only the fields of the dry-run result are compared, so that the fields set by other controllers don't make
+		// the resource recreated on every sync. The values of the result are normalized and defaulted by the server
+		// as the live ones, e.g., quantities
+		manifestObj.SetUnstructuredContent(deployedObj.DeepCopy().Object)
+		return "", nil
+	case err == nil && recreate:
+		reason = cdv1.SyncOptionRecreate + " is set"
+	case err != nil && isImmutableFieldError(err) && (recreate || app.HasSyncOption(cdv1.SyncOptionRecreateOnImmutableError)):
+		reason = "immutable fields are changed: " + err.Error()
+	default:
+		return "", err
+	}
+
+	manifestObj.SetUnstructuredContent(original.Object)
+	return reason, nil
+}
+
+// replaceManifest fills manifestObj with the object which the server would persist, if the deployed object is replaced
+// with the manifest
+func replaceManifest(ctx context.Context, cli client.Client, deployedObj, manifestObj *unstructured.Unstructured) error {
+	manifestObj.SetResourceVersion(deployedObj.GetResourceVersion())
+	return cli.Update(ctx, manifestObj, client.DryRunAll)
+}
+
+// isImmutableFieldError checks if the update is refused, as it changes the fields which can't be updated, e.g.,
+// "spec.selector: Invalid value: ...: field is immutable" or "spec: Forbidden: updates to statefulset spec for fields
+// other than ... are forbidden"
+func isImmutableFieldError(err error) bool {
+	if !errors.IsInvalid(err) {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "field is immutable") || strings.Contains(msg, "Forbidden: updates to")
+}
+
+// recreateObject deletes the live object of the manifest, and creates the manifest object again once it's gone.
+// The dependents of the live object are deleted in the foreground with it, or orphaned with OrphanDependents=true
+func recreateObject(ctx context.Context, cli client.Client, app *cdv1.Application, manifestObj *unstructured.Unstructured) error {
+	liveObj, err := getLiveObject(ctx, cli, manifestObj)
+	if err != nil {
+		return err
+	}
+
+	if liveObj != nil {
+		propagation := metav1.DeletePropagationForeground
+		if app.HasSyncOption(cdv1.SyncOptionOrphanDependents) || hasSyncOption(manifestObj, cdv1.SyncOptionOrphanDependents) {
+			propagation = metav1.DeletePropagationOrphan
+		}
+
+		log.Info(fmt.Sprintf("Recreating %s with %s propagation", objectString(liveObj), propagation))
+		uid := liveObj.GetUID()
+		if err := cli.Delete(ctx, liveObj, client.PropagationPolicy(propagation), client.Preconditions{UID: &uid}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		if err := waitForDeletion(ctx, cli, liveObj); err != nil {
+			return err
+		}
+	}
+
+	return cli.Create(ctx, manifestObj)
+}
+
+// waitForDeletion waits until the object is deleted, i.e., it does not exist or another object of the name exists
+func waitForDeletion(ctx context.Context, cli client.Client, obj *unstructured.Unstructured) error {
+	ctx, cancel := context.WithTimeout(ctx, recreateTimeout)
+	defer cancel()
+
+	err := wait.PollImmediateUntil(recreatePollInterval, func() (bool, error) {
+		liveObj, err := getLiveObject(ctx, cli, obj)
+		if err != nil {
+			return false, err
+		}
+		return liveObj == nil || liveObj.GetUID() != obj.GetUID(), nil
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("timed out waiting for %s to be deleted", objectString(obj))
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// immutableClient refuses all updates, as if they changed immutable fields
+type immutableClient struct {
+	client.Client
+}
+
+func (c *immutableClient) Update(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+	return newImmutableFieldError(obj.GetName())
+}
+
+// normalizingClient normalizes the quantities in the data of the objects on dry-run updates, as the server does for
+// the quantities of the typed resources
+type normalizingClient struct {
+	client.Client
+}
+
+func (c *normalizingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		data, _, _ := unstructured.NestedStringMap(u.Object, "data")
+		for k, v := range data {
+			if q, err := resource.ParseQuantity(v); err == nil {
+				data[k] = q.String()
+			}
+		}
+		_ = unstructured.SetNestedStringMap(u.Object, data, "data")
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func newImmutableFieldError(name string) error {
+	return errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, name, field.ErrorList{field.Invalid(field.NewPath("data"), "", "field is immutable")})
+}
+
+func newReplaceTestObj(data string, options string, labels map[string]string) *unstructured.Unstructured {
+	obj := newNamespaceTestObj("v1", "ConfigMap", "test", "cm")
+	obj.Object["data"] = map[string]interface{}{"key": data}
+	if options != "" {
+		obj.SetAnnotations(map[string]string{cdv1.AnnotationSyncOptions: options})
+	}
+	obj.SetLabels(labels)
+	return obj
+}
+
+type dryRunApplyTestCase struct {
+	appSyncOptions []string
+	manifestObj    *unstructured.Unstructured
+	liveData       string
+	immutable      bool
+	normalize      bool
+
+	expectedReason   string
+	expectedLabels   map[string]string
+	expectedErrOccur bool
+}
+
+func TestDryRunApply(t *testing.T) {
+	tc := map[string]dryRunApplyTestCase{
+		"merge": {
+			manifestObj:    newReplaceTestObj("new", "", nil),
+			expectedLabels: map[string]string{"live": "true"},
+		},
+		"replace": {
+			manifestObj:    newReplaceTestObj("new", cdv1.SyncOptionReplace, nil),
+			expectedLabels: nil,
+		},
+		"recreate": {
+			manifestObj:    newReplaceTestObj("new", cdv1.SyncOptionRecreate, nil),
+			expectedReason: "Recreate=true is set",
+		},
+		"recreateInSync": {
+			// The labels are set by others on the live object, but not specified in the manifest
+			manifestObj:    newReplaceTestObj("old", cdv1.SyncOptionRecreate, nil),
+			expectedLabels: map[string]string{"live": "true"},
+		},
+		"recreateNormalized": {
+			// The manifest is normalized by the server as the live object
+			manifestObj:    newReplaceTestObj("0.5", cdv1.SyncOptionRecreate, nil),
+			liveData:       "500m",
+			normalize:      true,
+			expectedLabels: map[string]string{"live": "true"},
+		},
+		"immutable": {
+			manifestObj:      newReplaceTestObj("new", "", nil),
+			immutable:        true,
+			expectedErrOccur: true,
+		},
+		"immutableRecreate": {
+			manifestObj:    newReplaceTestObj("new", cdv1.SyncOptionRecreate, nil),
+			immutable:      true,
+			expectedReason: "immutable fields are changed: " + newImmutableFieldError("cm").Error(),
+		},
+		"immutableRecreateOnImmutableError": {
+			appSyncOptions: []string{cdv1.SyncOptionRecreateOnImmutableError},
+			manifestObj:    newReplaceTestObj("new", "", nil),
+			immutable:      true,
+			expectedReason: "immutable fields are changed: " + newImmutableFieldError("cm").Error(),
+		},
+	}
+
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := newTrackingTestApp("test-app")
+			app.Spec.SyncPolicy.SyncOptions = c.appSyncOptions
+
+			liveData := c.liveData
+			if liveData == "" {
+				liveData = "old"
+			}
+			var cli client.Client = fake.NewClientBuilder().WithScheme(s).WithObjects(newReplaceTestObj(liveData, c.manifestObj.GetAnnotations()[cdv1.AnnotationSyncOptions], map[string]string{"live": "true"})).Build()
+			if c.immutable {
+				cli = &immutableClient{Client: cli}
+			}
+			if c.normalize {
+				cli = &normalizingClient{Client: cli}
+			}
+			deployedObj, err := getLiveObject(context.Background(), cli, c.manifestObj)
+			require.NoError(t, err)
+
+			original := c.manifestObj.DeepCopy()
+			reason, err := dryRunApply(context.Background(), cli, app, deployedObj, c.manifestObj)
+			if c.expectedErrOccur {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expectedReason, reason)
+			if reason != "" {
+				// The manifest is left as it is, to be created again
+				require.Equal(t, original, c.manifestObj)
+				return
+			}
+			require.Equal(t, c.expectedLabels, c.manifestObj.GetLabels())
+			require.Equal(t, deployedObj.GetResourceVersion(), c.manifestObj.GetResourceVersion())
+		})
+	}
+}
+
+func TestIsImmutableFieldError(t *testing.T) {
+	require.True(t, isImmutableFieldError(newImmutableFieldError("cm")))
+	require.True(t, isImmutableFieldError(errors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, "sts", field.ErrorList{field.Forbidden(field.NewPath("spec"), "updates to statefulset spec for fields other than 'replicas', 'template' and 'updateStrategy' are forbidden")})))
+	require.False(t, isImmutableFieldError(errors.NewInvalid(schema.GroupKind{Kind: "ConfigMap"}, "cm", field.ErrorList{field.Required(field.NewPath("data"), "")})))
+	require.False(t, isImmutableFieldError(fmt.Errorf("field is immutable")))
+}
+
+func TestRecreateObject(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	for name, exist := range map[string]bool{"exist": true, "notExist": false} {
+		t.Run(name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(s)
+			if exist {
+				live := newReplaceTestObj("old", "", map[string]string{"live": "true"})
+				live.SetUID("old-uid")
+				builder = builder.WithObjects(live)
+			}
+			cli := builder.Build()
+
+			app := newTrackingTestApp("test-app")
+			app.Spec.SyncPolicy.SyncOptions = []string{cdv1.SyncOptionOrphanDependents}
+			require.NoError(t, recreateObject(context.Background(), cli, app, newReplaceTestObj("new", "", nil)))
+
+			recreated, err := getLiveObject(context.Background(), cli, newReplaceTestObj("", "", nil))
+			require.NoError(t, err)
+			require.Equal(t, "new", recreated.Object["data"].(map[string]interface{})["key"])
+			require.Empty(t, recreated.GetLabels())
+		})
+	}
+}
+
+func TestSetRecreated(t *testing.T) {
+	result := newResourceResult(newReplaceTestObj("new", "", nil), &cdv1.DeployResource{ObjectMeta: metav1.ObjectMeta{Name: "dr"}})
+	result.setRecreated("rev", "Recreate=true is set")
+
+	require.Equal(t, cdv1.ResultCodeRecreated, result.resultCode)
+	require.True(t, strings.HasPrefix(result.resultMessage, "recreated, as "))
+	require.Equal(t, cdv1.SyncStatusCodeSynced, result.status.SyncStatus)
+	require.Equal(t, "rev", result.status.LastAppliedRevision)
+}
@@ -0,0 +1,170 @@
+package manifestmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Tracking methods, which can be set in trackingMethod of the cd-config config map
+const (
+	trackingMethodLabel              = "label"
+	trackingMethodAnnotation         = "annotation"
+	trackingMethodAnnotationAndLabel = "annotation+label"
+)
+
+// getTrackingMethod returns how the applied objects are marked with their applications. Default is label
+func getTrackingMethod() string {
+	switch configs.TrackingMethod {
+	case trackingMethodAnnotation, trackingMethodAnnotationAndLabel:
+		return configs.TrackingMethod
+	default:
+		return trackingMethodLabel
+	}
+}
+
+// trackingID returns the value of the tracking id annotation of the application
+func trackingID(app *cdv1.Application) string {
+	return app.Namespace + "/" + app.Name
+}
+
+// instanceLabelValue returns the value of the app-instance label of the application
+func instanceLabelValue(app *cdv1.Application) string {
+	return shortenLabelValue(app.Namespace + "_" + app.Name)
+}
+
+// setTrackingMetadata marks the object with the application, following the tracking method
+func setTrackingMetadata(obj *unstructured.Unstructured, app *cdv1.Application) {
+	method := getTrackingMethod()
+	if method != trackingMethodAnnotation {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[cdv1.LabelAppInstance] = instanceLabelValue(app)
+		obj.SetLabels(labels)
+	}
+	if method != trackingMethodLabel {
+		annotations := obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[cdv1.AnnotationTrackingID] = trackingID(app)
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// getTrackingOwner returns the application marked on the live object, and whether it's the given application.
+// The owner is empty if the object is not marked. Both marks are read regardless of the tracking method, so that the
+// objects marked before the method is changed are recognized
+func getTrackingOwner(obj *unstructured.Unstructured, app *cdv1.Application) (string, bool) {
+	if id, exist := obj.GetAnnotations()[cdv1.AnnotationTrackingID]; exist {
+		return id, id == trackingID(app)
+	}
+	if v, exist := obj.GetLabels()[cdv1.LabelAppInstance]; exist {
+		return v, v == instanceLabelValue(app)
+	}
+	return "", false
+}
+
+// isAdoptionAllowed checks if the live object, which is not managed by any application, can be adopted
+func isAdoptionAllowed(app *cdv1.Application, manifestObj *unstructured.Unstructured) bool {
+	return app.HasSyncOption(cdv1.SyncOptionAdopt) || hasSyncOption(manifestObj, cdv1.SyncOptionAdopt)
+}
+
+// checkSharedResource checks if the live object of the manifest can be updated by the application. It returns the
+// reason why it can't, if the object is owned by another application, or it's not managed by any application and
+// neither tracked nor adopted by the application. The unmarked objects tracked by the application were applied before
+// they're marked
+func checkSharedResource(app *cdv1.Application, manifestObj, liveObj *unstructured.Unstructured, tracked bool) string {
+	if liveObj == nil {
+		return ""
+	}
+
+	owner, owned := getTrackingOwner(liveObj, app)
+	switch {
+	case owner != "" && !owned:
+		return fmt.Sprintf("%s is owned by application %s", objectString(liveObj), owner)
+	case owner == "" && !tracked && !isAdoptionAllowed(app, manifestObj):
+		return fmt.Sprintf("%s already exists and is not managed by any application. Set sync option %s to adopt it", objectString(liveObj), cdv1.SyncOptionAdopt)
+	}
+	return ""
+}
+
+// getLiveObject gets the live object of the manifest. It returns nil if the object does not exist
+func getLiveObject(ctx context.Context, cli client.Client, manifestObj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	liveObj := &unstructured.Unstructured{}
+	liveObj.SetGroupVersionKind(manifestObj.GroupVersionKind())
+	if err := cli.Get(ctx, client.ObjectKeyFromObject(manifestObj), liveObj); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return liveObj, nil
+}
+
+// splitSharedDeployResources splits the DeployResources, whose live objects are owned by other applications, from the
+// others. They must not be pruned or deleted by the application. It returns the reasons of the shared ones as well
+func splitSharedDeployResources(ctx context.Context, cli client.Client, app *cdv1.Application, deployResources []cdv1.DeployResource) ([]cdv1.DeployResource, []cdv1.DeployResource, []string, error) {
+	var others, shared []cdv1.DeployResource
+	var messages []string
+	for i := range deployResources {
+		deployedObj, err := getDeployedObject(ctx, cli, &deployResources[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if deployedObj != nil {
+			if owner, owned := getTrackingOwner(deployedObj, app); owner != "" && !owned {
+				shared = append(shared, deployResources[i])
+				messages = append(messages, fmt.Sprintf("%s is owned by application %s", objectString(deployedObj), owner))
+				continue
+			}
+		}
+		others = append(others, deployResources[i])
+	}
+	return others, shared, messages, nil
+}
+
+// forgetDeployResources deletes the DeployResources without their live objects, so that the application stops
+// tracking the objects owned by other applications
+func forgetDeployResources(ctx context.Context, cli client.Client, deployResources []cdv1.DeployResource) error {
+	for i := range deployResources {
+		log.Info(fmt.Sprintf("Stop tracking %s %s/%s, which is owned by another application", deployResources[i].Spec.Kind, deployResources[i].Spec.Namespace, deployResources[i].Spec.Name))
+		if err := removeDeployResource(ctx, cli, &deployResources[i]); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSharedResourceCondition sets the shared resource warning of the application, if there are any shared resources.
+// Otherwise, it removes the warning
+func setSharedResourceCondition(app *cdv1.Application, messages []string) {
+	if len(messages) == 0 {
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionSharedResourceWarning)
+		return
+	}
+
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:    cdv1.ApplicationConditionSharedResourceWarning,
+		Status:  metav1.ConditionTrue,
+		Reason:  "SharedResourceWarning",
+		Message: strings.Join(messages, "; "),
+	})
+}
+
+func objectString(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetKind() + "/" + obj.GetName()
+	}
+	return obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+}
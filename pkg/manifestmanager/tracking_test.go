@@ -0,0 +1,157 @@
+package manifestmanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTrackingTestApp(name string) *cdv1.Application {
+	return &cdv1.Application{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+func TestInstanceLabelValue(t *testing.T) {
+	require.Equal(t, "default_test-app", instanceLabelValue(newTrackingTestApp("test-app")))
+
+	long := newTrackingTestApp(strings.Repeat("a", 100))
+	v := instanceLabelValue(long)
+	require.Len(t, v, validation.LabelValueMaxLength)
+	require.Empty(t, validation.IsValidLabelValue(v))
+	require.Equal(t, v, instanceLabelValue(long))
+	require.NotEqual(t, v, instanceLabelValue(newTrackingTestApp(strings.Repeat("a", 99))))
+}
+
+type setTrackingMetadataTestCase struct {
+	method string
+
+	expectedLabels      map[string]string
+	expectedAnnotations map[string]string
+}
+
+func TestSetTrackingMetadata(t *testing.T) {
+	tc := map[string]setTrackingMetadataTestCase{
+		"default": {
+			expectedLabels: map[string]string{"app": "test", cdv1.LabelAppInstance: "default_test-app"},
+		},
+		"annotation": {
+			method:              trackingMethodAnnotation,
+			expectedLabels:      map[string]string{"app": "test"},
+			expectedAnnotations: map[string]string{cdv1.AnnotationTrackingID: "default/test-app"},
+		},
+		"annotationAndLabel": {
+			method:              trackingMethodAnnotationAndLabel,
+			expectedLabels:      map[string]string{"app": "test", cdv1.LabelAppInstance: "default_test-app"},
+			expectedAnnotations: map[string]string{cdv1.AnnotationTrackingID: "default/test-app"},
+		},
+	}
+
+	defer func(method string) { configs.TrackingMethod = method }(configs.TrackingMethod)
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			configs.TrackingMethod = c.method
+			obj := newNamespaceTestObj("v1", "Service", "test", "svc")
+			obj.SetLabels(map[string]string{"app": "test"})
+
+			setTrackingMetadata(obj, newTrackingTestApp("test-app"))
+			require.Equal(t, c.expectedLabels, obj.GetLabels())
+			require.Equal(t, c.expectedAnnotations, obj.GetAnnotations())
+		})
+	}
+}
+
+type checkSharedResourceTestCase struct {
+	liveLabels      map[string]string
+	liveAnnotations map[string]string
+	liveNotExist    bool
+	tracked         bool
+	appSyncOptions  []string
+	manifestAdopted bool
+
+	expectedMessage string
+}
+
+func TestCheckSharedResource(t *testing.T) {
+	tc := map[string]checkSharedResourceTestCase{
+		"notExist": {
+			liveNotExist: true,
+		},
+		"ownedByLabel": {
+			liveLabels: map[string]string{cdv1.LabelAppInstance: "default_test-app"},
+		},
+		"ownedByAnnotation": {
+			liveAnnotations: map[string]string{cdv1.AnnotationTrackingID: "default/test-app"},
+		},
+		"ownedByOther": {
+			liveLabels:      map[string]string{cdv1.LabelAppInstance: "default_test-app"},
+			liveAnnotations: map[string]string{cdv1.AnnotationTrackingID: "default/other-app"},
+			expectedMessage: "Service test/svc is owned by application default/other-app",
+		},
+		"unmarkedTracked": {
+			tracked: true,
+		},
+		"unmarked": {
+			expectedMessage: "Service test/svc already exists and is not managed by any application. Set sync option Adopt=true to adopt it",
+		},
+		"adoptedByApplication": {
+			appSyncOptions: []string{cdv1.SyncOptionAdopt},
+		},
+		"adoptedByManifest": {
+			manifestAdopted: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := newTrackingTestApp("test-app")
+			app.Spec.SyncPolicy.SyncOptions = c.appSyncOptions
+
+			manifestObj := newNamespaceTestObj("v1", "Service", "test", "svc")
+			if c.manifestAdopted {
+				manifestObj.SetAnnotations(map[string]string{cdv1.AnnotationSyncOptions: cdv1.SyncOptionAdopt})
+			}
+
+			var liveObj *unstructured.Unstructured
+			if !c.liveNotExist {
+				liveObj = newNamespaceTestObj("v1", "Service", "test", "svc")
+				liveObj.SetLabels(c.liveLabels)
+				liveObj.SetAnnotations(c.liveAnnotations)
+			}
+
+			require.Equal(t, c.expectedMessage, checkSharedResource(app, manifestObj, liveObj, c.tracked))
+		})
+	}
+}
+
+func TestSplitSharedDeployResources(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(corev1.AddToScheme(s))
+
+	owned := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "owned", Namespace: "test", Labels: map[string]string{cdv1.LabelAppInstance: "default_test-app"}}}
+	shared := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "test", Labels: map[string]string{cdv1.LabelAppInstance: "default_other-app"}}}
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(owned, shared).Build()
+
+	var deployResources []cdv1.DeployResource
+	for _, name := range []string{"owned", "shared", "deleted"} {
+		deployResources = append(deployResources, cdv1.DeployResource{Spec: cdv1.DeployResourceSpec{APIVersion: "v1", Kind: "Service", Namespace: "test", Name: name}})
+	}
+
+	others, sharedDeployResources, messages, err := splitSharedDeployResources(context.Background(), cli, newTrackingTestApp("test-app"), deployResources)
+	require.NoError(t, err)
+	require.Len(t, others, 2)
+	require.Equal(t, "owned", others[0].Spec.Name)
+	require.Equal(t, "deleted", others[1].Spec.Name)
+	require.Len(t, sharedDeployResources, 1)
+	require.Equal(t, "shared", sharedDeployResources[0].Spec.Name)
+	require.Equal(t, []string{"Service test/shared is owned by application default_other-app"}, messages)
+}
@@ -57,7 +57,7 @@ func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert webhook
-	wh, err := gitCli.ParseWebhook(r.Header, body)
+	wh, err := gitCli.ParseWebhook(r.Context(), r.Header, body)
 	if err != nil {
 		_ = utils.RespondError(w, http.StatusInternalServerError, fmt.Sprintf("req: %s, cannot parse webhook body", reqID))
 		log.Info("Cannot parse webhook", "error", err.Error())
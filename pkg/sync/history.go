@@ -0,0 +1,51 @@
+package sync
+
+import (
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	defaultRevisionHistoryLimit = 10
+)
+
+// addHistory records a completed sync in the history of the application, and removes the oldest ones over the limit
+func addHistory(app *cdv1.Application, startedAt metav1.Time, initiator cdv1.SyncInitiator, syncErr error) {
+	source := app.Spec.Source.DeepCopy()
+	source.Token = nil
+
+	history := cdv1.SyncHistory{
+		Revision:    app.Status.Sync.Revision,
+		Source:      *source,
+		StartedAt:   startedAt,
+		FinishedAt:  metav1.Now(),
+		InitiatedBy: initiator,
+		Result:      cdv1.SyncResultSucceeded,
+	}
+	if syncErr != nil {
+		history.Result = cdv1.SyncResultFailed
+		history.Message = syncErr.Error()
+	}
+
+	if len(app.Status.History) > 0 {
+		last := &app.Status.History[len(app.Status.History)-1]
+		// Autosync retries the same failed sync periodically. Update the last history, not to flood the history with it
+		if initiator.Type == cdv1.SyncInitiatorTypeAutoSync && last.InitiatedBy.Type == initiator.Type &&
+			last.Result == cdv1.SyncResultFailed && history.Result == cdv1.SyncResultFailed &&
+			last.Revision == history.Revision && last.Message == history.Message {
+			last.FinishedAt = history.FinishedAt
+			return
+		}
+		history.ID = last.ID + 1
+	}
+
+	app.Status.History = append(app.Status.History, history)
+
+	limit := app.Spec.RevisionHistoryLimit
+	if limit == 0 {
+		limit = defaultRevisionHistoryLimit
+	}
+	if len(app.Status.History) > limit {
+		app.Status.History = app.Status.History[len(app.Status.History)-limit:]
+	}
+}
@@ -0,0 +1,81 @@
+package sync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type addHistoryTestCase struct {
+	limit     int
+	initiator cdv1.SyncInitiator
+	errs      []error
+
+	expectedIDs     []int64
+	expectedResults []cdv1.SyncResult
+}
+
+func TestAddHistory(t *testing.T) {
+	autoSync := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeAutoSync}
+	user := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeUser, Username: "admin"}
+
+	tc := map[string]addHistoryTestCase{
+		"succeeded": {
+			initiator:       user,
+			errs:            []error{nil, nil},
+			expectedIDs:     []int64{0, 1},
+			expectedResults: []cdv1.SyncResult{cdv1.SyncResultSucceeded, cdv1.SyncResultSucceeded},
+		},
+		"limit": {
+			limit:           2,
+			initiator:       user,
+			errs:            []error{nil, nil, fmt.Errorf("apply failed")},
+			expectedIDs:     []int64{1, 2},
+			expectedResults: []cdv1.SyncResult{cdv1.SyncResultSucceeded, cdv1.SyncResultFailed},
+		},
+		"repeatedAutoSyncFailure": {
+			initiator:       autoSync,
+			errs:            []error{nil, fmt.Errorf("apply failed"), fmt.Errorf("apply failed")},
+			expectedIDs:     []int64{0, 1},
+			expectedResults: []cdv1.SyncResult{cdv1.SyncResultSucceeded, cdv1.SyncResultFailed},
+		},
+		"repeatedUserFailure": {
+			initiator:       user,
+			errs:            []error{fmt.Errorf("apply failed"), fmt.Errorf("apply failed")},
+			expectedIDs:     []int64{0, 1},
+			expectedResults: []cdv1.SyncResult{cdv1.SyncResultFailed, cdv1.SyncResultFailed},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{Spec: cdv1.ApplicationSpec{
+				RevisionHistoryLimit: c.limit,
+				Source: cdv1.ApplicationSource{
+					RepoURL: "https://github.com/tmax-cloud/cd-example-apps",
+					Token:   &cdv1.GitToken{Value: "token"},
+				},
+			}}
+			app.Status.Sync.Revision = "abcdef"
+
+			for _, err := range c.errs {
+				addHistory(app, metav1.Now(), c.initiator, err)
+			}
+
+			var ids []int64
+			var results []cdv1.SyncResult
+			for _, h := range app.Status.History {
+				ids = append(ids, h.ID)
+				results = append(results, h.Result)
+				require.Equal(t, "abcdef", h.Revision)
+				require.Nil(t, h.Source.Token)
+				require.Equal(t, c.initiator, h.InitiatedBy)
+			}
+			require.Equal(t, c.expectedIDs, ids)
+			require.Equal(t, c.expectedResults, results)
+		})
+	}
+}
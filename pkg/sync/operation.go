@@ -0,0 +1,393 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Messages of the operations, which are stopped before they finish
+const (
+	operationTerminatedMessage  = "Operation is terminated"
+	operationInterruptedMessage = "Operation is interrupted"
+)
+
+// terminationCheckPeriod is the period to check if the running operation is terminated
+const terminationCheckPeriod = 2 * time.Second
+
+// ErrOperationInProgress is returned when an operation is requested while another one, which cannot be merged with
+// it, is pending
+var ErrOperationInProgress = fmt.Errorf("another operation is already in progress")
+
+// ErrOperationInterrupted is returned when the operation is cancelled before it finishes, e.g., as the controller
+// is shutting down
+var ErrOperationInterrupted = fmt.Errorf("operation is interrupted")
+
+// RequestOperation requests the operation to the application. It's executed by the controller, and its result is
+// recorded in status.operationState.
+// If an operation is running, the operation is queued and executed after it. If another operation is already queued,
+// the triggers of the full syncs are coalesced into one operation, and the others are refused.
+// The requested operation is patched with the resource version of the application, so that the concurrent triggers,
+// even the ones of other processes, are not lost. The patch is retried with the latest application on a conflict
+func RequestOperation(ctx context.Context, cli client.Client, app *cdv1.Application, op cdv1.Operation) error {
+	key := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// The application may be outdated, if the patch conflicted
+		if !first {
+			if err := cli.Get(ctx, key, app); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		requested := op.DeepCopy()
+		if app.Operation != nil {
+			if !isCoalescable(*app.Operation) || !isCoalescable(op) {
+				return ErrOperationInProgress
+			}
+			requested = coalesceOperations(*app.Operation, op)
+			log.Info(fmt.Sprintf("Operation of %s/%s is coalesced with the pending one", app.Namespace, app.Name))
+		}
+
+		patch := client.MergeFromWithOptions(app.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		app.Operation = requested
+		return cli.Patch(ctx, app, patch)
+	})
+}
+
+// isCoalescable checks if the operation syncs the whole application to its target revision, so that it can be merged
+// with another one
+func isCoalescable(op cdv1.Operation) bool {
+	syncOp := op.Sync
+	return syncOp != nil && syncOp.Revision == "" && syncOp.RollbackTo == nil && len(syncOp.Resources) == 0 &&
+		len(syncOp.LabelSelectors) == 0 && syncOp.Prune == nil
+}
+
+// coalesceOperations merges the pending operation and the new one. The merged operation is initiated by the manual
+// trigger rather than the automated ones, or by the latest one. The other triggers are superseded by it
+func coalesceOperations(pending, op cdv1.Operation) *cdv1.Operation {
+	merged := op.DeepCopy()
+	superseded := pending.InitiatedBy
+	if operationPriority(pending) > operationPriority(op) {
+		merged.InitiatedBy = pending.InitiatedBy
+		superseded = op.InitiatedBy
+	}
+
+	var coalesced []cdv1.SyncInitiator
+	coalesced = append(coalesced, pending.Coalesced...)
+	coalesced = append(coalesced, op.Coalesced...)
+	merged.Coalesced = append(coalesced, superseded)
+	return merged
+}
+
+// operationPriority returns the priority of the operation's trigger. Manual syncs apply the manifests regardless of
+// the automated sync policy, so they should not be superseded by the automated ones
+func operationPriority(op cdv1.Operation) int {
+	switch {
+	case isManualOperation(op):
+		return 2
+	case op.InitiatedBy.Type == cdv1.SyncInitiatorTypeWebhook:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// TerminateOperation terminates the operation in progress. A requested operation, which is not started yet,
+// is cancelled. The application is updated with its resource version, and it's retried with the latest one on a
+// conflict
+func TerminateOperation(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	key := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		// The application may be outdated, if the update conflicted
+		if !first {
+			if err := cli.Get(ctx, key, app); err != nil {
+				return err
+			}
+		}
+		first = false
+		return terminateOperation(ctx, cli, app)
+	})
+}
+
+func terminateOperation(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	state := app.Status.OperationState
+	running := state != nil && state.Phase == cdv1.OperationPhaseRunning
+	if app.Operation == nil && !running {
+		return fmt.Errorf("there is no running operation")
+	}
+
+	if app.Operation != nil {
+		patch := client.MergeFromWithOptions(app.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		app.Operation = nil
+		if err := cli.Patch(ctx, app, patch); err != nil {
+			return err
+		}
+	}
+
+	if !running {
+		return nil
+	}
+	// The state is replaced by the patched application
+	app.Status.OperationState.Phase = cdv1.OperationPhaseTerminating
+	app.Status.OperationState.Message = "Terminating"
+	return cli.Status().Update(ctx, app)
+}
+
+// RunOperation executes the operation and records its state in the status of the application.
+// If the operation is the one requested to the application, the request is removed.
+// The operation is cancelled when the context is done, when it's terminated, or when it exceeds the sync timeout
+func RunOperation(ctx context.Context, cli client.Client, app *cdv1.Application, op cdv1.Operation) error {
+	return runOperation(ctx, cli, app, op, 0)
+}
+
+// RetryOperation executes the failed operation again
+func RetryOperation(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	state := app.Status.OperationState
+	if state == nil {
+		return fmt.Errorf("there is no operation to retry")
+	}
+	log.Info(fmt.Sprintf("Retrying operation of %s/%s (retry %d)", app.Namespace, app.Name, state.RetryCount+1))
+	return runOperation(ctx, cli, app, state.Operation, state.RetryCount+1)
+}
+
+func runOperation(ctx context.Context, cli client.Client, app *cdv1.Application, op cdv1.Operation, retryCount int64) error {
+	if err := takeRequestedOperation(ctx, cli, app); err != nil {
+		log.Error(err, "Removing requested operation failed..")
+		return err
+	}
+
+	// Mark the operation running first, so that it can be watched and terminated
+	original := app.DeepCopy()
+	app.Status.OperationState = &cdv1.OperationState{
+		Operation:  op,
+		Phase:      cdv1.OperationPhaseRunning,
+		Message:    "Running",
+		StartedAt:  metav1.Now(),
+		RetryCount: retryCount,
+	}
+	if err := cli.Status().Patch(ctx, app.DeepCopy(), client.MergeFrom(original)); err != nil {
+		log.Error(err, "Updating operation state failed..")
+		return err
+	}
+
+	err := executeOperation(ctx, cli, app, op)
+	finishOperation(app.Status.OperationState, err)
+	setRetryExhaustedCond(app)
+
+	blocked := meta.FindStatusCondition(app.Status.Conditions, cdv1.ApplicationConditionSyncBlocked)
+	if err == nil && !isManualOperation(op) && blocked != nil && blocked.Status == metav1.ConditionTrue {
+		app.Status.OperationState.Message = "Compared only, " + blocked.Message
+	}
+	return err
+}
+
+// takeRequestedOperation removes the requested operation, which is to be executed. It fails with a conflict, if
+// another trigger is coalesced into the operation in the meantime. The application is reconciled again for it
+func takeRequestedOperation(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	if app.Operation == nil {
+		return nil
+	}
+
+	// Patch a copy, not to overwrite the status which is not patched yet
+	requested := app.DeepCopy()
+	cleared := requested.DeepCopy()
+	cleared.Operation = nil
+	if err := cli.Patch(ctx, cleared, client.MergeFromWithOptions(requested, client.MergeFromWithOptimisticLock{})); err != nil {
+		return err
+	}
+	app.Operation = nil
+	// Clearing the operation changes the generation. The resource version is not copied, as the status of the
+	// application is patched without the optimistic lock
+	app.Generation = cleared.Generation
+	return nil
+}
+
+// isManualOperation checks if the operation is requested by a user, not by autosync or a webhook
+func isManualOperation(op cdv1.Operation) bool {
+	return op.InitiatedBy.Type == cdv1.SyncInitiatorTypeUser || op.InitiatedBy.Type == cdv1.SyncInitiatorTypeSyncRequest
+}
+
+// isForcedOperation checks if the operation applies the manifests regardless of the automated sync policy.
+// Webhooks apply new commits only if onCommit is enabled, unless the legacy autosync is used
+func isForcedOperation(app *cdv1.Application, op cdv1.Operation) bool {
+	if op.InitiatedBy.Type == cdv1.SyncInitiatorTypeWebhook {
+		return app.Spec.SyncPolicy.Automated == nil
+	}
+	return isManualOperation(op)
+}
+
+// AbortInterruptedOperation marks the operation, which was running when the controller stopped, as an error.
+// It should be called only when no operation of the application is being executed
+func AbortInterruptedOperation(app *cdv1.Application) {
+	state := app.Status.OperationState
+	if state == nil || state.IsCompleted() {
+		return
+	}
+	now := metav1.Now()
+	state.Phase = cdv1.OperationPhaseError
+	state.Message = operationInterruptedMessage
+	state.FinishedAt = &now
+}
+
+func executeOperation(ctx context.Context, cli client.Client, app *cdv1.Application, op cdv1.Operation) error {
+	if op.Sync == nil {
+		return fmt.Errorf("operation has nothing to execute")
+	}
+
+	timeout := getSyncTimeout(app)
+	syncCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	terminated := watchTermination(syncCtx, cli, app, cancel)
+
+	state := app.Status.OperationState
+	state.SyncResult = &cdv1.SyncOperationResult{}
+	opts := manifestmanager.SyncOptions{
+		Forced:         isForcedOperation(app, op),
+		Revision:       op.Sync.Revision,
+		Terminated:     terminated,
+		Result:         state.SyncResult,
+		Resources:      op.Sync.Resources,
+		LabelSelectors: op.Sync.LabelSelectors,
+		Prune:          op.Sync.Prune,
+	}
+
+	// Automated syncs only compare the application with the live state, while they're blocked by a sync window
+	manual := isManualOperation(op)
+	windows, err := getSyncWindows(syncCtx, cli, app)
+	if err != nil {
+		return err
+	}
+	allowed, reason, err := checkSyncWindows(windows, time.Now(), manual)
+	if err != nil {
+		return err
+	}
+	if manual && !allowed {
+		return fmt.Errorf("%s", reason)
+	}
+	if !manual {
+		setSyncBlockedCond(app, allowed, reason)
+		opts.Forced = opts.Forced && allowed
+	}
+
+	if op.Sync.RollbackTo != nil {
+		err = rollback(syncCtx, cli, app, *op.Sync.RollbackTo, opts, op.InitiatedBy)
+	} else {
+		// A manual sync enables the autosync disabled by a rollback
+		if op.InitiatedBy.Type == cdv1.SyncInitiatorTypeUser {
+			EnableAutoSync(app)
+		}
+		err = runSync(syncCtx, cli, app, opts, op.InitiatedBy)
+	}
+
+	// The status is replaced by a rollback
+	app.Status.OperationState = state
+	return syncError(syncCtx, err, terminated(), timeout)
+}
+
+// syncError explains the error of the sync, if it failed as the sync was cancelled
+func syncError(ctx context.Context, err error, terminated bool, timeout time.Duration) error {
+	switch {
+	case err == nil:
+		return nil
+	case terminated:
+		return manifestmanager.ErrTerminated
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("sync timed out after %s: %w", timeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return ErrOperationInterrupted
+	default:
+		return err
+	}
+}
+
+// finishOperation sets the phase of the finished operation
+func finishOperation(state *cdv1.OperationState, err error) {
+	now := metav1.Now()
+	state.FinishedAt = &now
+
+	switch {
+	case err == nil:
+		state.Phase = cdv1.OperationPhaseSucceeded
+		state.Message = "Successfully synced"
+		if state.SyncResult != nil && state.SyncResult.Revision != "" {
+			state.Message = fmt.Sprintf("Successfully synced (revision %s)", state.SyncResult.Revision)
+		}
+	case errors.Is(err, manifestmanager.ErrTerminated):
+		state.Phase = cdv1.OperationPhaseFailed
+		state.Message = operationTerminatedMessage
+	case errors.Is(err, ErrOperationInterrupted):
+		state.Phase = cdv1.OperationPhaseError
+		state.Message = operationInterruptedMessage
+	case hasFailedResource(state.SyncResult):
+		state.Phase = cdv1.OperationPhaseFailed
+		state.Message = err.Error()
+	default:
+		state.Phase = cdv1.OperationPhaseError
+		state.Message = err.Error()
+	}
+
+	if n := len(state.Operation.Coalesced); n > 0 {
+		state.Message = fmt.Sprintf("%s, superseding %d coalesced triggers", state.Message, n)
+	}
+}
+
+func hasFailedResource(result *cdv1.SyncOperationResult) bool {
+	if result == nil {
+		return false
+	}
+	for _, r := range result.Resources {
+		if r.Status == cdv1.ResultCodeSyncFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// watchTermination cancels the sync, when the running operation of the application is terminated. It's checked
+// periodically until the context is done. It returns a function which checks if the operation is terminated, which
+// is called before applying or pruning resources as well
+func watchTermination(ctx context.Context, cli client.Client, app *cdv1.Application, cancel context.CancelFunc) func() bool {
+	var terminated int32
+	check := func() bool {
+		if atomic.LoadInt32(&terminated) == 1 {
+			return true
+		}
+		if !isTerminating(ctx, cli, app) {
+			return false
+		}
+		log.Info(fmt.Sprintf("Operation of %s/%s is terminated, cancelling the sync", app.Namespace, app.Name))
+		atomic.StoreInt32(&terminated, 1)
+		cancel()
+		return true
+	}
+
+	go wait.Until(func() { check() }, terminationCheckPeriod, ctx.Done())
+	return check
+}
+
+// isTerminating checks if the running operation of the application is being terminated
+func isTerminating(ctx context.Context, cli client.Client, app *cdv1.Application) bool {
+	latest := &cdv1.Application{}
+	if err := cli.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, latest); err != nil {
+		// The sync is over, if the context is done
+		if ctx.Err() == nil {
+			log.Error(err, "Getting application failed..")
+		}
+		return false
+	}
+	return latest.Status.OperationState != nil && latest.Status.OperationState.Phase == cdv1.OperationPhaseTerminating
+}
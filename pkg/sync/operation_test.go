@@ -0,0 +1,337 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newOperationTestApp(state *cdv1.OperationState) *cdv1.Application {
+	return &cdv1.Application{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-app", Namespace: "default"},
+		Status:     cdv1.ApplicationStatus{OperationState: state},
+	}
+}
+
+type requestOperationTestCase struct {
+	pending *cdv1.Operation
+	state   *cdv1.OperationState
+	op      cdv1.Operation
+
+	expectedErr       error
+	expectedOperation *cdv1.Operation
+}
+
+func TestRequestOperation(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	webhook := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeWebhook}
+	user := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeUser, Username: "admin"}
+	syncRequest := cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeSyncRequest, SyncRequest: "sr"}
+	revision := &cdv1.SyncOperation{Revision: "abcdef"}
+
+	tc := map[string]requestOperationTestCase{
+		"noOperation": {
+			op:                cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+		},
+		"completed": {
+			state:             &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed},
+			op:                cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+		},
+		"queuedAfterRunning": {
+			state:             &cdv1.OperationState{Phase: cdv1.OperationPhaseRunning},
+			op:                cdv1.Operation{Sync: revision, InitiatedBy: user},
+			expectedOperation: &cdv1.Operation{Sync: revision, InitiatedBy: user},
+		},
+		"coalesced": {
+			pending:           &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			op:                cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook, Coalesced: []cdv1.SyncInitiator{webhook}},
+		},
+		"coalescedIntoManual": {
+			pending:           &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: syncRequest, Coalesced: []cdv1.SyncInitiator{webhook}},
+			op:                cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: syncRequest, Coalesced: []cdv1.SyncInitiator{webhook, webhook}},
+		},
+		"manualSupersedesPending": {
+			pending:           &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: syncRequest},
+			op:                cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: user},
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: user, Coalesced: []cdv1.SyncInitiator{syncRequest}},
+		},
+		"notCoalescable": {
+			pending:           &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+			op:                cdv1.Operation{Sync: revision, InitiatedBy: user},
+			expectedErr:       ErrOperationInProgress,
+			expectedOperation: &cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: webhook},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := newOperationTestApp(c.state)
+			app.Operation = c.pending
+			fakeCli := fake.NewClientBuilder().WithScheme(s).WithObjects(app).Build()
+
+			err := RequestOperation(context.Background(), fakeCli, app, c.op)
+			require.Equal(t, c.expectedErr, err)
+
+			result := &cdv1.Application{}
+			require.NoError(t, fakeCli.Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, result))
+			require.Equal(t, c.expectedOperation, result.Operation)
+		})
+	}
+}
+
+func TestRequestOperationConcurrently(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := newOperationTestApp(nil)
+	fakeCli := fake.NewClientBuilder().WithScheme(s).WithObjects(app).Build()
+
+	// Triggers with outdated applications are coalesced, not lost
+	const triggers = 5
+	var wg gosync.WaitGroup
+	for i := 0; i < triggers; i++ {
+		wg.Add(1)
+		go func(app *cdv1.Application) {
+			defer wg.Done()
+			op := cdv1.Operation{Sync: &cdv1.SyncOperation{}, InitiatedBy: cdv1.SyncInitiator{Type: cdv1.SyncInitiatorTypeWebhook}}
+			require.NoError(t, RequestOperation(context.Background(), fakeCli, app, op))
+		}(app.DeepCopy())
+	}
+	wg.Wait()
+
+	result := &cdv1.Application{}
+	require.NoError(t, fakeCli.Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, result))
+	require.NotNil(t, result.Operation)
+	require.Len(t, result.Operation.Coalesced, triggers-1)
+}
+
+type terminateOperationTestCase struct {
+	operation *cdv1.Operation
+	state     *cdv1.OperationState
+	outdated  bool
+
+	expectedErrOccur bool
+	expectedPhase    cdv1.OperationPhase
+}
+
+func TestTerminateOperation(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	tc := map[string]terminateOperationTestCase{
+		"running": {
+			state:         &cdv1.OperationState{Phase: cdv1.OperationPhaseRunning},
+			expectedPhase: cdv1.OperationPhaseTerminating,
+		},
+		"requested": {
+			operation:     &cdv1.Operation{Sync: &cdv1.SyncOperation{}},
+			state:         &cdv1.OperationState{Phase: cdv1.OperationPhaseSucceeded},
+			expectedPhase: cdv1.OperationPhaseSucceeded,
+		},
+		"runningAndQueued": {
+			operation:     &cdv1.Operation{Sync: &cdv1.SyncOperation{}},
+			state:         &cdv1.OperationState{Phase: cdv1.OperationPhaseRunning},
+			expectedPhase: cdv1.OperationPhaseTerminating,
+		},
+		"outdated": {
+			operation:     &cdv1.Operation{Sync: &cdv1.SyncOperation{}},
+			state:         &cdv1.OperationState{Phase: cdv1.OperationPhaseRunning},
+			outdated:      true,
+			expectedPhase: cdv1.OperationPhaseTerminating,
+		},
+		"completed": {
+			state:            &cdv1.OperationState{Phase: cdv1.OperationPhaseSucceeded},
+			expectedErrOccur: true,
+			expectedPhase:    cdv1.OperationPhaseSucceeded,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := newOperationTestApp(c.state)
+			app.Operation = c.operation
+			fakeCli := fake.NewClientBuilder().WithScheme(s).WithObjects(app).Build()
+			if c.outdated {
+				// The application is modified by another process, after it's read
+				latest := app.DeepCopy()
+				latest.Labels = map[string]string{"modified": "true"}
+				require.NoError(t, fakeCli.Update(context.Background(), latest))
+			}
+
+			err := TerminateOperation(context.Background(), fakeCli, app)
+			require.Equal(t, c.expectedErrOccur, err != nil)
+
+			result := &cdv1.Application{}
+			require.NoError(t, fakeCli.Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, result))
+			require.Nil(t, result.Operation)
+			require.Equal(t, c.expectedPhase, result.Status.OperationState.Phase)
+		})
+	}
+}
+
+type finishOperationTestCase struct {
+	result    *cdv1.SyncOperationResult
+	err       error
+	coalesced []cdv1.SyncInitiator
+
+	expectedPhase   cdv1.OperationPhase
+	expectedMessage string
+}
+
+func TestFinishOperation(t *testing.T) {
+	tc := map[string]finishOperationTestCase{
+		"succeeded": {
+			result:          &cdv1.SyncOperationResult{Revision: "abcdef"},
+			expectedPhase:   cdv1.OperationPhaseSucceeded,
+			expectedMessage: "Successfully synced (revision abcdef)",
+		},
+		"terminated": {
+			result:          &cdv1.SyncOperationResult{},
+			err:             manifestmanager.ErrTerminated,
+			expectedPhase:   cdv1.OperationPhaseFailed,
+			expectedMessage: "Operation is terminated",
+		},
+		"failedResource": {
+			result: &cdv1.SyncOperationResult{Resources: []cdv1.ResourceResult{
+				{Kind: "Service", Name: "test", Status: cdv1.ResultCodeSynced},
+				{Kind: "Deployment", Name: "test", Status: cdv1.ResultCodeSyncFailed, Message: "invalid"},
+			}},
+			err:             fmt.Errorf("invalid"),
+			expectedPhase:   cdv1.OperationPhaseFailed,
+			expectedMessage: "invalid",
+		},
+		"error": {
+			result:          &cdv1.SyncOperationResult{},
+			err:             fmt.Errorf("cannot get manifests"),
+			expectedPhase:   cdv1.OperationPhaseError,
+			expectedMessage: "cannot get manifests",
+		},
+		"coalesced": {
+			result:          &cdv1.SyncOperationResult{Revision: "abcdef"},
+			coalesced:       []cdv1.SyncInitiator{{Type: cdv1.SyncInitiatorTypeWebhook}, {Type: cdv1.SyncInitiatorTypeWebhook}},
+			expectedPhase:   cdv1.OperationPhaseSucceeded,
+			expectedMessage: "Successfully synced (revision abcdef), superseding 2 coalesced triggers",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			state := &cdv1.OperationState{Operation: cdv1.Operation{Coalesced: c.coalesced}, Phase: cdv1.OperationPhaseRunning, SyncResult: c.result}
+			finishOperation(state, c.err)
+
+			require.Equal(t, c.expectedPhase, state.Phase)
+			require.Equal(t, c.expectedMessage, state.Message)
+			require.NotNil(t, state.FinishedAt)
+			require.True(t, state.IsCompleted())
+		})
+	}
+}
+
+type syncErrorTestCase struct {
+	err        error
+	terminated bool
+	cancel     bool
+	timeout    bool
+
+	expectedErr     error
+	expectedMessage string
+}
+
+func TestSyncError(t *testing.T) {
+	tc := map[string]syncErrorTestCase{
+		"succeeded": {
+			terminated: true,
+		},
+		"failed": {
+			err:             fmt.Errorf("invalid"),
+			expectedMessage: "invalid",
+		},
+		"terminated": {
+			err:         context.Canceled,
+			terminated:  true,
+			cancel:      true,
+			expectedErr: manifestmanager.ErrTerminated,
+		},
+		"interrupted": {
+			err:         context.Canceled,
+			cancel:      true,
+			expectedErr: ErrOperationInterrupted,
+		},
+		"timedOut": {
+			err:             context.DeadlineExceeded,
+			timeout:         true,
+			expectedErr:     context.DeadlineExceeded,
+			expectedMessage: "sync timed out after 1m0s: context deadline exceeded",
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if c.timeout {
+				ctx, cancel = context.WithTimeout(ctx, 0)
+				defer cancel()
+			}
+			if c.cancel {
+				cancel()
+			}
+
+			err := syncError(ctx, c.err, c.terminated, time.Minute)
+			if c.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			if c.expectedErr != nil {
+				require.ErrorIs(t, err, c.expectedErr)
+			}
+			if c.expectedMessage != "" {
+				require.Equal(t, c.expectedMessage, err.Error())
+			}
+		})
+	}
+}
+
+func TestWatchTermination(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(cdv1.AddToScheme(s))
+
+	app := newOperationTestApp(&cdv1.OperationState{Phase: cdv1.OperationPhaseRunning})
+	cli := fake.NewClientBuilder().WithScheme(s).WithObjects(app.DeepCopy()).Build()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	terminated := watchTermination(ctx, cli, app, cancel)
+	require.False(t, terminated())
+	require.NoError(t, ctx.Err())
+
+	latest := &cdv1.Application{}
+	require.NoError(t, cli.Get(context.Background(), types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, latest))
+	latest.Status.OperationState.Phase = cdv1.OperationPhaseTerminating
+	require.NoError(t, cli.Status().Update(context.Background(), latest))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(3 * terminationCheckPeriod):
+		t.Fatal("sync is not cancelled")
+	}
+	require.True(t, terminated())
+	require.ErrorIs(t, ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Default backoff of the retries
+const (
+	defaultRetryDuration    = 5 * time.Second
+	defaultRetryFactor      = 2
+	defaultRetryMaxDuration = 3 * time.Minute
+)
+
+// IsRetryPending checks if the last operation failed and is to be retried
+func IsRetryPending(app *cdv1.Application) bool {
+	retry := app.Spec.SyncPolicy.Retry
+	state := app.Status.OperationState
+	if retry == nil || !isRetriable(state) {
+		return false
+	}
+	return retry.Limit < 0 || state.RetryCount < retry.Limit
+}
+
+// RetryDelay returns the remaining delay before the pending retry
+func RetryDelay(app *cdv1.Application) time.Duration {
+	state := app.Status.OperationState
+	if state == nil || state.FinishedAt == nil {
+		return 0
+	}
+	var backoff *cdv1.Backoff
+	if app.Spec.SyncPolicy.Retry != nil {
+		backoff = app.Spec.SyncPolicy.Retry.Backoff
+	}
+	delay := getBackoff(backoff, state.RetryCount)
+	return time.Until(state.FinishedAt.Add(delay))
+}
+
+// isRetriable checks if the operation failed, not being terminated
+func isRetriable(state *cdv1.OperationState) bool {
+	if state == nil || state.Message == operationTerminatedMessage {
+		return false
+	}
+	return state.Phase == cdv1.OperationPhaseFailed || state.Phase == cdv1.OperationPhaseError
+}
+
+// getBackoff returns the delay after the retryCount-th retry, i.e., duration * factor^retryCount, up to maxDuration
+func getBackoff(backoff *cdv1.Backoff, retryCount int64) time.Duration {
+	duration := defaultRetryDuration
+	factor := int64(defaultRetryFactor)
+	maxDuration := defaultRetryMaxDuration
+
+	if backoff != nil {
+		duration = parseDuration(backoff.Duration, duration)
+		maxDuration = parseDuration(backoff.MaxDuration, maxDuration)
+		if backoff.Factor != nil && *backoff.Factor > 0 {
+			factor = *backoff.Factor
+		}
+	}
+
+	delay := float64(duration) * math.Pow(float64(factor), float64(retryCount))
+	if delay > float64(maxDuration) {
+		return maxDuration
+	}
+	return time.Duration(delay)
+}
+
+func parseDuration(s string, defaultDuration time.Duration) time.Duration {
+	if s == "" {
+		return defaultDuration
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Error(err, "time.ParseDuration failed..")
+		return defaultDuration
+	}
+	return d
+}
+
+// setRetryExhaustedCond sets the retry-exhausted condition, if the operation failed and no retry is left
+func setRetryExhaustedCond(app *cdv1.Application) {
+	state := app.Status.OperationState
+	if app.Spec.SyncPolicy.Retry == nil || !isRetriable(state) || IsRetryPending(app) {
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionRetryExhausted)
+		return
+	}
+
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:    cdv1.ApplicationConditionRetryExhausted,
+		Status:  metav1.ConditionTrue,
+		Reason:  "RetryLimitReached",
+		Message: fmt.Sprintf("Operation failed after %d attempts: %s", state.RetryCount+1, state.Message),
+	})
+}
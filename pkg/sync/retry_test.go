@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type getBackoffTestCase struct {
+	backoff    *cdv1.Backoff
+	retryCount int64
+
+	expectedBackoff time.Duration
+}
+
+func TestGetBackoff(t *testing.T) {
+	factor := int64(3)
+
+	tc := map[string]getBackoffTestCase{
+		"default": {
+			retryCount:      2,
+			expectedBackoff: 20 * time.Second,
+		},
+		"custom": {
+			backoff:         &cdv1.Backoff{Duration: "10s", Factor: &factor, MaxDuration: "10m"},
+			retryCount:      2,
+			expectedBackoff: 90 * time.Second,
+		},
+		"maxDuration": {
+			backoff:         &cdv1.Backoff{Duration: "1m", MaxDuration: "3m"},
+			retryCount:      5,
+			expectedBackoff: 3 * time.Minute,
+		},
+		"invalidDuration": {
+			backoff:         &cdv1.Backoff{Duration: "five seconds"},
+			expectedBackoff: 5 * time.Second,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expectedBackoff, getBackoff(c.backoff, c.retryCount))
+		})
+	}
+}
+
+type isRetryPendingTestCase struct {
+	retry *cdv1.RetryStrategy
+	state *cdv1.OperationState
+
+	expectedPending   bool
+	expectedExhausted bool
+}
+
+func TestIsRetryPending(t *testing.T) {
+	tc := map[string]isRetryPendingTestCase{
+		"noRetry": {
+			state: &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed},
+		},
+		"succeeded": {
+			retry: &cdv1.RetryStrategy{Limit: 3},
+			state: &cdv1.OperationState{Phase: cdv1.OperationPhaseSucceeded},
+		},
+		"failed": {
+			retry:           &cdv1.RetryStrategy{Limit: 3},
+			state:           &cdv1.OperationState{Phase: cdv1.OperationPhaseError, RetryCount: 2},
+			expectedPending: true,
+		},
+		"exhausted": {
+			retry:             &cdv1.RetryStrategy{Limit: 3},
+			state:             &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed, RetryCount: 3},
+			expectedExhausted: true,
+		},
+		"unlimited": {
+			retry:           &cdv1.RetryStrategy{Limit: -1},
+			state:           &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed, RetryCount: 100},
+			expectedPending: true,
+		},
+		"terminated": {
+			retry: &cdv1.RetryStrategy{Limit: 3},
+			state: &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed, Message: operationTerminatedMessage},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{
+				Spec:   cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{Retry: c.retry}},
+				Status: cdv1.ApplicationStatus{OperationState: c.state},
+			}
+			require.Equal(t, c.expectedPending, IsRetryPending(app))
+
+			setRetryExhaustedCond(app)
+			require.Equal(t, c.expectedExhausted, meta.IsStatusConditionTrue(app.Status.Conditions, cdv1.ApplicationConditionRetryExhausted))
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	finishedAt := metav1.NewTime(time.Now().Add(-3 * time.Second))
+	app := &cdv1.Application{
+		Spec:   cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{Retry: &cdv1.RetryStrategy{Limit: 3}}},
+		Status: cdv1.ApplicationStatus{OperationState: &cdv1.OperationState{Phase: cdv1.OperationPhaseFailed, FinishedAt: &finishedAt, RetryCount: 1}},
+	}
+
+	delay := RetryDelay(app)
+	require.True(t, delay > 6*time.Second && delay <= 7*time.Second, delay.String())
+}
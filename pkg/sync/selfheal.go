@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"time"
+
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getAutoSyncOptions returns the options of an automated sync, following the automated sync policy of the application
+func getAutoSyncOptions(app *cdv1.Application, now time.Time) manifestmanager.AutoSyncOptions {
+	policy := app.GetAutomatedSyncPolicy()
+	if !app.IsAutoSyncEnabled() {
+		return manifestmanager.AutoSyncOptions{}
+	}
+
+	return manifestmanager.AutoSyncOptions{
+		OnCommit:       policy.OnCommit,
+		SelfHeal:       policy.SelfHeal && isSelfHealDue(app, now),
+		SyncedRevision: getSyncedRevision(app),
+	}
+}
+
+// getSyncedRevision returns the revision synced last, if the source of the application is not changed since then
+func getSyncedRevision(app *cdv1.Application) string {
+	source := app.Spec.Source.DeepCopy()
+	source.Token = nil
+
+	for i := len(app.Status.History) - 1; i >= 0; i-- {
+		history := app.Status.History[i]
+		if history.Result != cdv1.SyncResultSucceeded {
+			continue
+		}
+		if !equality.Semantic.DeepEqual(history.Source, *source) {
+			return ""
+		}
+		return history.Revision
+	}
+	return ""
+}
+
+// isSelfHealDue checks if the backoff after the last self-heal attempt has passed
+func isSelfHealDue(app *cdv1.Application, now time.Time) bool {
+	attempts := app.Status.SelfHealAttempts
+	if attempts == 0 || app.Status.LastSelfHealedAt == nil {
+		return true
+	}
+
+	backoff := getBackoff(app.GetAutomatedSyncPolicy().SelfHealBackoff, attempts-1)
+	return !now.Before(app.Status.LastSelfHealedAt.Add(backoff))
+}
+
+// updateSelfHealStatus counts the consecutive self-heal attempts, which reverted the drift of the live state from the
+// synced revision. The count is reset once the live state stays in sync, or another revision is synced
+func updateSelfHealStatus(app *cdv1.Application, opts manifestmanager.SyncOptions, synced bool, now metav1.Time) {
+	switch {
+	case synced && !opts.Forced && app.Status.Sync.Revision == opts.AutoSync.SyncedRevision:
+		app.Status.SelfHealAttempts++
+		app.Status.LastSelfHealedAt = &now
+	case synced || app.Status.Sync.Status == cdv1.SyncStatusCodeSynced:
+		app.Status.SelfHealAttempts = 0
+		app.Status.LastSelfHealedAt = nil
+	}
+}
@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type getAutoSyncOptionsTestCase struct {
+	syncPolicy cdv1.SyncPolicy
+	status     cdv1.ApplicationStatus
+
+	expectedOptions manifestmanager.AutoSyncOptions
+}
+
+func TestGetAutoSyncOptions(t *testing.T) {
+	now := time.Now()
+	healedAt := metav1.NewTime(now.Add(-10 * time.Second))
+	source := cdv1.ApplicationSource{RepoURL: "https://github.com/tmax-cloud/cd-example-apps", Path: "guestbook"}
+	history := []cdv1.SyncHistory{
+		{ID: 0, Revision: "rev1", Source: source, Result: cdv1.SyncResultSucceeded},
+		{ID: 1, Revision: "rev2", Source: source, Result: cdv1.SyncResultFailed},
+	}
+
+	tc := map[string]getAutoSyncOptionsTestCase{
+		"disabled": {
+			status: cdv1.ApplicationStatus{History: history},
+		},
+		"legacyAutoSync": {
+			syncPolicy:      cdv1.SyncPolicy{AutoSync: true},
+			status:          cdv1.ApplicationStatus{History: history},
+			expectedOptions: manifestmanager.AutoSyncOptions{OnCommit: true, SelfHeal: true, SyncedRevision: "rev1"},
+		},
+		"onCommitOnly": {
+			syncPolicy:      cdv1.SyncPolicy{AutoSync: true, Automated: &cdv1.AutomatedSyncPolicy{OnCommit: true}},
+			status:          cdv1.ApplicationStatus{History: history},
+			expectedOptions: manifestmanager.AutoSyncOptions{OnCommit: true, SyncedRevision: "rev1"},
+		},
+		"selfHealDue": {
+			syncPolicy:      cdv1.SyncPolicy{Automated: &cdv1.AutomatedSyncPolicy{SelfHeal: true}},
+			status:          cdv1.ApplicationStatus{History: history, SelfHealAttempts: 1, LastSelfHealedAt: &healedAt},
+			expectedOptions: manifestmanager.AutoSyncOptions{SelfHeal: true, SyncedRevision: "rev1"},
+		},
+		"selfHealBackoff": {
+			syncPolicy:      cdv1.SyncPolicy{Automated: &cdv1.AutomatedSyncPolicy{SelfHeal: true, SelfHealBackoff: &cdv1.Backoff{Duration: "1m"}}},
+			status:          cdv1.ApplicationStatus{History: history, SelfHealAttempts: 1, LastSelfHealedAt: &healedAt},
+			expectedOptions: manifestmanager.AutoSyncOptions{SyncedRevision: "rev1"},
+		},
+		"sourceChanged": {
+			syncPolicy: cdv1.SyncPolicy{Automated: &cdv1.AutomatedSyncPolicy{OnCommit: true, SelfHeal: true}},
+			status: cdv1.ApplicationStatus{History: []cdv1.SyncHistory{
+				{ID: 0, Revision: "rev1", Source: cdv1.ApplicationSource{RepoURL: source.RepoURL, Path: "old"}, Result: cdv1.SyncResultSucceeded},
+			}},
+			expectedOptions: manifestmanager.AutoSyncOptions{OnCommit: true, SelfHeal: true},
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{Spec: cdv1.ApplicationSpec{Source: source, SyncPolicy: c.syncPolicy}, Status: c.status}
+			require.Equal(t, c.expectedOptions, getAutoSyncOptions(app, now))
+		})
+	}
+}
+
+func TestUpdateSelfHealStatus(t *testing.T) {
+	now := metav1.Now()
+	app := &cdv1.Application{}
+	app.Status.Sync.Revision = "rev1"
+	opts := manifestmanager.SyncOptions{AutoSync: manifestmanager.AutoSyncOptions{SelfHeal: true, SyncedRevision: "rev1"}}
+
+	// Drift of the synced revision is reverted
+	updateSelfHealStatus(app, opts, true, now)
+	updateSelfHealStatus(app, opts, true, now)
+	require.Equal(t, int64(2), app.Status.SelfHealAttempts)
+	require.Equal(t, &now, app.Status.LastSelfHealedAt)
+
+	// Out of sync, waiting for the backoff
+	app.Status.Sync.Status = cdv1.SyncStatusCodeOutOfSync
+	updateSelfHealStatus(app, opts, false, now)
+	require.Equal(t, int64(2), app.Status.SelfHealAttempts)
+
+	// Live state stays in sync
+	app.Status.Sync.Status = cdv1.SyncStatusCodeSynced
+	updateSelfHealStatus(app, opts, false, now)
+	require.Equal(t, int64(0), app.Status.SelfHealAttempts)
+	require.Nil(t, app.Status.LastSelfHealedAt)
+}
@@ -2,26 +2,30 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"math/rand"
-	"net/http"
+	"hash/fnv"
+	"strconv"
 	"time"
 
 	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
-	"github.com/tmax-cloud/cd-operator/internal/utils"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
 	"github.com/tmax-cloud/cd-operator/pkg/manifestmanager"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-var (
-	log              = logf.Log.WithName("sync")
-	PlainYamlManager manifestmanager.ManifestManager
-	HelmManager      manifestmanager.ManifestManager
-)
+var log = logf.Log.WithName("sync")
 
 const (
 	defaultSyncCheckPerod = 60
+	// syncCheckJitterFactor spreads the periodic sync checks, not to check all applications at once
+	syncCheckJitterFactor = 0.1
+	// defaultSyncTimeout is used if the timeout is configured neither in the application nor in the config map
+	defaultSyncTimeout = 10 * time.Minute
 )
 
 func SetDefaultSyncStatus(app *cdv1.Application) {
@@ -33,54 +37,142 @@ func SetDefaultSyncCheckPeriod(app *cdv1.Application) {
 	app.Spec.SyncPolicy.SyncCheckPeriod = defaultSyncCheckPerod
 }
 
-func PeriodicSyncCheck(cli client.Client, app *cdv1.Application, done chan bool, ticker *time.Ticker) {
-	randNum := rand.Int()
-	log.Info(fmt.Sprintf("Periodic sync check %d start..", randNum))
-
-	for {
-		select {
-		case <-done:
-			log.Info(fmt.Sprintf("Periodic sync check %d finished", randNum))
-			return
-		case <-ticker.C:
-			log.Info(fmt.Sprintf("Periodic sync check %d", randNum))
-			if err := CheckSync(cli, app, false); err != nil {
-				log.Error(err, "")
-			}
-		}
+// SyncCheckDelay returns the delay before the next periodic sync check, which compares the application with the
+// live state and syncs it if autosync is enabled
+func SyncCheckDelay(app *cdv1.Application) time.Duration {
+	period := app.Spec.SyncPolicy.SyncCheckPeriod
+	if period <= 0 {
+		period = defaultSyncCheckPerod
 	}
+	return wait.Jitter(time.Duration(period)*time.Second, syncCheckJitterFactor)
 }
 
-func CheckSync(cli client.Client, app *cdv1.Application, forced bool) error {
-	log.Info("Checking Sync status...")
+// getSyncTimeout returns the maximum duration of a sync of the application
+func getSyncTimeout(app *cdv1.Application) time.Duration {
+	defaultTimeout := parseDuration(configs.SyncTimeout, defaultSyncTimeout)
+	if defaultTimeout <= 0 {
+		defaultTimeout = defaultSyncTimeout
+	}
+	if timeout := parseDuration(app.Spec.SyncPolicy.SyncTimeout, defaultTimeout); timeout > 0 {
+		return timeout
+	}
+	return defaultTimeout
+}
 
-	if PlainYamlManager == nil {
-		gitCli, err := utils.GetGitCli(app, cli)
-		if err != nil {
-			return err
-		}
-		PlainYamlManager = manifestmanager.NewPlainYamlManager(context.Background(), cli, http.DefaultClient, gitCli)
+// rollback syncs the application to the revision of the sync history. Autosync is disabled until the spec of the
+// application is changed or the application is synced manually
+func rollback(ctx context.Context, cli client.Client, app *cdv1.Application, id int64, opts manifestmanager.SyncOptions, initiator cdv1.SyncInitiator) error {
+	history := app.GetHistory(id)
+	if history == nil {
+		return fmt.Errorf("there is no history %d", id)
+	}
+	log.Info(fmt.Sprintf("Rollback to history %d, revision %s...", history.ID, history.Revision))
+
+	// Sync the source snapshot of the history, with the current token
+	target := app.DeepCopy()
+	target.Spec.Source = *history.Source.DeepCopy()
+	target.Spec.Source.Token = app.Spec.Source.Token
+
+	opts.Forced = true
+	opts.Revision = history.Revision
+	err := runSync(ctx, cli, target, opts, initiator)
+	app.Status = target.Status
+	if err != nil {
+		return err
 	}
-	if HelmManager == nil {
-		HelmManager = manifestmanager.NewHelmManager(context.Background(), cli)
+
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:               cdv1.ApplicationConditionAutoSyncDisabled,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Rollback",
+		Message:            fmt.Sprintf("Autosync is disabled, as the application is rolled back to revision %s (history %d). It's enabled again when the application is synced manually or its spec is changed", history.Revision, history.ID),
+		ObservedGeneration: app.Generation,
+	})
+	return nil
+}
+
+// SpecHash returns the hash of the spec of the application
+func SpecHash(app *cdv1.Application) string {
+	b, err := json.Marshal(app.Spec)
+	if err != nil {
+		log.Error(err, "json.Marshal failed..")
+		return ""
 	}
+	h := fnv.New64a()
+	_, _ = h.Write(b)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// IsSpecChanged checks if the spec of the application is changed since it was reconciled last. The generation can't
+// tell it, as it's changed by requesting and clearing the operations as well
+func IsSpecChanged(app *cdv1.Application) bool {
+	return app.Status.ObservedSpecHash != "" && app.Status.ObservedSpecHash != SpecHash(app)
+}
 
-	var mgr manifestmanager.ManifestManager
+// EnableAutoSync enables the autosync which is disabled temporarily
+func EnableAutoSync(app *cdv1.Application) {
+	meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionAutoSyncDisabled)
+}
 
-	switch app.Spec.Source.Type {
-	case cdv1.ApplicationSourceTypePlainYAML:
-		mgr = PlainYamlManager
-	case cdv1.ApplicationSourceTypeHelm:
-		mgr = HelmManager
-	default:
-		err := fmt.Errorf("get sync manager failed")
+func runSync(ctx context.Context, cli client.Client, app *cdv1.Application, opts manifestmanager.SyncOptions, initiator cdv1.SyncInitiator) error {
+	mgr, err := getManifestManager(ctx, cli, app)
+	if err != nil {
 		return err
 	}
 
+	startedAt := metav1.Now()
+	lastSyncedAt := app.Status.LastSyncedAt
+	if !opts.Forced {
+		opts.AutoSync = getAutoSyncOptions(app, startedAt.Time)
+	}
+
 	app.Status.Sync.Status = cdv1.SyncStatusCodeUnknown
-	if err := mgr.Sync(app, forced); err != nil {
+	if err := mgr.Sync(ctx, app, opts); err != nil {
+		// Record failed syncs only if the manifests were to be applied
+		if opts.Forced || app.IsAutoSyncEnabled() {
+			addHistory(app, startedAt, initiator, err)
+		}
 		return err
 	}
 
+	now := metav1.Now()
+	app.Status.ReconciledAt = &now
+	synced := isSyncedSince(app, lastSyncedAt)
+	updateSelfHealStatus(app, opts, synced, now)
+	if synced {
+		addHistory(app, startedAt, initiator, nil)
+	}
 	return nil
 }
+
+// isSyncedSince checks if the application was synced after lastSyncedAt. The times are compared by their values, as
+// the status may be copied or fetched again during the sync
+func isSyncedSince(app *cdv1.Application, lastSyncedAt *metav1.Time) bool {
+	return !app.Status.LastSyncedAt.Equal(lastSyncedAt)
+}
+
+// Diff compares the desired state of the revision with the live cluster, without applying anything.
+// Only the resources selected by the options are compared
+func Diff(ctx context.Context, cli client.Client, app *cdv1.Application, opts manifestmanager.SyncOptions) ([]manifestmanager.ResourceDiff, error) {
+	mgr, err := getManifestManager(ctx, cli, app)
+	if err != nil {
+		return nil, err
+	}
+
+	return mgr.Diff(ctx, app, opts)
+}
+
+// Clear deletes all resources deployed by the application
+func Clear(ctx context.Context, cli client.Client, app *cdv1.Application) error {
+	mgr, err := getManifestManager(ctx, cli, app)
+	if err != nil {
+		return err
+	}
+
+	return mgr.Clear(ctx, app)
+}
+
+// getManifestManager creates a manifest manager for the application, from the factory registered for its source type
+func getManifestManager(ctx context.Context, cli client.Client, app *cdv1.Application) (manifestmanager.ManifestManager, error) {
+	return manifestmanager.New(ctx, cli, app)
+}
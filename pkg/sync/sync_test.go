@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"github.com/tmax-cloud/cd-operator/internal/configs"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type syncCheckDelayTestCase struct {
+	syncCheckPeriod int64
+
+	expectedPeriod time.Duration
+}
+
+func TestSyncCheckDelay(t *testing.T) {
+	tc := map[string]syncCheckDelayTestCase{
+		"default": {
+			expectedPeriod: defaultSyncCheckPerod * time.Second,
+		},
+		"custom": {
+			syncCheckPeriod: 180,
+			expectedPeriod:  3 * time.Minute,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			app := &cdv1.Application{Spec: cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{SyncCheckPeriod: c.syncCheckPeriod}}}
+			for i := 0; i < 10; i++ {
+				delay := SyncCheckDelay(app)
+				require.GreaterOrEqual(t, delay, c.expectedPeriod)
+				require.LessOrEqual(t, delay, time.Duration(float64(c.expectedPeriod)*(1+syncCheckJitterFactor)))
+			}
+		})
+	}
+}
+
+type getSyncTimeoutTestCase struct {
+	defaultTimeout string
+	syncTimeout    string
+
+	expectedTimeout time.Duration
+}
+
+func TestGetSyncTimeout(t *testing.T) {
+	tc := map[string]getSyncTimeoutTestCase{
+		"default": {
+			expectedTimeout: defaultSyncTimeout,
+		},
+		"configMap": {
+			defaultTimeout:  "5m",
+			expectedTimeout: 5 * time.Minute,
+		},
+		"application": {
+			defaultTimeout:  "5m",
+			syncTimeout:     "30s",
+			expectedTimeout: 30 * time.Second,
+		},
+		"invalid": {
+			defaultTimeout:  "0s",
+			syncTimeout:     "invalid",
+			expectedTimeout: defaultSyncTimeout,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			configs.SyncTimeout = c.defaultTimeout
+			defer func() {
+				configs.SyncTimeout = ""
+			}()
+
+			app := &cdv1.Application{Spec: cdv1.ApplicationSpec{SyncPolicy: cdv1.SyncPolicy{SyncTimeout: c.syncTimeout}}}
+			require.Equal(t, c.expectedTimeout, getSyncTimeout(app))
+		})
+	}
+}
+
+func TestIsSyncedSince(t *testing.T) {
+	lastSyncedAt := metav1.NewTime(time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC))
+
+	app := &cdv1.Application{}
+	require.False(t, isSyncedSince(app, nil))
+
+	// A copy of the same time is not a sync
+	app.Status.LastSyncedAt = lastSyncedAt.DeepCopy()
+	require.False(t, isSyncedSince(app, &lastSyncedAt))
+	require.False(t, isSyncedSince(app.DeepCopy(), &lastSyncedAt))
+
+	now := metav1.Now()
+	app.Status.LastSyncedAt = &now
+	require.True(t, isSyncedSince(app, &lastSyncedAt))
+	require.True(t, isSyncedSince(app, nil))
+}
@@ -0,0 +1,111 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	// Embed the timezone database, as the operator image may not have one
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getSyncWindows returns the sync windows of the application and the SyncWindowPolicies selecting it
+func getSyncWindows(ctx context.Context, cli client.Client, app *cdv1.Application) ([]cdv1.SyncWindow, error) {
+	windows := append([]cdv1.SyncWindow{}, app.Spec.SyncPolicy.SyncWindows...)
+
+	policies := &cdv1.SyncWindowPolicyList{}
+	if err := cli.List(ctx, policies, client.InNamespace(app.Namespace)); err != nil {
+		return nil, err
+	}
+	for _, policy := range policies.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("selector of SyncWindowPolicy %s is not valid: %s", policy.Name, err.Error())
+		}
+		if selector.Matches(labels.Set(app.Labels)) {
+			windows = append(windows, policy.Spec.Windows...)
+		}
+	}
+	return windows, nil
+}
+
+// checkSyncWindows checks if a sync is allowed at the time. Manual syncs are checked only against the windows which
+// block manual syncs. It returns the reason if the sync is blocked
+func checkSyncWindows(windows []cdv1.SyncWindow, now time.Time, manual bool) (bool, string, error) {
+	var allows, activeAllows, activeDenies []string
+	for _, w := range windows {
+		if manual && !w.BlockManualSync {
+			continue
+		}
+		active, err := isSyncWindowActive(w, now)
+		if err != nil {
+			return false, "", err
+		}
+
+		desc := fmt.Sprintf("%s (%s)", w.Schedule, w.Duration)
+		switch w.Kind {
+		case cdv1.SyncWindowKindAllow:
+			allows = append(allows, desc)
+			if active {
+				activeAllows = append(activeAllows, desc)
+			}
+		case cdv1.SyncWindowKindDeny:
+			if active {
+				activeDenies = append(activeDenies, desc)
+			}
+		}
+	}
+
+	if len(activeDenies) > 0 {
+		return false, "sync blocked by deny window " + strings.Join(activeDenies, ", "), nil
+	}
+	if len(allows) > 0 && len(activeAllows) == 0 {
+		return false, "sync blocked by window, outside of allow window " + strings.Join(allows, ", "), nil
+	}
+	return true, "", nil
+}
+
+// isSyncWindowActive checks if the time is in the window, i.e., the window started within its duration
+func isSyncWindowActive(w cdv1.SyncWindow, now time.Time) (bool, error) {
+	schedule, err := cron.ParseStandard(w.Schedule)
+	if err != nil {
+		return false, fmt.Errorf("schedule %q of the sync window is not valid: %s", w.Schedule, err.Error())
+	}
+	duration, err := time.ParseDuration(w.Duration)
+	if err != nil {
+		return false, fmt.Errorf("duration %q of the sync window is not valid: %s", w.Duration, err.Error())
+	}
+	loc := time.UTC
+	if w.TimeZone != "" {
+		loc, err = time.LoadLocation(w.TimeZone)
+		if err != nil {
+			return false, fmt.Errorf("timeZone %q of the sync window is not valid: %s", w.TimeZone, err.Error())
+		}
+	}
+
+	// The window is active if it started after now-duration
+	start := schedule.Next(now.In(loc).Add(-duration))
+	return !start.After(now), nil
+}
+
+// setSyncBlockedCond sets the sync-blocked condition, which disables autosync while it's true
+func setSyncBlockedCond(app *cdv1.Application, allowed bool, reason string) {
+	if allowed {
+		meta.RemoveStatusCondition(&app.Status.Conditions, cdv1.ApplicationConditionSyncBlocked)
+		return
+	}
+	meta.SetStatusCondition(&app.Status.Conditions, metav1.Condition{
+		Type:    cdv1.ApplicationConditionSyncBlocked,
+		Status:  metav1.ConditionTrue,
+		Reason:  "SyncWindow",
+		Message: reason,
+	})
+}
@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	cdv1 "github.com/tmax-cloud/cd-operator/api/v1"
+)
+
+type checkSyncWindowsTestCase struct {
+	windows []cdv1.SyncWindow
+	now     string
+	manual  bool
+
+	expectedAllowed  bool
+	expectedErrOccur bool
+}
+
+func TestCheckSyncWindows(t *testing.T) {
+	denyNight := cdv1.SyncWindow{Kind: cdv1.SyncWindowKindDeny, Schedule: "0 22 * * *", Duration: "8h"}
+	allowNoon := cdv1.SyncWindow{Kind: cdv1.SyncWindowKindAllow, Schedule: "0 12 * * *", Duration: "1h", TimeZone: "Asia/Seoul"}
+	denyManual := cdv1.SyncWindow{Kind: cdv1.SyncWindowKindDeny, Schedule: "0 22 * * *", Duration: "8h", BlockManualSync: true}
+
+	tc := map[string]checkSyncWindowsTestCase{
+		"noWindow": {
+			now:             "2021-11-01T23:00:00Z",
+			expectedAllowed: true,
+		},
+		"inDenyWindow": {
+			windows: []cdv1.SyncWindow{denyNight},
+			now:     "2021-11-02T05:59:59Z",
+		},
+		"afterDenyWindow": {
+			windows:         []cdv1.SyncWindow{denyNight},
+			now:             "2021-11-02T06:00:00Z",
+			expectedAllowed: true,
+		},
+		"inAllowWindow": {
+			windows:         []cdv1.SyncWindow{allowNoon},
+			now:             "2021-11-01T03:30:00Z", // 12:30 KST
+			expectedAllowed: true,
+		},
+		"outsideAllowWindow": {
+			windows: []cdv1.SyncWindow{allowNoon},
+			now:     "2021-11-01T12:30:00Z", // 21:30 KST
+		},
+		"denyOverridesAllow": {
+			windows: []cdv1.SyncWindow{{Kind: cdv1.SyncWindowKindAllow, Schedule: "0 0 * * *", Duration: "24h"}, denyNight},
+			now:     "2021-11-01T23:00:00Z",
+		},
+		"manualNotBlocked": {
+			windows:         []cdv1.SyncWindow{denyNight, allowNoon},
+			now:             "2021-11-01T23:00:00Z",
+			manual:          true,
+			expectedAllowed: true,
+		},
+		"manualBlocked": {
+			windows: []cdv1.SyncWindow{denyManual},
+			now:     "2021-11-01T23:00:00Z",
+			manual:  true,
+		},
+		"invalidSchedule": {
+			windows:          []cdv1.SyncWindow{{Kind: cdv1.SyncWindowKindDeny, Schedule: "every night", Duration: "1h"}},
+			now:              "2021-11-01T23:00:00Z",
+			expectedErrOccur: true,
+		},
+		"invalidTimeZone": {
+			windows:          []cdv1.SyncWindow{{Kind: cdv1.SyncWindowKindDeny, Schedule: "0 22 * * *", Duration: "1h", TimeZone: "Mars/Olympus"}},
+			now:              "2021-11-01T23:00:00Z",
+			expectedErrOccur: true,
+		},
+	}
+
+	for name, c := range tc {
+		t.Run(name, func(t *testing.T) {
+			now, err := time.Parse(time.RFC3339, c.now)
+			require.NoError(t, err)
+
+			allowed, reason, err := checkSyncWindows(c.windows, now, c.manual)
+			if c.expectedErrOccur {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expectedAllowed, allowed)
+			require.Equal(t, c.expectedAllowed, reason == "")
+		})
+	}
+}
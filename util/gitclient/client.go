@@ -1,6 +1,7 @@
 package gitclient
 
 import (
+	"context"
 	"os"
 
 	gogit "github.com/go-git/go-git/v5"
@@ -10,14 +11,14 @@ import (
 
 var log = logf.Log.WithName("git-client")
 
-// Clone the given repository to the given directory
-func Clone(url string, localPath string, revision string) (*gogit.Repository, error) {
+// Clone the given repository to the given directory. The clone is cancelled when the context is done
+func Clone(ctx context.Context, url string, localPath string, revision string) (*gogit.Repository, error) {
 	log.Info("git clone " + url)
 
 	// TODO : tag(NewTagReferenceName)나 commit SHA 일 경우도 지원해줘야 함
 	referenceName := plumbing.NewBranchReferenceName(revision)
 	// comment : 이미 존재하는 폴더에 클론을 받으면 ErrRepositoryAlreadyExists 오류 출력
-	repo, err := gogit.PlainClone(localPath, false, &gogit.CloneOptions{
+	repo, err := gogit.PlainCloneContext(ctx, localPath, false, &gogit.CloneOptions{
 		URL:           url,
 		ReferenceName: referenceName,
 		Progress:      os.Stdout,
@@ -59,14 +60,16 @@ func Fetch(repo *gogit.Repository) error {
 	return nil
 }
 
-// Pull incorporates changes from a remote repository into the current branch
-func Pull(repo *gogit.Repository) error {
+// Pull incorporates changes from a remote repository into the current branch. The pull is cancelled when the context
+// is done
+func Pull(ctx context.Context, repo *gogit.Repository) error {
 	worktree, err := repo.Worktree()
 	if err != nil {
-		panic(err)
+		log.Error(err, "repo.Worktree failed..")
+		return err
 	}
 
-	err = worktree.Pull(&gogit.PullOptions{
+	err = worktree.PullContext(ctx, &gogit.PullOptions{
 		RemoteName: "origin",
 		Progress:   os.Stdout,
 	})
@@ -81,3 +84,37 @@ func Pull(repo *gogit.Repository) error {
 
 	return nil
 }
+
+// Checkout checks out the given revision, which can be a branch, a tag or a commit SHA
+func Checkout(repo *gogit.Repository, revision string) error {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		// Branches which are not checked out yet exist only as remote references
+		hash, err = repo.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + revision))
+		if err != nil {
+			log.Error(err, "repo.ResolveRevision failed..")
+			return err
+		}
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if err := worktree.Checkout(&gogit.CheckoutOptions{Hash: *hash}); err != nil {
+		log.Error(err, "worktree.Checkout failed..")
+		return err
+	}
+	return nil
+}
+
+// Head returns the commit SHA of HEAD
+func Head(repo *gogit.Repository) (string, error) {
+	ref, err := repo.Head()
+	if err != nil {
+		log.Error(err, "repo.Head failed..")
+		return "", err
+	}
+	return ref.Hash().String(), nil
+}
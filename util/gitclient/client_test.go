@@ -1,6 +1,7 @@
 package gitclient
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -19,11 +20,20 @@ func TestClone(t *testing.T) {
 
 	defer os.RemoveAll(localPath)
 
-	repo, err := Clone(testRepoURL, localPath, revision)
+	repo, err := Clone(context.Background(), testRepoURL, localPath, revision)
 	require.NotEmpty(t, repo)
 	require.NoError(t, err)
 }
 
+func TestCloneCancelled(t *testing.T) {
+	localPath := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Clone(ctx, testRepoURL, localPath+"/repo", "main")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
 type openTestCase struct {
 	localRepoPath string
 
@@ -37,7 +47,7 @@ func TestOpen(t *testing.T) {
 	revision := "main"
 
 	// Clone for ValidRepo Case
-	_, err := Clone(testRepoURL, localPath1, revision)
+	_, err := Clone(context.Background(), testRepoURL, localPath1, revision)
 	require.NoError(t, err)
 	defer os.RemoveAll(localPath1)
 
@@ -80,7 +90,7 @@ func TestFetch(t *testing.T) {
 
 	defer os.RemoveAll(localPath)
 
-	repo, err := Clone(testRepoURL, localPath, revision)
+	repo, err := Clone(context.Background(), testRepoURL, localPath, revision)
 	require.NotEmpty(t, repo)
 	require.NoError(t, err)
 
@@ -95,10 +105,10 @@ func TestPull(t *testing.T) {
 
 	defer os.RemoveAll(localPath)
 
-	repo, err := Clone(testRepoURL, localPath, revision)
+	repo, err := Clone(context.Background(), testRepoURL, localPath, revision)
 	require.NotEmpty(t, repo)
 	require.NoError(t, err)
 
-	err = Pull(repo)
+	err = Pull(context.Background(), repo)
 	require.NoError(t, err)
 }
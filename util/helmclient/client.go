@@ -1,11 +1,16 @@
 package helmclient
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os/exec"
+	"time"
 
 	gohelm "github.com/mittwald/go-helm-client"
 	cdexec "github.com/tmax-cloud/cd-operator/util/exec"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -16,9 +21,9 @@ type Client struct {
 }
 
 // InstallChart installs helm chart
-func (c *Client) InstallChart(chartSpec *gohelm.ChartSpec) (string, error) {
+func (c *Client) InstallChart(ctx context.Context, chartSpec *gohelm.ChartSpec) (string, error) {
 	// If the chart is already installed, trigger an upgrade instead.
-	release, err := c.Client.InstallOrUpgradeChart(context.Background(), chartSpec)
+	release, err := c.Client.InstallOrUpgradeChart(ctx, chartSpec)
 	// if err != context.DeadlineExceeded { // TODO : 확인필요. ChartSpec wait true 문제 -> 왜 true로 set??
 	if err != nil {
 		return "", err
@@ -27,6 +32,43 @@ func (c *Client) InstallChart(chartSpec *gohelm.ChartSpec) (string, error) {
 	return release.Manifest, nil
 }
 
+// SetPostRenderer makes the client pass the rendered manifests through the post-renderer, before they're installed.
+// It's ignored if the client is not the one of go-helm-client
+func (c *Client) SetPostRenderer(postRenderer postrender.PostRenderer) {
+	helmClient, ok := c.Client.(*gohelm.HelmClient)
+	if !ok || helmClient.ActionConfig == nil || helmClient.ActionConfig.KubeClient == nil {
+		return
+	}
+	helmClient.ActionConfig.KubeClient = &postRenderingKubeClient{Interface: helmClient.ActionConfig.KubeClient, postRenderer: postRenderer}
+}
+
+// postRenderingKubeClient post-renders the manifests, before they're built into the resources to be created or
+// updated. go-helm-client does not support post-renderers of helm
+type postRenderingKubeClient struct {
+	kube.Interface
+	postRenderer postrender.PostRenderer
+}
+
+func (c *postRenderingKubeClient) Build(reader io.Reader, validate bool) (kube.ResourceList, error) {
+	manifests := &bytes.Buffer{}
+	if _, err := manifests.ReadFrom(reader); err != nil {
+		return nil, err
+	}
+	rendered, err := c.postRenderer.Run(manifests)
+	if err != nil {
+		log.Error(err, "PostRenderer failed..")
+		return nil, err
+	}
+	return c.Interface.Build(rendered, validate)
+}
+
+func (c *postRenderingKubeClient) WaitForDelete(resources kube.ResourceList, timeout time.Duration) error {
+	if kubeClient, ok := c.Interface.(kube.InterfaceExt); ok {
+		return kubeClient.WaitForDelete(resources, timeout)
+	}
+	return nil
+}
+
 // UninstallReleaseByName uninstalls a release identified by the provided 'name'.
 func (c *Client) UninstallReleaseByName(releaseName string) error {
 	if err := c.Client.UninstallReleaseByName(releaseName); err != nil {
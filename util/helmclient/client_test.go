@@ -1,14 +1,19 @@
 package helmclient
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
 	gohelm "github.com/mittwald/go-helm-client"
 	"github.com/stretchr/testify/require"
 	"github.com/tmax-cloud/cd-operator/internal/utils"
 	"github.com/tmax-cloud/cd-operator/util/gitclient"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
@@ -36,7 +41,7 @@ func TestInstallChart(t *testing.T) {
 	defer os.RemoveAll(path)
 
 	// 1. 로컬에 helm manifest의 git repo clone
-	_, err = gitclient.Clone(url, path, revision)
+	_, err = gitclient.Clone(context.Background(), url, path, revision)
 	require.Equal(t, err, nil)
 
 	// 2. 로컬에 저장된 경로를 이용하여 chart install
@@ -51,7 +56,7 @@ func TestInstallChart(t *testing.T) {
 		Wait:        false,
 	}
 
-	_, err = testHelmClient.InstallChart(chartSpec)
+	_, err = testHelmClient.InstallChart(context.Background(), chartSpec)
 	require.Equal(t, err, nil)
 
 	defer func() {
@@ -86,7 +91,7 @@ func TestInstallChartByCLI(t *testing.T) {
 	defer os.RemoveAll(path)
 
 	// 1. 로컬에 helm manifest의 git repo clone
-	_, err := gitclient.Clone(url, path, revision)
+	_, err := gitclient.Clone(context.Background(), url, path, revision)
 	require.Equal(t, err, nil)
 
 	// 2. 로컬에 저장된 경로를 이용하여 chart install
@@ -123,3 +128,36 @@ func TestInstallChartByCLI(t *testing.T) {
 	require.NotEqual(t, deploy, nil)
 	require.NotEqual(t, svc, nil)
 }
+
+// recordingKubeClient records the manifests which are built into resources
+type recordingKubeClient struct {
+	kube.Interface
+	built string
+}
+
+func (c *recordingKubeClient) Build(reader io.Reader, _ bool) (kube.ResourceList, error) {
+	b, err := io.ReadAll(reader)
+	c.built = string(b)
+	return nil, err
+}
+
+// prefixPostRenderer prefixes the manifests with a comment
+type prefixPostRenderer struct{}
+
+func (prefixPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString("# post-rendered\n" + renderedManifests.String()), nil
+}
+
+func TestSetPostRenderer(t *testing.T) {
+	recorder := &recordingKubeClient{}
+	helmClient := &gohelm.HelmClient{ActionConfig: &action.Configuration{KubeClient: recorder}}
+	c := &Client{Client: helmClient}
+	c.SetPostRenderer(prefixPostRenderer{})
+
+	_, err := helmClient.ActionConfig.KubeClient.Build(strings.NewReader("kind: Service\n"), false)
+	require.NoError(t, err)
+	require.Equal(t, "# post-rendered\nkind: Service\n", recorder.built)
+
+	// Other clients are left as they are
+	(&Client{}).SetPostRenderer(prefixPostRenderer{})
+}